@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/auth"
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/data/abuse"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/pow"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+// Defaults of the proof of work required to report a shorturl.
+const (
+	defaultReportDifficulty   = 18
+	defaultReportChallengeAge = 10 * time.Minute
+)
+
+// previewPage shows where a shorturl leads without visiting it and lets
+// anyone report it. The proof of work is solved in the browser.
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Preview of /{{.Code}}</title>
+<style>
+body { font-family: sans-serif; max-width: 720px; margin: 2em auto; padding: 0 1em; color: #24292f; }
+textarea, select { display: block; margin: 0.5em 0 1em; width: 100%; }
+</style>
+</head>
+<body>
+<h1>/{{.Code}}</h1>
+{{if .Disabled}}<p>This link has been disabled.</p>
+{{else if .URL}}<p>This link leads to <strong>{{.URL}}</strong></p>
+{{else}}<p>The destination of this link is not shown.</p>
+{{end}}<h2>Report this link</h2>
+<form id="report">
+<label for="reason">Reason</label>
+<select id="reason" name="reason">
+<option value="spam">Spam</option>
+<option value="phishing">Phishing</option>
+<option value="malware">Malware</option>
+<option value="other">Other</option>
+</select>
+<label for="details">Details</label>
+<textarea id="details" name="details" rows="4" maxlength="1000"></textarea>
+<button type="submit">Report</button>
+</form>
+<p id="status"></p>
+<script>
+const code = {{.Code}};
+const challenge = {{.Challenge}};
+const difficulty = {{.Difficulty}};
+
+function zeroBits(hash) {
+	let n = 0;
+	for (const b of hash) {
+		if (b !== 0) {
+			return n + Math.clz32(b) - 24;
+		}
+		n += 8;
+	}
+	return n;
+}
+
+async function solve() {
+	const enc = new TextEncoder();
+	for (let n = 0; ; n++) {
+		const hash = new Uint8Array(await crypto.subtle.digest("SHA-256", enc.encode(challenge + ":" + n)));
+		if (zeroBits(hash) >= difficulty) {
+			return String(n);
+		}
+	}
+}
+
+document.getElementById("report").addEventListener("submit", async (e) => {
+	e.preventDefault();
+	const status = document.getElementById("status");
+	status.textContent = "Sending report...";
+	const resp = await fetch("/api/reports", {
+		method: "POST",
+		headers: { "Content-Type": "application/json" },
+		body: JSON.stringify({
+			code: code,
+			reason: document.getElementById("reason").value,
+			details: document.getElementById("details").value,
+			challenge: challenge,
+			nonce: await solve(),
+		}),
+	});
+	status.textContent = resp.ok ? "Thank you, the link was reported." : "The report could not be sent, reload the page and try again.";
+});
+</script>
+</body>
+</html>
+`))
+
+// disabledPage is shown instead of redirecting for disabled shorturls.
+var disabledPage = []byte(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link disabled</title>
+<style>
+body { font-family: sans-serif; max-width: 720px; margin: 2em auto; padding: 0 1em; color: #24292f; }
+</style>
+</head>
+<body>
+<h1>This link has been disabled</h1>
+<p>It was reported and found to break the terms of use of this service.</p>
+</body>
+</html>
+`)
+
+type abuseGroup struct {
+	abuse        abuse.Abuse
+	shorturl     shorturl.Shorturl
+	difficulty   int
+	challengeAge time.Duration
+}
+
+// challenge issues a proof of work challenge for a report.
+func (ag abuseGroup) challenge(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	challenge, err := pow.NewChallenge(v.Now)
+	if err != nil {
+		return errors.Wrap(err, "issuing challenge")
+	}
+
+	data := struct {
+		Challenge  string    `json:"challenge"`
+		Difficulty int       `json:"difficulty"`
+		Expires    time.Time `json:"expires"`
+	}{
+		Challenge:  challenge,
+		Difficulty: ag.difficulty,
+		Expires:    v.Now.Add(ag.challengeAge).UTC(),
+	}
+
+	return web.Respond(ctx, w, data, http.StatusOK)
+}
+
+// report files an abuse report once its proof of work checks out.
+func (ag abuseGroup) report(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var nr abuse.NewReport
+	if err := web.Decode(r, &nr); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	if err := pow.Verify(nr.Challenge, nr.Nonce, ag.difficulty, ag.challengeAge, v.Now); err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	shorturlID, err := base62.Decode(nr.Code)
+	if err != nil {
+		return web.NewRequestError(abuse.ErrNotFound, http.StatusNotFound)
+	}
+
+	report, err := ag.abuse.Report(ctx, v.TraceID, shorturlID, nr, v.Now)
+	if err != nil {
+		switch errors.Cause(err) {
+		case abuse.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case abuse.ErrReplayed:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "Code: %s", nr.Code)
+		}
+	}
+
+	data := struct {
+		ID int `json:"id"`
+	}{
+		ID: report.ID,
+	}
+
+	return web.Respond(ctx, w, data, http.StatusCreated)
+}
+
+// preview renders the preview page of a shorturl. The destination is left
+// out for shorturls that must not reveal it.
+func (ag abuseGroup) preview(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return web.NewRequestError(shorturl.ErrNotFound, http.StatusNotFound)
+	}
+
+	surl, err := ag.shorturl.QueryInfo(ctx, v.TraceID, shorturlID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case shorturl.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "URL: %s", params["url"])
+		}
+	}
+
+	challenge, err := pow.NewChallenge(v.Now)
+	if err != nil {
+		return errors.Wrap(err, "issuing challenge")
+	}
+
+	data := struct {
+		Code       string
+		URL        string
+		Disabled   bool
+		Challenge  string
+		Difficulty int
+	}{
+		Code:       params["url"],
+		Disabled:   surl.Disabled,
+		Challenge:  challenge,
+		Difficulty: ag.difficulty,
+	}
+	hidden := surl.SignedOnly || surl.BurnAfterReading ||
+		shorturl.Window(surl.ActiveFrom, surl.ActiveUntil, v.Now.UTC()) != shorturl.WindowActive
+	if !hidden {
+		data.URL = surl.URL
+	}
+
+	var page bytes.Buffer
+	if err := previewPage.Execute(&page, data); err != nil {
+		return errors.Wrap(err, "rendering preview page")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	v.StatusCode = http.StatusOK
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := page.WriteTo(w); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// queue lists the shorturls waiting for moderation.
+func (ag abuseGroup) queue(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	pageNumber, err := strconv.Atoi(params["page"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid page format: %s", params["page"]), http.StatusBadRequest)
+	}
+	rowsPerPage, err := strconv.Atoi(params["rows"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid rows format: %s", params["rows"]), http.StatusBadRequest)
+	}
+
+	items, err := ag.abuse.Queue(ctx, v.TraceID, pageNumber, rowsPerPage)
+	if err != nil {
+		return errors.Wrap(err, "unable to query moderation queue")
+	}
+
+	return web.Respond(ctx, w, items, http.StatusOK)
+}
+
+// moderate takes a moderation action on a shorturl.
+func (ag abuseGroup) moderate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return errors.New("invalid url")
+	}
+
+	var m abuse.Moderation
+	if err := web.Decode(r, &m); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	if err := ag.abuse.Moderate(ctx, v.TraceID, claims.Subject, shorturlID, m, v.Now); err != nil {
+		switch errors.Cause(err) {
+		case abuse.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "URL: %s : Moderation: %+v", params["url"], &m)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// actions lists the moderation actions taken.
+func (ag abuseGroup) actions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	pageNumber, err := strconv.Atoi(params["page"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid page format: %s", params["page"]), http.StatusBadRequest)
+	}
+	rowsPerPage, err := strconv.Atoi(params["rows"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid rows format: %s", params["rows"]), http.StatusBadRequest)
+	}
+
+	actions, err := ag.abuse.QueryActions(ctx, v.TraceID, pageNumber, rowsPerPage)
+	if err != nil {
+		return errors.Wrap(err, "unable to query moderation actions")
+	}
+
+	return web.Respond(ctx, w, actions, http.StatusOK)
+}
+
+// disabled answers a visit to a disabled shorturl.
+func disabled(ctx context.Context, w http.ResponseWriter) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	v.StatusCode = http.StatusGone
+	w.WriteHeader(http.StatusGone)
+
+	if _, err := w.Write(disabledPage); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/data/anomaly"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+type alertGroup struct {
+	detector anomaly.Detector
+}
+
+func (ag alertGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	pageNumber, err := strconv.Atoi(params["page"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid page format: %s", params["page"]), http.StatusBadRequest)
+	}
+	rowsPerPage, err := strconv.Atoi(params["rows"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid rows format: %s", params["rows"]), http.StatusBadRequest)
+	}
+
+	alerts, err := ag.detector.Query(ctx, v.TraceID, pageNumber, rowsPerPage)
+	if err != nil {
+		return errors.Wrap(err, "unable to query for alerts")
+	}
+
+	return web.Respond(ctx, w, alerts, http.StatusOK)
+}
+
+func (ag alertGroup) queryWorkspaceRule(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	rule, err := ag.detector.QueryWorkspaceRule(ctx, v.TraceID)
+	if err != nil {
+		return errors.Wrap(err, "unable to query for workspace rule")
+	}
+
+	return web.Respond(ctx, w, rule, http.StatusOK)
+}
+
+func (ag alertGroup) updateWorkspaceRule(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var nr anomaly.NewRule
+	if err := web.Decode(r, &nr); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	rule, err := ag.detector.UpdateWorkspaceRule(ctx, v.TraceID, nr, v.Now)
+	if err != nil {
+		return errors.Wrapf(err, "Rule: %+v", &nr)
+	}
+
+	return web.Respond(ctx, w, rule, http.StatusOK)
+}
+
+func (ag alertGroup) queryRule(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return errors.New("invalid url")
+	}
+
+	rule, err := ag.detector.QueryRule(ctx, v.TraceID, shorturlID)
+	if err != nil {
+		switch errors.Cause(err) {
+		case anomaly.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "URL: %s", params["url"])
+		}
+	}
+
+	return web.Respond(ctx, w, rule, http.StatusOK)
+}
+
+func (ag alertGroup) saveRule(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return errors.New("invalid url")
+	}
+
+	var nr anomaly.NewRule
+	if err := web.Decode(r, &nr); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	rule, err := ag.detector.SaveRule(ctx, v.TraceID, shorturlID, nr, v.Now)
+	if err != nil {
+		switch errors.Cause(err) {
+		case anomaly.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "URL: %s", params["url"])
+		}
+	}
+
+	return web.Respond(ctx, w, rule, http.StatusOK)
+}
+
+func (ag alertGroup) deleteRule(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return errors.New("invalid url")
+	}
+
+	if err := ag.detector.DeleteRule(ctx, v.TraceID, shorturlID); err != nil {
+		return errors.Wrapf(err, "URL: %s", params["url"])
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/svg"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+// Set of parameters of visit count badges.
+const (
+	badgeLabel    = "clicks"
+	badgeColor    = "blue"
+	badgeMaxLabel = 40
+	badgeMaxAge   = time.Minute
+
+	// badgeCacheSize bounds the number of cached visit counts. Expired
+	// counts are swept once it is reached.
+	badgeCacheSize = 10000
+)
+
+type badgeGroup struct {
+	shorturl shorturl.Shorturl
+	counts   *visitCache
+}
+
+// badge renders the visit count of a shorturl with public stats as a
+// shields-style SVG badge. Disabled shorturls have no badge.
+func (bg badgeGroup) badge(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return web.NewRequestError(shorturl.ErrNotFound, http.StatusNotFound)
+	}
+
+	q := r.URL.Query()
+	label := q.Get("label")
+	if label == "" {
+		label = badgeLabel
+	}
+	if len(label) > badgeMaxLabel {
+		return web.NewRequestError(fmt.Errorf("label longer than %d characters", badgeMaxLabel), http.StatusBadRequest)
+	}
+	colorName := q.Get("color")
+	if colorName == "" {
+		colorName = badgeColor
+	}
+	color, ok := svg.BadgeColor(colorName)
+	if !ok {
+		return web.NewRequestError(fmt.Errorf("invalid color: %s", colorName), http.StatusBadRequest)
+	}
+
+	visits, err := bg.counts.get(v.Now, shorturlID, func() (int, error) {
+		surl, err := bg.shorturl.QueryInfo(ctx, v.TraceID, shorturlID)
+		if err != nil {
+			return 0, err
+		}
+		if !surl.PublicStats || surl.Disabled {
+			return 0, shorturl.ErrNotFound
+		}
+		return surl.Visits, nil
+	})
+	if err != nil {
+		switch errors.Cause(err) {
+		case shorturl.ErrNotFound:
+			return web.NewRequestError(shorturl.ErrNotFound, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "URL: %s", params["url"])
+		}
+	}
+
+	message := compactCount(visits)
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", label, message, color)
+	etag := fmt.Sprintf(`"%x"`, h.Sum64())
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(badgeMaxAge.Seconds())))
+
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		v.StatusCode = http.StatusNotModified
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+
+	v.StatusCode = http.StatusOK
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write([]byte(svg.Badge(label, message, color))); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// compactCount formats a count the way badges usually show it, like 999,
+// 1.2k or 3.4M. Counts are rounded before the unit is picked, so 999999 is
+// shown as 1.0M rather than 1000.0k.
+func compactCount(n int) string {
+	if n < 1000 {
+		return strconv.Itoa(n)
+	}
+
+	if k := math.Round(float64(n)/100) / 10; k < 1000 {
+		return strconv.FormatFloat(k, 'f', 1, 64) + "k"
+	}
+
+	return strconv.FormatFloat(math.Round(float64(n)/100000)/10, 'f', 1, 64) + "M"
+}
+
+// etagMatch reports whether the If-None-Match header lists the entity tag.
+func etagMatch(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// visitCache keeps the visit counts of shorturls for a short time so badges
+// embedded in busy pages do not reach the database on every request. Missing
+// and private shorturls are not cached, so requests for made up codes can't
+// fill the cache, and it never holds more than badgeCacheSize counts.
+type visitCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int]visitCacheEntry
+}
+
+// visitCacheEntry is a cached visit count.
+type visitCacheEntry struct {
+	visits  int
+	expires time.Time
+}
+
+// newVisitCache constructs a cache that keeps counts for the ttl.
+func newVisitCache(ttl time.Duration) *visitCache {
+	return &visitCache{
+		ttl:     ttl,
+		entries: make(map[int]visitCacheEntry),
+	}
+}
+
+// get returns the cached count of the shorturl, loading it when it is
+// missing or expired.
+func (vc *visitCache) get(now time.Time, shorturlID int, load func() (int, error)) (int, error) {
+	vc.mu.Lock()
+	e, ok := vc.entries[shorturlID]
+	vc.mu.Unlock()
+
+	if ok && now.Before(e.expires) {
+		return e.visits, nil
+	}
+
+	visits, err := load()
+	if err != nil {
+		return 0, err
+	}
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if len(vc.entries) >= badgeCacheSize {
+		for id, e := range vc.entries {
+			if !now.Before(e.expires) {
+				delete(vc.entries, id)
+			}
+		}
+
+		// Every count is still fresh, this one is served uncached.
+		if len(vc.entries) >= badgeCacheSize {
+			return visits, nil
+		}
+	}
+	vc.entries[shorturlID] = visitCacheEntry{
+		visits:  visits,
+		expires: now.Add(vc.ttl),
+	}
+
+	return visits, nil
+}
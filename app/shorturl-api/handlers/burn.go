@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http"
+
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+// confirmPage is the interstitial page of shorturls that burn after reading.
+// Link unfurlers fetch the page but don't submit it, so only a visitor
+// pressing the button burns the shorturl. The form posts back to the URI
+// that was visited so passthrough shorturls keep their path and query.
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>One-time link</title>
+<style>
+body { font-family: sans-serif; max-width: 720px; margin: 2em auto; padding: 0 1em; color: #24292f; }
+</style>
+</head>
+<body>
+<h1>One-time link</h1>
+<p>/{{.Code}} can only be opened once. It stops working after you continue.</p>
+<form method="post" action="{{.Action}}">
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// confirm renders the interstitial page of a shorturl that burns after
+// reading.
+func (sg shorturlGroup) confirm(ctx context.Context, w http.ResponseWriter, r *http.Request, code string) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	data := struct {
+		Code   string
+		Action string
+	}{
+		Code:   code,
+		Action: r.URL.RequestURI(),
+	}
+
+	var page bytes.Buffer
+	if err := confirmPage.Execute(&page, data); err != nil {
+		return errors.Wrap(err, "rendering confirm page")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	v.StatusCode = http.StatusOK
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := page.WriteTo(w); err != nil {
+		return err
+	}
+
+	return nil
+}
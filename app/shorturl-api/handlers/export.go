@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/export"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+// exportRange is the range of days exported when the request does not
+// specify one.
+const exportRange = 7 * 24 * time.Hour
+
+// defaultExportTimeout is how long an export may take to stream when the
+// configuration does not say.
+const defaultExportTimeout = 10 * time.Minute
+
+type exportGroup struct {
+	log      *log.Logger
+	shorturl shorturl.Shorturl
+	timeout  time.Duration
+}
+
+func (eg exportGroup) byShorturl(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return errors.New("invalid url")
+	}
+
+	return eg.export(ctx, w, r, shorturl.ExportFilter{ShorturlID: shorturlID}, params["url"])
+}
+
+func (eg exportGroup) byCampaign(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	campaign := web.Params(r)["campaign"]
+	return eg.export(ctx, w, r, shorturl.ExportFilter{Campaign: campaign}, campaign)
+}
+
+func (eg exportGroup) all(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return eg.export(ctx, w, r, shorturl.ExportFilter{}, "shorturls")
+}
+
+// export streams the clicks or daily visits matching the filter in the
+// requested format. The response is written as it is read from the database
+// and sent with chunked transfer encoding, so it is given the export timeout
+// rather than the write timeout of the server.
+func (eg exportGroup) export(ctx context.Context, w http.ResponseWriter, r *http.Request, filter shorturl.ExportFilter, name string) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	kind := web.Params(r)["kind"]
+	var header []string
+	switch kind {
+	case "clicks":
+		header = shorturl.ClickColumns
+	case "daily":
+		header = shorturl.DailyColumns
+	default:
+		return web.NewRequestError(fmt.Errorf("unknown export: %s", kind), http.StatusNotFound)
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if format != export.FormatCSV && format != export.FormatNDJSON {
+		return web.NewRequestError(fmt.Errorf("invalid format: %s", format), http.StatusBadRequest)
+	}
+
+	from, to, err := parseRange(r, v.Now, exportRange)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+	filter.From, filter.To = from, to
+
+	if err := web.ExtendWriteDeadline(ctx, time.Now().Add(eg.timeout)); err != nil {
+		return errors.Wrap(err, "extending write deadline")
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+		fmt.Sprintf("%s-%s-%s-%s.%s", name, kind, from.Format("20060102"), to.Format("20060102"), format)))
+	w.Header().Set("Cache-Control", "no-store")
+
+	ew, err := export.NewWriter(w, format, header)
+	if err != nil {
+		return errors.Wrapf(err, "Format: %s", format)
+	}
+
+	// Once streaming has started the status can no longer change. A failure
+	// half way aborts the connection, so the client sees a broken transfer
+	// rather than a complete looking file.
+	v.StatusCode = http.StatusOK
+	switch kind {
+	case "clicks":
+		err = eg.shorturl.ExportClicks(ctx, v.TraceID, filter, func(c shorturl.Click) error {
+			return ew.Write(c)
+		})
+	case "daily":
+		err = eg.shorturl.ExportDaily(ctx, v.TraceID, filter, func(d shorturl.DailyVisits) error {
+			return ew.Write(d)
+		})
+	}
+	if err == nil {
+		err = ew.Flush()
+	}
+	if err != nil {
+		eg.log.Printf("%s : ERROR     : Export: %s %s : %v", v.TraceID, name, kind, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
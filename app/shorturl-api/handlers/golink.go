@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mitrovicsinisaa/shorturl/business/auth"
+	"github.com/mitrovicsinisaa/shorturl/business/data/golink"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+// suggestLimit is the number of keywords returned for autocomplete.
+const suggestLimit = 10
+
+type golinkGroup struct {
+	golink golink.Golink
+}
+
+func (gg golinkGroup) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var ngl golink.NewGolink
+	if err := web.Decode(r, &ngl); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	gl, err := gg.golink.Create(ctx, v.TraceID, claims, ngl, v.Now)
+	if err != nil {
+		switch errors.Cause(err) {
+		case golink.ErrInvalidKeyword, golink.ErrMalicious:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case golink.ErrKeywordExists, golink.ErrKeywordIsCode:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "Golink: %+v", &ngl)
+		}
+	}
+
+	return web.Respond(ctx, w, gl, http.StatusCreated)
+}
+
+func (gg golinkGroup) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := gg.golink.Delete(ctx, v.TraceID, claims, params["keyword"]); err != nil {
+		switch errors.Cause(err) {
+		case golink.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case golink.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "Keyword: %s", params["keyword"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (gg golinkGroup) search(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	pageNumber, err := strconv.Atoi(params["page"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid page format: %s", params["page"]), http.StatusBadRequest)
+	}
+	rowsPerPage, err := strconv.Atoi(params["rows"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid rows format: %s", params["rows"]), http.StatusBadRequest)
+	}
+
+	golinks, err := gg.golink.Search(ctx, v.TraceID, r.URL.Query().Get("q"), pageNumber, rowsPerPage)
+	if err != nil {
+		return errors.Wrap(err, "unable to search for golinks")
+	}
+
+	return web.Respond(ctx, w, golinks, http.StatusOK)
+}
+
+func (gg golinkGroup) suggest(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	keywords, err := gg.golink.Suggest(ctx, v.TraceID, r.URL.Query().Get("q"), suggestLimit)
+	if err != nil {
+		return errors.Wrap(err, "unable to suggest golinks")
+	}
+
+	return web.Respond(ctx, w, keywords, http.StatusOK)
+}
@@ -3,20 +3,90 @@
 package handlers
 
 import (
+	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/mitrovicsinisaa/shorturl/business/alert"
 	"github.com/mitrovicsinisaa/shorturl/business/auth"
+	"github.com/mitrovicsinisaa/shorturl/business/broker"
+	"github.com/mitrovicsinisaa/shorturl/business/data/abuse"
+	"github.com/mitrovicsinisaa/shorturl/business/data/anomaly"
+	"github.com/mitrovicsinisaa/shorturl/business/data/golink"
+	"github.com/mitrovicsinisaa/shorturl/business/data/leaderboard"
 	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
 	"github.com/mitrovicsinisaa/shorturl/business/data/user"
+	"github.com/mitrovicsinisaa/shorturl/business/data/webhook"
+	"github.com/mitrovicsinisaa/shorturl/business/jobs"
 	"github.com/mitrovicsinisaa/shorturl/business/mid"
+	"github.com/mitrovicsinisaa/shorturl/business/proxy"
+	"github.com/mitrovicsinisaa/shorturl/business/reputation"
+	"github.com/mitrovicsinisaa/shorturl/business/signed"
 	"github.com/mitrovicsinisaa/shorturl/foundation/web"
 )
 
+// Config holds the deployment wide settings used by the handlers.
+type Config struct {
+
+	// RedirectCode is the status code used for shorturls that do not define
+	// their own. It defaults to 303 See Other.
+	RedirectCode int
+
+	// RedirectMaxAge is how long clients may cache permanent redirects.
+	RedirectMaxAge time.Duration
+
+	// GoLinks enables resolving go link keywords on the root route before
+	// shorturl codes.
+	GoLinks bool
+
+	// VisitorSalt is mixed into the visitor hashes used to count unique
+	// visitors. It must be the same on every instance of the service.
+	VisitorSalt string
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For header is
+	// believed when telling visitors apart.
+	TrustedProxies proxy.Trusted
+
+	// LiveDone is closed when the server starts shutting down, which ends the
+	// live click streams.
+	LiveDone <-chan struct{}
+
+	// ExportTimeout is how long a stats export may take to stream, in place
+	// of the write timeout of the server.
+	ExportTimeout time.Duration
+
+	// ComingSoonPage is shown for shorturls that are not active yet and have
+	// no pre-launch URL. It defaults to a plain page naming the launch time.
+	ComingSoonPage *template.Template
+
+	// Signer verifies signed shorturl codes and issues new ones. Signed
+	// codes are not found when it is nil.
+	Signer *signed.Signer
+
+	// ReportDifficulty is the number of leading zero bits the proof of work
+	// of an abuse report must have, and ReportChallengeAge how long its
+	// challenge stays valid.
+	ReportDifficulty   int
+	ReportChallengeAge time.Duration
+
+	// Reputation screens the destinations of new and updated shorturls, it
+	// is skipped when nil.
+	Reputation reputation.Checker
+}
+
+// liveBuffer is the number of visit events buffered per live subscriber
+// before events are dropped.
+const liveBuffer = 64
+
+// liveHistory is the number of recent visit events kept so reconnecting live
+// streams can replay what they missed.
+const liveHistory = 1024
+
 // API construct an http.Handler with all application routes defined.
-func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, db *sqlx.DB) *web.App {
+func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, db *sqlx.DB, cfg Config) *web.App {
 	app := web.NewApp(shutdown, mid.Logger(log), mid.Errors(log), mid.Metrics(), mid.Panics(log))
 
 	cg := checkGroup{
@@ -33,17 +103,158 @@ func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, d
 	}
 	app.Handle(http.MethodGet, "/api/token/:kid", ug.token)
 
+	// Visits are fanned out to live click streams through this broker.
+	b := broker.New(liveBuffer, liveHistory)
+
+	comingSoon := cfg.ComingSoonPage
+	if comingSoon == nil {
+		comingSoon = comingSoonPage
+	}
+
 	// Register user management and authentication endpoints.
 	sg := shorturlGroup{
-		shorturl: shorturl.New(log, db),
-		auth:     a,
+		shorturl:       shorturl.New(log, db, cfg.Reputation),
+		golink:         golink.New(log, db, cfg.Reputation),
+		auth:           a,
+		redirectCode:   cfg.RedirectCode,
+		redirectMaxAge: cfg.RedirectMaxAge,
+		goLinks:        cfg.GoLinks,
+		visitorSalt:    []byte(cfg.VisitorSalt),
+		proxies:        cfg.TrustedProxies,
+		broker:         b,
+		comingSoon:     comingSoon,
+		signer:         cfg.Signer,
 	}
 
 	app.Handle(http.MethodPost, "/api/shorturl", sg.create)
 	app.Handle(http.MethodGet, "/:url", sg.queryByID)
+	app.Handle(http.MethodPost, "/:url", sg.queryByID)
+	app.Handle(http.MethodGet, "/:url/*path", sg.queryByID)
+	app.Handle(http.MethodPost, "/:url/*path", sg.queryByID)
 	app.Handle(http.MethodGet, "/api/shorturl/:page/:rows", sg.query, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
 	app.Handle(http.MethodGet, "/api/shorturl/:url", sg.queryVisitation, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPut, "/api/shorturl/:url", sg.update, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
 	app.Handle(http.MethodDelete, "/api/shorturl/:url", sg.delete, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/campaigns", sg.queryCampaigns, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/campaigns/:campaign", sg.queryCampaign, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/campaigns/:campaign/uniques", sg.queryCampaignUniques, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/shorturl/:url/uniques", sg.queryUniques, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/api/shorturl/:url/sign", sg.sign, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/api/conversions", sg.createConversion, mid.Authenticate(a))
+
+	// Register the public stats page. Pages about a shorturl live under the
+	// /- prefix, which is never a shorturl code or go link keyword, so they
+	// don't shadow paths forwarded by passthrough shorturls.
+	stg := statsGroup{
+		shorturl: shorturl.New(log, db, nil),
+	}
+
+	app.Handle(http.MethodGet, "/-/:url/stats", stg.public)
+
+	// Register the visit count badge, served from a short lived cache of
+	// the visit counts.
+	bdg := badgeGroup{
+		shorturl: shorturl.New(log, db, nil),
+		counts:   newVisitCache(badgeMaxAge),
+	}
+
+	app.Handle(http.MethodGet, "/-/:url/badge.svg", bdg.badge)
+
+	// Register abuse reporting and moderation endpoints. Reports are public
+	// and paid for with a proof of work instead of a captcha.
+	abg := abuseGroup{
+		abuse:        abuse.New(log, db),
+		shorturl:     shorturl.New(log, db, nil),
+		difficulty:   cfg.ReportDifficulty,
+		challengeAge: cfg.ReportChallengeAge,
+	}
+	if abg.difficulty == 0 {
+		abg.difficulty = defaultReportDifficulty
+	}
+	if abg.challengeAge == 0 {
+		abg.challengeAge = defaultReportChallengeAge
+	}
+
+	app.Handle(http.MethodGet, "/-/:url/preview", abg.preview)
+	app.Handle(http.MethodGet, "/api/reports/challenge", abg.challenge)
+	app.Handle(http.MethodPost, "/api/reports", abg.report)
+	app.Handle(http.MethodGet, "/api/moderation/:page/:rows", abg.queue, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/moderation/actions/:page/:rows", abg.actions, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/api/moderation/:url", abg.moderate, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	// Register stats export endpoints.
+	eg := exportGroup{
+		log:      log,
+		shorturl: shorturl.New(log, db, nil),
+		timeout:  cfg.ExportTimeout,
+	}
+	if eg.timeout == 0 {
+		eg.timeout = defaultExportTimeout
+	}
+
+	app.Handle(http.MethodGet, "/api/export/:kind", eg.all, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/shorturl/:url/export/:kind", eg.byShorturl, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/campaigns/:campaign/export/:kind", eg.byCampaign, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	// Register live click stream endpoints.
+	lg := liveGroup{
+		broker: b,
+		done:   cfg.LiveDone,
+	}
+
+	app.Handle(http.MethodGet, "/api/live", lg.all, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/shorturl/:url/live", lg.shorturl, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	// Register leaderboard endpoints.
+	lbg := leaderboardGroup{
+		leaderboard: leaderboard.New(log, db),
+	}
+
+	app.Handle(http.MethodGet, "/api/leaderboard/:period", lbg.top, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/trending", lbg.trending, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	// Register anomaly alert endpoints. Alerts are raised by the background
+	// detector, the handlers only manage rules and list what was raised.
+	ag := alertGroup{
+		detector: anomaly.New(log, db, alert.NewLog(log)),
+	}
+
+	app.Handle(http.MethodGet, "/api/alerts/:page/:rows", ag.query, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/alerts/rule", ag.queryWorkspaceRule, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPut, "/api/alerts/rule", ag.updateWorkspaceRule, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/shorturl/:url/alerts", ag.queryRule, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPut, "/api/shorturl/:url/alerts", ag.saveRule, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/api/shorturl/:url/alerts", ag.deleteRule, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	// Register webhook subscription endpoints. Deliveries are sent by the
+	// job workers.
+	wg := webhookGroup{
+		webhook: webhook.New(log, db),
+	}
+
+	app.Handle(http.MethodPost, "/api/webhooks", wg.create, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/webhooks", wg.query, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/api/webhooks/:id", wg.delete, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/api/webhooks/:id/deliveries/:page/:rows", wg.queryDeliveries, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	// Register background job endpoints. Jobs that ran out of attempts stay
+	// dead lettered until they are retried here.
+	jg := jobGroup{
+		jobs: jobs.New(log, db),
+	}
+
+	app.Handle(http.MethodGet, "/api/jobs/:page/:rows", jg.query, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/api/jobs/:id/retry", jg.retry, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	// Register go links management endpoints.
+	gg := golinkGroup{
+		golink: golink.New(log, db, cfg.Reputation),
+	}
+
+	app.Handle(http.MethodPost, "/api/golinks", gg.create, mid.Authenticate(a))
+	app.Handle(http.MethodGet, "/api/golinks/suggest", gg.suggest, mid.Authenticate(a))
+	app.Handle(http.MethodGet, "/api/golinks/:page/:rows", gg.search, mid.Authenticate(a))
+	app.Handle(http.MethodDelete, "/api/golinks/:keyword", gg.delete, mid.Authenticate(a))
 
 	return app
 }
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mitrovicsinisaa/shorturl/business/jobs"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+type jobGroup struct {
+	jobs jobs.Jobs
+}
+
+func (jg jobGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	pageNumber, err := strconv.Atoi(params["page"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid page format: %s", params["page"]), http.StatusBadRequest)
+	}
+	rowsPerPage, err := strconv.Atoi(params["rows"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid rows format: %s", params["rows"]), http.StatusBadRequest)
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = jobs.StatusDead
+	case jobs.StatusPending, jobs.StatusDead:
+	default:
+		return web.NewRequestError(fmt.Errorf("invalid status: %s", status), http.StatusBadRequest)
+	}
+
+	list, err := jg.jobs.Query(ctx, v.TraceID, status, pageNumber, rowsPerPage)
+	if err != nil {
+		return errors.Wrapf(err, "Status: %s", status)
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (jg jobGroup) retry(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	id, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid id format: %s", params["id"]), http.StatusBadRequest)
+	}
+
+	if err := jg.jobs.Retry(ctx, v.TraceID, id, v.Now); err != nil {
+		switch errors.Cause(err) {
+		case jobs.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %d", id)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/data/leaderboard"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+// Set of limits for the number of ranked shorturls returned.
+const (
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+)
+
+type leaderboardGroup struct {
+	leaderboard leaderboard.Leaderboard
+}
+
+// leaderboardEntry is a ranked shorturl together with its short code.
+type leaderboardEntry struct {
+	leaderboard.Entry
+	Code string `json:"code"`
+}
+
+func (lg leaderboardGroup) top(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return lg.query(ctx, w, r, web.Params(r)["period"])
+}
+
+func (lg leaderboardGroup) trending(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return lg.query(ctx, w, r, leaderboard.Trending)
+}
+
+func (lg leaderboardGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request, period string) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	limit := defaultLeaderboardLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxLeaderboardLimit {
+			return web.NewRequestError(fmt.Errorf("invalid limit: %s", s), http.StatusBadRequest)
+		}
+	}
+
+	entries, err := lg.leaderboard.Query(ctx, v.TraceID, period, limit)
+	if err != nil {
+		switch errors.Cause(err) {
+		case leaderboard.ErrUnknownPeriod:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "Period: %s", period)
+		}
+	}
+
+	ranked := make([]leaderboardEntry, len(entries))
+	for i, e := range entries {
+		ranked[i] = leaderboardEntry{
+			Entry: e,
+			Code:  base62.Encode(e.ShorturlID),
+		}
+	}
+
+	return web.Respond(ctx, w, ranked, http.StatusOK)
+}
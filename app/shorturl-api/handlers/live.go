@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/broker"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+// Set of parameters of live click streams.
+const (
+
+	// liveHeartbeat is how often a comment is sent on idle streams so
+	// proxies and clients can tell them from dead connections.
+	liveHeartbeat = 15 * time.Second
+
+	// liveWriteTimeout is how long each write of a stream may take. The
+	// write deadline is moved forward before every write, in place of the
+	// write timeout of the server, so it must exceed liveHeartbeat.
+	liveWriteTimeout = 2 * liveHeartbeat
+)
+
+type liveGroup struct {
+	broker *broker.Broker
+
+	// done ends every stream when closed. Streams otherwise last until the
+	// client leaves.
+	done <-chan struct{}
+}
+
+func (lg liveGroup) shorturl(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return errors.New("invalid url")
+	}
+
+	return lg.stream(ctx, w, r, shorturlID)
+}
+
+func (lg liveGroup) all(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return lg.stream(ctx, w, r, 0)
+}
+
+// stream pushes the visits of the specified shorturl, or of every shorturl
+// for an ID of zero, as Server-Sent Events. Visits missed since the event in
+// the Last-Event-ID header are replayed while the broker still keeps them,
+// for clients reconnecting after a dropped connection or a restart.
+func (lg liveGroup) stream(ctx context.Context, w http.ResponseWriter, r *http.Request, shorturlID int) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("streaming unsupported")
+	}
+
+	// A malformed Last-Event-ID is treated as a fresh connection.
+	lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	sub := lg.broker.Subscribe(shorturlID, lastID)
+	defer lg.broker.Unsubscribe(sub)
+
+	// write sends a message once the write deadline is moved forward. A
+	// failed write means the client is gone.
+	write := func(format string, a ...interface{}) error {
+		if err := web.ExtendWriteDeadline(ctx, time.Now().Add(liveWriteTimeout)); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, format, a...); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if err := web.ExtendWriteDeadline(ctx, time.Now().Add(liveWriteTimeout)); err != nil {
+		return errors.Wrap(err, "extending write deadline")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	v.StatusCode = http.StatusOK
+	w.WriteHeader(http.StatusOK)
+
+	if err := write("retry: %d\n\n", time.Second.Milliseconds()); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+
+	var dropped uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-lg.done:
+			return nil
+
+		case <-heartbeat.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return nil
+			}
+
+		case e := <-sub.C:
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if err := write("id: %d\nevent: visit\ndata: %s\n\n", e.ID, data); err != nil {
+				return nil
+			}
+
+			// Let the client know when events were dropped because it did
+			// not keep up.
+			if d := sub.Dropped(); d != dropped {
+				dropped = d
+				if err := write("event: dropped\ndata: {\"dropped\":%d}\n\n", d); err != nil {
+					return nil
+				}
+			}
+		}
+	}
+}
@@ -3,19 +3,45 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"html/template"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mitrovicsinisaa/shorturl/business/auth"
 	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/bot"
+	"github.com/mitrovicsinisaa/shorturl/business/broker"
+	"github.com/mitrovicsinisaa/shorturl/business/data/golink"
 	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/proxy"
+	"github.com/mitrovicsinisaa/shorturl/business/signed"
+	"github.com/mitrovicsinisaa/shorturl/business/useragent"
 	"github.com/mitrovicsinisaa/shorturl/foundation/web"
 	"github.com/pkg/errors"
 )
 
+// uniquesRange is the range of days reported for unique visitors when the
+// request does not specify one.
+const uniquesRange = 30 * 24 * time.Hour
+
+// variantCookieAge is how long a visitor stays bound to a variant of a
+// sticky shorturl.
+const variantCookieAge = 30 * 24 * time.Hour
+
 type shorturlGroup struct {
-	shorturl shorturl.Shorturl
-	auth     *auth.Auth
+	shorturl       shorturl.Shorturl
+	golink         golink.Golink
+	auth           *auth.Auth
+	redirectCode   int
+	redirectMaxAge time.Duration
+	goLinks        bool
+	visitorSalt    []byte
+	proxies        proxy.Trusted
+	broker         *broker.Broker
+	comingSoon     *template.Template
+	signer         *signed.Signer
 }
 
 func (sg shorturlGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -34,7 +60,12 @@ func (sg shorturlGroup) query(ctx context.Context, w http.ResponseWriter, r *htt
 		return web.NewRequestError(fmt.Errorf("invalid rows format: %s", params["rows"]), http.StatusBadRequest)
 	}
 
-	shorturls, err := sg.shorturl.Query(ctx, v.TraceID, pageNumber, rowsPerPage)
+	filter := shorturl.QueryFilter{
+		Campaign: r.URL.Query().Get("campaign"),
+		Broken:   r.URL.Query().Get("broken") == "true",
+	}
+
+	shorturls, err := sg.shorturl.Query(ctx, v.TraceID, filter, pageNumber, rowsPerPage)
 	if err != nil {
 		return errors.Wrap(err, "unable to query for shorturls")
 	}
@@ -49,23 +80,204 @@ func (sg shorturlGroup) queryByID(ctx context.Context, w http.ResponseWriter, r
 	}
 
 	params := web.Params(r)
+	isSigned := sg.signer != nil && sg.signer.IsSigned(params["url"])
 
-	shorturlID, err := base62.Decode(params["url"])
-	if err != nil {
-		return errors.New("invalid url")
+	// Signed codes are verified before the shorturl is looked up, so forged
+	// and expired codes never reach the database.
+	var shorturlID int
+	var expires time.Time
+	var err error
+	if isSigned {
+		shorturlID, expires, err = sg.signer.Verify(params["url"], v.Now)
+		switch err {
+		case nil:
+		case signed.ErrExpired:
+			return web.NewRequestError(err, http.StatusGone)
+		default:
+			return web.NewRequestError(shorturl.ErrNotFound, http.StatusNotFound)
+		}
+	} else {
+		shorturlID, err = base62.Decode(params["url"])
+		if err != nil {
+			if sg.goLinks {
+				return sg.queryGolink(ctx, w, r, params["url"])
+			}
+			return errors.New("invalid url")
+		}
 	}
 
-	surl, err := sg.shorturl.QueryByID(ctx, v.TraceID, shorturlID)
+	// A visitor of a sticky shorturl keeps being sent to the variant that was
+	// picked on the first visit.
+	nv := shorturl.NewVisit{
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		Confirmed: r.Method == http.MethodPost,
+		Preview:   r.Method == http.MethodHead,
+		Signed:    isSigned,
+	}
+	nv.Bot, _ = bot.Detect(r)
+	nv.Visitor = shorturl.VisitorHash(sg.visitorSalt, sg.proxies.ClientIP(r), r.UserAgent())
+	if c, err := r.Cookie(variantCookie(params["url"])); err == nil {
+		nv.VariantID, _ = strconv.Atoi(c.Value)
+	}
+
+	// Shorturl codes take precedence over go link keywords, so a keyword
+	// can never take over the visits of an existing shorturl.
+	dest, err := sg.shorturl.QueryByID(ctx, v.TraceID, shorturlID, nv, v.Now)
 	if err != nil {
 		switch err {
 		case shorturl.ErrNotFound:
+			if sg.goLinks && !isSigned {
+				return sg.queryGolink(ctx, w, r, params["url"])
+			}
 			return web.NewRequestError(err, http.StatusNotFound)
+		case shorturl.ErrBurned:
+			return web.NewRequestError(err, http.StatusGone)
 		default:
 			return errors.Wrapf(err, "URL: %s", params["url"])
 		}
 	}
 
-	http.Redirect(w, r, surl.URL, http.StatusSeeOther)
+	if dest.Disabled {
+		return disabled(ctx, w)
+	}
+	if dest.Window != shorturl.WindowActive {
+		return sg.inactive(ctx, w, r, params["url"], dest)
+	}
+	if dest.Confirm {
+		return sg.confirm(ctx, w, r, params["url"])
+	}
+
+	sg.broker.Publish(broker.Event{
+		ShorturlID: dest.ShorturlID,
+		Code:       params["url"],
+		Time:       v.Now.UTC(),
+		Referrer:   shorturl.ReferrerDomain(nv.Referrer),
+		Device:     useragent.Parse(nv.UserAgent).Device,
+		Bot:        nv.Bot,
+	})
+
+	if dest.Sticky && dest.VariantID != 0 {
+		http.SetCookie(w, &http.Cookie{
+			Name:     variantCookie(params["url"]),
+			Value:    strconv.Itoa(dest.VariantID),
+			Path:     "/" + params["url"],
+			MaxAge:   int(variantCookieAge.Seconds()),
+			HttpOnly: true,
+		})
+	}
+
+	target := dest.URL
+	if dest.Passthrough {
+		target, err = shorturl.Forward(dest.URL, params["path"], r.URL.Query(), dest.QueryPolicy)
+		if err != nil {
+			return errors.Wrapf(err, "URL: %s", params["url"])
+		}
+	}
+	if dest.ClickID != "" {
+		target, err = shorturl.AppendClickID(target, dest.ClickID)
+		if err != nil {
+			return errors.Wrapf(err, "URL: %s", params["url"])
+		}
+	}
+
+	code := dest.RedirectCode
+	if code == 0 {
+		code = sg.redirectCode
+	}
+	if code == 0 {
+		code = http.StatusSeeOther
+	}
+
+	// A fallback only lasts until the destination recovers and a burnt
+	// shorturl is gone, so neither is ever redirected to permanently.
+	if dest.Fallback || dest.BurnAfterReading {
+		code = http.StatusFound
+	}
+
+	// Permanent redirects may be cached by clients, temporary ones must reach
+	// us on every visit so they are counted. A cached click ID would credit
+	// every later conversion to the first click.
+	switch {
+	case dest.ClickID != "", dest.Fallback, dest.BurnAfterReading:
+		w.Header().Set("Cache-Control", "no-store")
+	case code == http.StatusMovedPermanently, code == http.StatusPermanentRedirect:
+
+		// A cached redirect must not outlive the activation window or the
+		// signed code it was reached through.
+		maxAge := sg.redirectMaxAge
+		if dest.ActiveUntil != nil {
+			if left := dest.ActiveUntil.Sub(v.Now); left < maxAge {
+				maxAge = left
+			}
+		}
+		if !expires.IsZero() {
+			if left := expires.Sub(v.Now); left < maxAge {
+				maxAge = left
+			}
+		}
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	default:
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	v.StatusCode = code
+	http.Redirect(w, r, target, code)
+
+	return nil
+}
+
+// queryGolink redirects to the go link with the keyword.
+func (sg shorturlGroup) queryGolink(ctx context.Context, w http.ResponseWriter, r *http.Request, keyword string) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	gl, err := sg.golink.QueryByKeyword(ctx, v.TraceID, keyword)
+	if err != nil {
+		switch errors.Cause(err) {
+		case golink.ErrNotFound:
+			return web.NewRequestError(shorturl.ErrNotFound, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "Keyword: %s", keyword)
+		}
+	}
+
+	return sg.redirectGolink(ctx, w, r, gl)
+}
+
+// redirectGolink expands the template of a go link with the path segments and
+// query parameters of the request and redirects to the result.
+func (sg shorturlGroup) redirectGolink(ctx context.Context, w http.ResponseWriter, r *http.Request, gl golink.Info) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var positional []string
+	if path := strings.Trim(web.Params(r)["path"], "/"); path != "" {
+		positional = strings.Split(path, "/")
+	}
+
+	target, err := golink.Expand(gl.Template, positional, r.URL.Query())
+	if err != nil {
+		switch errors.Cause(err) {
+		case golink.ErrMissingParam:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "Keyword: %s", gl.Keyword)
+		}
+	}
+
+	code := sg.redirectCode
+	if code == 0 {
+		code = http.StatusSeeOther
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	v.StatusCode = code
+	http.Redirect(w, r, target, code)
 
 	return nil
 }
@@ -83,7 +295,12 @@ func (sg shorturlGroup) create(ctx context.Context, w http.ResponseWriter, r *ht
 
 	surl, err := sg.shorturl.Create(ctx, v.TraceID, nsu, v.Now)
 	if err != nil {
-		return errors.Wrapf(err, "Shorturl: %+v", &surl)
+		switch errors.Cause(err) {
+		case shorturl.ErrInvalidWindow, shorturl.ErrMalicious, shorturl.ErrInvalidDestination:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "Shorturl: %+v", &surl)
+		}
 	}
 
 	data := struct {
@@ -95,6 +312,121 @@ func (sg shorturlGroup) create(ctx context.Context, w http.ResponseWriter, r *ht
 	return web.Respond(ctx, w, data, http.StatusCreated)
 }
 
+// sign issues a signed code for a shorturl, optionally expiring at the
+// requested time.
+func (sg shorturlGroup) sign(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	if sg.signer == nil {
+		return web.NewRequestError(errors.New("signing keys are not configured"), http.StatusNotImplemented)
+	}
+
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return errors.New("invalid url")
+	}
+
+	var nc signed.NewCode
+	if err := web.Decode(r, &nc); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	var expires time.Time
+	if nc.ExpiresAt != nil {
+		expires = *nc.ExpiresAt
+		if !expires.After(v.Now) {
+			return web.NewRequestError(errors.New("expires_at must be in the future"), http.StatusBadRequest)
+		}
+	}
+
+	if _, err := sg.shorturl.QueryInfo(ctx, v.TraceID, shorturlID); err != nil {
+		switch errors.Cause(err) {
+		case shorturl.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "URL: %s", params["url"])
+		}
+	}
+
+	data := struct {
+		ShortUrl  string     `json:"shorturl"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	}{
+		ShortUrl:  r.Host + "/" + sg.signer.Sign(shorturlID, expires),
+		ExpiresAt: nc.ExpiresAt,
+	}
+
+	return web.Respond(ctx, w, data, http.StatusCreated)
+}
+
+func (sg shorturlGroup) createConversion(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var nc shorturl.NewConversion
+	if err := web.Decode(r, &nc); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	c, err := sg.shorturl.CreateConversion(ctx, v.TraceID, nc, v.Now)
+	if err != nil {
+		switch errors.Cause(err) {
+		case shorturl.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case shorturl.ErrConversionExists:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "Conversion: %+v", &nc)
+		}
+	}
+
+	return web.Respond(ctx, w, c, http.StatusCreated)
+}
+
+func (sg shorturlGroup) update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return errors.New("invalid url")
+	}
+
+	var usu shorturl.UpdateShorturl
+	if err := web.Decode(r, &usu); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	if err := sg.shorturl.Update(ctx, v.TraceID, claims, shorturlID, usu, v.Now); err != nil {
+		switch errors.Cause(err) {
+		case shorturl.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case shorturl.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		case shorturl.ErrInvalidWindow, shorturl.ErrMalicious, shorturl.ErrInvalidDestination:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "URL: %s : Shorturl: %+v", params["url"], &usu)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
 func (sg shorturlGroup) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	v, ok := ctx.Value(web.KeyValues).(*web.Values)
 	if !ok {
@@ -112,7 +444,7 @@ func (sg shorturlGroup) delete(ctx context.Context, w http.ResponseWriter, r *ht
 		return errors.New("invalid url")
 	}
 
-	err = sg.shorturl.Delete(ctx, v.TraceID, claims, shorturlID)
+	err = sg.shorturl.Delete(ctx, v.TraceID, claims, shorturlID, v.Now)
 	if err != nil {
 		switch errors.Cause(err) {
 		case shorturl.ErrNotFound:
@@ -144,7 +476,16 @@ func (sg shorturlGroup) queryVisitation(ctx context.Context, w http.ResponseWrit
 		return errors.New("invalid url")
 	}
 
-	surl, err := sg.shorturl.QueryVisitation(ctx, v.TraceID, claims, shorturlID)
+	bots := r.URL.Query().Get("bots")
+	switch bots {
+	case "":
+		bots = shorturl.BotsExclude
+	case shorturl.BotsExclude, shorturl.BotsInclude, shorturl.BotsOnly:
+	default:
+		return web.NewRequestError(fmt.Errorf("invalid bots filter: %s", bots), http.StatusBadRequest)
+	}
+
+	surl, err := sg.shorturl.QueryVisitation(ctx, v.TraceID, claims, shorturlID, bots)
 	if err != nil {
 		switch errors.Cause(err) {
 		case shorturl.ErrNotFound:
@@ -158,3 +499,117 @@ func (sg shorturlGroup) queryVisitation(ctx context.Context, w http.ResponseWrit
 
 	return web.Respond(ctx, w, surl, http.StatusOK)
 }
+
+// variantCookie returns the name of the cookie that binds a visitor to a
+// variant of the specified shorturl.
+func variantCookie(url string) string {
+	return "shorturl_" + url
+}
+
+func (sg shorturlGroup) queryCampaigns(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	campaigns, err := sg.shorturl.QueryCampaigns(ctx, v.TraceID)
+	if err != nil {
+		return errors.Wrap(err, "unable to query for campaigns")
+	}
+
+	return web.Respond(ctx, w, campaigns, http.StatusOK)
+}
+
+func (sg shorturlGroup) queryCampaign(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	campaign, err := sg.shorturl.QueryCampaign(ctx, v.TraceID, params["campaign"])
+	if err != nil {
+		switch errors.Cause(err) {
+		case shorturl.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "Campaign: %s", params["campaign"])
+		}
+	}
+
+	return web.Respond(ctx, w, campaign, http.StatusOK)
+}
+
+func (sg shorturlGroup) queryUniques(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return errors.New("invalid url")
+	}
+
+	from, to, err := parseRange(r, v.Now, uniquesRange)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	uniques, err := sg.shorturl.QueryUniques(ctx, v.TraceID, shorturlID, from, to)
+	if err != nil {
+		return errors.Wrapf(err, "URL: %s", params["url"])
+	}
+
+	return web.Respond(ctx, w, uniques, http.StatusOK)
+}
+
+func (sg shorturlGroup) queryCampaignUniques(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	from, to, err := parseRange(r, v.Now, uniquesRange)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	params := web.Params(r)
+	uniques, err := sg.shorturl.QueryCampaignUniques(ctx, v.TraceID, params["campaign"], from, to)
+	if err != nil {
+		return errors.Wrapf(err, "Campaign: %s", params["campaign"])
+	}
+
+	return web.Respond(ctx, w, uniques, http.StatusOK)
+}
+
+// parseRange reads the from and to days of the request in YYYY-MM-DD form.
+// Missing values default to the span ending today.
+func parseRange(r *http.Request, now time.Time, span time.Duration) (time.Time, time.Time, error) {
+	to := now.UTC().Truncate(24 * time.Hour)
+	from := to.Add(-span)
+
+	q := r.URL.Query()
+	if s := q.Get("from"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from format: %s", s)
+		}
+		from = t
+	}
+	if s := q.Get("to"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to format: %s", s)
+		}
+		to = t
+	}
+
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+
+	return from, to, nil
+}
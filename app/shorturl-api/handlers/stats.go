@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/svg"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+// Set of parameters of the public stats page.
+const (
+	publicStatsRange     = 29 * 24 * time.Hour
+	publicStatsReferrers = 10
+	publicStatsMaxAge    = 5 * time.Minute
+)
+
+// publicStatsPage is the page shown at /-/:url/stats for shorturls with public
+// stats.
+var publicStatsPage = template.Must(template.New("stats").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Stats for /{{.Code}}</title>
+<style>
+body { font-family: sans-serif; max-width: 720px; margin: 2em auto; padding: 0 1em; color: #24292f; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: 4px 0; border-bottom: 1px solid #d0d7de; }
+td.visits { text-align: right; }
+</style>
+</head>
+<body>
+<h1>/{{.Code}}</h1>
+<p><strong>{{.Stats.Visits}}</strong> visits in total</p>
+<h2>Daily visits</h2>
+<p>{{.From}} to {{.To}}</p>
+{{.Chart}}
+<h2>Top referrers</h2>
+{{if .Stats.Referrers}}<table>
+{{range .Stats.Referrers}}<tr><td>{{.Name}}</td><td class="visits">{{.Visits}}</td></tr>
+{{end}}</table>{{else}}<p>No visits yet.</p>{{end}}
+</body>
+</html>
+`))
+
+type statsGroup struct {
+	shorturl shorturl.Shorturl
+}
+
+// public renders the stats page of a shorturl whose owner made its stats
+// public. Every other shorturl is reported as not found.
+func (stg statsGroup) public(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	shorturlID, err := base62.Decode(params["url"])
+	if err != nil {
+		return web.NewRequestError(shorturl.ErrNotFound, http.StatusNotFound)
+	}
+
+	to := v.Now.UTC()
+	from := to.Add(-publicStatsRange)
+
+	stats, err := stg.shorturl.QueryPublicStats(ctx, v.TraceID, shorturlID, from, to)
+	if err != nil {
+		switch errors.Cause(err) {
+		case shorturl.ErrNotFound:
+			return web.NewRequestError(shorturl.ErrNotFound, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "URL: %s", params["url"])
+		}
+	}
+
+	if len(stats.Referrers) > publicStatsReferrers {
+		stats.Referrers = stats.Referrers[:publicStatsReferrers]
+	}
+
+	bars := make([]svg.Bar, len(stats.Days))
+	for i, d := range stats.Days {
+		bars[i] = svg.Bar{
+			Label: d.Day.Format("2006-01-02"),
+			Value: d.Visits,
+		}
+	}
+
+	data := struct {
+		Code  string
+		From  string
+		To    string
+		Stats shorturl.PublicStats
+		Chart template.HTML
+	}{
+		Code:  params["url"],
+		From:  from.Format("2006-01-02"),
+		To:    to.Format("2006-01-02"),
+		Stats: stats,
+		Chart: template.HTML(svg.BarChart(bars, 680, 160)),
+	}
+
+	var page bytes.Buffer
+	if err := publicStatsPage.Execute(&page, data); err != nil {
+		return errors.Wrap(err, "rendering stats page")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(publicStatsMaxAge.Seconds())))
+
+	v.StatusCode = http.StatusOK
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := page.WriteTo(w); err != nil {
+		return err
+	}
+
+	return nil
+}
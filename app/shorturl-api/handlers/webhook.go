@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mitrovicsinisaa/shorturl/business/data/webhook"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+type webhookGroup struct {
+	webhook webhook.Webhook
+}
+
+func (wg webhookGroup) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var ns webhook.NewSubscription
+	if err := web.Decode(r, &ns); err != nil {
+		return errors.Wrap(err, "unable to decode payload")
+	}
+
+	sub, err := wg.webhook.Create(ctx, v.TraceID, ns, v.Now)
+	if err != nil {
+		return errors.Wrapf(err, "Subscription: %+v", &ns)
+	}
+
+	// The secret is only ever shown here.
+	data := struct {
+		webhook.Info
+		Secret string `json:"secret"`
+	}{
+		Info:   sub,
+		Secret: sub.Secret,
+	}
+
+	return web.Respond(ctx, w, data, http.StatusCreated)
+}
+
+func (wg webhookGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	subs, err := wg.webhook.Query(ctx, v.TraceID)
+	if err != nil {
+		return errors.Wrap(err, "unable to query for subscriptions")
+	}
+
+	return web.Respond(ctx, w, subs, http.StatusOK)
+}
+
+func (wg webhookGroup) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid id format: %s", params["id"]), http.StatusBadRequest)
+	}
+
+	if err := wg.webhook.Delete(ctx, v.TraceID, id); err != nil {
+		return errors.Wrapf(err, "ID: %d", id)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (wg webhookGroup) queryDeliveries(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid id format: %s", params["id"]), http.StatusBadRequest)
+	}
+	pageNumber, err := strconv.Atoi(params["page"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid page format: %s", params["page"]), http.StatusBadRequest)
+	}
+	rowsPerPage, err := strconv.Atoi(params["rows"])
+	if err != nil {
+		return web.NewRequestError(fmt.Errorf("invalid rows format: %s", params["rows"]), http.StatusBadRequest)
+	}
+
+	deliveries, err := wg.webhook.QueryDeliveries(ctx, v.TraceID, id, pageNumber, rowsPerPage)
+	if err != nil {
+		return errors.Wrapf(err, "ID: %d", id)
+	}
+
+	return web.Respond(ctx, w, deliveries, http.StatusOK)
+}
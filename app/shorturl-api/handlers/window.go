@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
+	"github.com/pkg/errors"
+)
+
+// errEnded is reported for shorturls past their activation window that have
+// nowhere left to send visitors.
+var errEnded = errors.New("shorturl is no longer active")
+
+// comingSoonPage is the page shown for shorturls that are not active yet and
+// have no pre-launch URL, unless the deployment configures its own.
+var comingSoonPage = template.Must(template.New("soon").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Coming soon</title>
+<style>
+body { font-family: sans-serif; max-width: 720px; margin: 2em auto; padding: 0 1em; color: #24292f; }
+</style>
+</head>
+<body>
+<h1>Coming soon</h1>
+<p>/{{.Code}} goes live on {{.ActiveFrom}}.</p>
+</body>
+</html>
+`))
+
+// inactive answers a visit to a shorturl outside of its activation window.
+// Nothing about it may be cached, the answer changes once the window opens.
+func (sg shorturlGroup) inactive(ctx context.Context, w http.ResponseWriter, r *http.Request, code string, dest shorturl.Destination) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if dest.URL != "" {
+		v.StatusCode = http.StatusFound
+		http.Redirect(w, r, dest.URL, http.StatusFound)
+		return nil
+	}
+
+	if dest.Window == shorturl.WindowEnded {
+		return web.NewRequestError(errEnded, http.StatusGone)
+	}
+
+	data := struct {
+		Code       string
+		ActiveFrom string
+	}{
+		Code:       code,
+		ActiveFrom: dest.ActiveFrom.UTC().Format(time.RFC1123),
+	}
+
+	var page bytes.Buffer
+	if err := sg.comingSoon.Execute(&page, data); err != nil {
+		return errors.Wrap(err, "rendering coming soon page")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	v.StatusCode = http.StatusOK
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := page.WriteTo(w); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rsa"
 	"expvar"
 	"fmt"
+	"html/template"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -17,8 +18,20 @@ import (
 	"github.com/ardanlabs/conf"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/mitrovicsinisaa/shorturl/app/shorturl-api/handlers"
+	"github.com/mitrovicsinisaa/shorturl/business/alert"
 	"github.com/mitrovicsinisaa/shorturl/business/auth"
+	"github.com/mitrovicsinisaa/shorturl/business/data/abuse"
+	"github.com/mitrovicsinisaa/shorturl/business/data/anomaly"
+	"github.com/mitrovicsinisaa/shorturl/business/data/health"
+	"github.com/mitrovicsinisaa/shorturl/business/data/leaderboard"
+	"github.com/mitrovicsinisaa/shorturl/business/data/webhook"
+	"github.com/mitrovicsinisaa/shorturl/business/jobs"
+	"github.com/mitrovicsinisaa/shorturl/business/proxy"
+	"github.com/mitrovicsinisaa/shorturl/business/reputation"
+	"github.com/mitrovicsinisaa/shorturl/business/scheduler"
+	"github.com/mitrovicsinisaa/shorturl/business/signed"
 	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/mitrovicsinisaa/shorturl/foundation/web"
 	"github.com/pkg/errors"
 )
 
@@ -53,12 +66,64 @@ func run(log *log.Logger) error {
 			ReadTimeout     time.Duration `conf:"default:5s"`
 			WriteTimeout    time.Duration `conf:"default:5s"`
 			ShutdownTimeout time.Duration `conf:"default:5s"`
+			RedirectCode    int           `conf:"default:303"`
+			RedirectMaxAge  time.Duration `conf:"default:24h"`
+			GoLinks         bool          `conf:"default:false"`
+			ExportTimeout   time.Duration `conf:"default:10m"`
+			TrustedProxies  []string
+			ComingSoonPage  string
 		}
 		Auth struct {
 			KeyID          string `conf:"default:01aad0ee-cee2-11eb-b8bc-0242ac130003"`
 			PrivateKeyFile string `conf:"default:/shorturl/private.pem"`
 			Algorithm      string `conf:"default:RS256"`
 		}
+		Stats struct {
+			VisitorSalt     string        `conf:"default:develop,mask"`
+			RefreshInterval time.Duration `conf:"default:5m"`
+		}
+		Scheduler struct {
+			PollInterval time.Duration `conf:"default:5s"`
+			Timeout      time.Duration `conf:"default:5m"`
+		}
+		Jobs struct {
+			Workers      int           `conf:"default:4"`
+			PollInterval time.Duration `conf:"default:1s"`
+			Timeout      time.Duration `conf:"default:30s"`
+		}
+		Webhooks struct {
+			MilestoneInterval  time.Duration `conf:"default:10s"`
+			ExpirationInterval time.Duration `conf:"default:1m"`
+			Timeout            time.Duration `conf:"default:10s"`
+		}
+		Health struct {
+			Interval     time.Duration `conf:"default:10m"`
+			Recheck      time.Duration `conf:"default:1h"`
+			Batch        int           `conf:"default:50"`
+			Concurrency  int           `conf:"default:8"`
+			HostInterval time.Duration `conf:"default:1s"`
+			Threshold    int           `conf:"default:3"`
+			Timeout      time.Duration `conf:"default:10s"`
+		}
+		Abuse struct {
+			ReportDifficulty   int           `conf:"default:18"`
+			ReportChallengeAge time.Duration `conf:"default:10m"`
+		}
+		Reputation struct {
+			BlocklistFile  string
+			HashPrefixFile string
+			Interval       time.Duration `conf:"default:1h"`
+			Recheck        time.Duration `conf:"default:24h"`
+			Batch          int           `conf:"default:100"`
+		}
+		Signing struct {
+			ActiveKey string
+			Keys      map[string]string `conf:"mask"`
+		}
+		Alerts struct {
+			WebhookURL     string        `conf:"mask"`
+			WebhookTimeout time.Duration `conf:"default:5s"`
+		}
 		DB struct {
 			User       string `conf:"default:postgres"`
 			Password   string `conf:"default:postgres,mask"`
@@ -91,6 +156,12 @@ func run(log *log.Logger) error {
 		return errors.Wrap(err, "parsing config")
 	}
 
+	switch cfg.Web.RedirectCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("invalid redirect code: %d", cfg.Web.RedirectCode)
+	}
+
 	// =========================================================================
 	// App Starting
 
@@ -171,6 +242,151 @@ func run(log *log.Logger) error {
 
 	}()
 
+	// =========================================================================
+	// Start Job Workers
+	//
+	// Jobs are enqueued in the database by the writes they belong to and run
+	// here. On shutdown the workers stop claiming jobs and the ones running
+	// are given the job timeout to finish.
+
+	log.Println("main: Initializing job workers")
+
+	runner := jobs.NewRunner(log, db, jobs.Config{
+		Workers:      cfg.Jobs.Workers,
+		PollInterval: cfg.Jobs.PollInterval,
+		Timeout:      cfg.Jobs.Timeout,
+	})
+
+	whClient := http.Client{
+		Timeout: cfg.Webhooks.Timeout,
+	}
+	webhook.New(log, db).Register(runner, &whClient)
+
+	runner.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Jobs.Timeout)
+		defer cancel()
+
+		if err := runner.Shutdown(ctx); err != nil {
+			log.Printf("main: ERROR : stopping job workers : %v", err)
+			return
+		}
+		log.Println("main: Job workers stopped")
+	}()
+
+	// =========================================================================
+	// Load Reputation Lists
+	//
+	// Destinations are screened against the configured lists when shorturls
+	// are created or updated, and existing shorturls are rescreened as the
+	// lists change. Files changed since they were loaded are reloaded before
+	// every rescreen. Without lists nothing is screened.
+
+	log.Println("main: Loading reputation lists")
+
+	lists, err := reputation.LoadLists(cfg.Reputation.BlocklistFile, cfg.Reputation.HashPrefixFile)
+	if err != nil {
+		return errors.Wrap(err, "loading reputation lists")
+	}
+
+	// =========================================================================
+	// Start Scheduled Tasks
+	//
+	// Periodic tasks run once per slot across every replica, the replica
+	// holding the advisory lock of a task runs it. Leaderboards are
+	// recomputed from click rollups so the API only ever reads the stored
+	// snapshot, and anomaly detection runs right after on the freshly rolled
+	// up clicks. Visit milestones and ended activation windows of webhook
+	// subscriptions are checked on their own, shorter intervals.
+	// Destinations are health checked and rescreened in batches. The last run
+	// of every task is published under /debug/vars.
+
+	log.Println("main: Initializing scheduled tasks")
+
+	notifiers := alert.Notifiers{alert.NewLog(log)}
+	if cfg.Alerts.WebhookURL != "" {
+		client := http.Client{
+			Timeout: cfg.Alerts.WebhookTimeout,
+		}
+		notifiers = append(notifiers, alert.NewWebhook(cfg.Alerts.WebhookURL, &client))
+	}
+
+	lb := leaderboard.New(log, db)
+	detector := anomaly.New(log, db, notifiers)
+	wh := webhook.New(log, db)
+
+	monitor := health.New(log, db, health.NewClient(cfg.Health.Timeout), notifiers, health.Config{
+		Batch:        cfg.Health.Batch,
+		Recheck:      cfg.Health.Recheck,
+		Concurrency:  cfg.Health.Concurrency,
+		HostInterval: cfg.Health.HostInterval,
+		Threshold:    cfg.Health.Threshold,
+	})
+
+	sched := scheduler.New(log, db, scheduler.Config{
+		PollInterval: cfg.Scheduler.PollInterval,
+		Timeout:      cfg.Scheduler.Timeout,
+	})
+
+	refresh := func(ctx context.Context, traceID string, now time.Time) error {
+		if err := lb.Refresh(ctx, traceID, now); err != nil {
+			return errors.Wrap(err, "refreshing leaderboard")
+		}
+		if _, err := detector.Detect(ctx, traceID, now); err != nil {
+			return errors.Wrap(err, "detecting anomalies")
+		}
+		return nil
+	}
+	if err := sched.Add("stats.refresh", "@every "+cfg.Stats.RefreshInterval.String(), refresh); err != nil {
+		return err
+	}
+	if err := sched.Add("webhook.milestones", "@every "+cfg.Webhooks.MilestoneInterval.String(), wh.CheckMilestones); err != nil {
+		return err
+	}
+	if err := sched.Add("webhook.expirations", "@every "+cfg.Webhooks.ExpirationInterval.String(), wh.CheckExpirations); err != nil {
+		return err
+	}
+
+	check := func(ctx context.Context, traceID string, now time.Time) error {
+		_, err := monitor.Check(ctx, traceID, now)
+		return err
+	}
+	if err := sched.Add("health.check", "@every "+cfg.Health.Interval.String(), check); err != nil {
+		return err
+	}
+
+	if lists.Len() > 0 {
+		ab := abuse.New(log, db)
+		rescreen := func(ctx context.Context, traceID string, now time.Time) error {
+			if err := lists.Reload(); err != nil {
+				log.Printf("main: ERROR : reloading reputation lists : %v", err)
+			}
+
+			_, err := ab.Rescreen(ctx, traceID, lists, abuse.RescreenConfig{
+				Batch:   cfg.Reputation.Batch,
+				Recheck: cfg.Reputation.Recheck,
+			}, now)
+			return err
+		}
+		if err := sched.Add("reputation.rescreen", "@every "+cfg.Reputation.Interval.String(), rescreen); err != nil {
+			return err
+		}
+	}
+
+	expvar.Publish("scheduler", expvar.Func(func() interface{} { return sched.Status() }))
+
+	sched.Start()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Scheduler.Timeout)
+		defer cancel()
+
+		if err := sched.Shutdown(ctx); err != nil {
+			log.Printf("main: ERROR : stopping scheduled tasks : %v", err)
+			return
+		}
+		log.Println("main: Scheduled tasks stopped")
+	}()
+
 	// =========================================================================
 	// Start API Service
 
@@ -181,12 +397,55 @@ func run(log *log.Logger) error {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	apiCfg := handlers.Config{
+		RedirectCode:       cfg.Web.RedirectCode,
+		RedirectMaxAge:     cfg.Web.RedirectMaxAge,
+		GoLinks:            cfg.Web.GoLinks,
+		VisitorSalt:        cfg.Stats.VisitorSalt,
+		ExportTimeout:      cfg.Web.ExportTimeout,
+		ReportDifficulty:   cfg.Abuse.ReportDifficulty,
+		ReportChallengeAge: cfg.Abuse.ReportChallengeAge,
+	}
+	if cfg.Web.ComingSoonPage != "" {
+		page, err := template.ParseFiles(cfg.Web.ComingSoonPage)
+		if err != nil {
+			return errors.Wrap(err, "parsing coming soon page")
+		}
+		apiCfg.ComingSoonPage = page
+	}
+	proxies, err := proxy.New(cfg.Web.TrustedProxies)
+	if err != nil {
+		return errors.Wrap(err, "parsing trusted proxies")
+	}
+	apiCfg.TrustedProxies = proxies
+	if len(cfg.Signing.Keys) > 0 {
+		keys := make(signed.Keys, len(cfg.Signing.Keys))
+		for kid, secret := range cfg.Signing.Keys {
+			keys[kid] = []byte(secret)
+		}
+		signer, err := signed.New(cfg.Signing.ActiveKey, keys)
+		if err != nil {
+			return errors.Wrap(err, "constructing signer")
+		}
+		apiCfg.Signer = signer
+	}
+	if lists.Len() > 0 {
+		apiCfg.Reputation = lists
+	}
+
+	// Live click streams only end when the client leaves, so they are told
+	// to end once shutdown starts instead of holding it up.
+	liveDone := make(chan struct{})
+	apiCfg.LiveDone = liveDone
+
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
-		Handler:      handlers.API(build, shutdown, log, auth, db),
+		Handler:      handlers.API(build, shutdown, log, auth, db, apiCfg),
 		ReadTimeout:  cfg.Web.ReadTimeout,
 		WriteTimeout: cfg.Web.WriteTimeout,
+		ConnContext:  web.ConnContext,
 	}
+	api.RegisterOnShutdown(func() { close(liveDone) })
 
 	// Make a channel to listen for errors coming from the listener. Use a
 	// buffered channel so the gorutine can exit if we don't collect this error.
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/mitrovicsinisaa/shorturl/app/shorturl-api/handlers"
+	"github.com/mitrovicsinisaa/shorturl/business/data/golink"
+	"github.com/mitrovicsinisaa/shorturl/business/signed"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+// GolinkTests holds methods for each golink subtest. This type allows passing
+// dependencies for tests while still providing a convenient syntax when
+// subtests are registered.
+type GolinkTests struct {
+	app       http.Handler
+	userToken string
+}
+
+// TestGolinks is the entry point for testing go links API functions.
+func TestGolinks(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	// A signer is configured so dotted keywords are told apart from signed
+	// codes.
+	signer, err := signed.New("k1", signed.Keys{"k1": []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shutdown := make(chan os.Signal, 1)
+	tests := GolinkTests{
+		app:       handlers.API("develop", shutdown, test.Log, test.Auth, test.DB, handlers.Config{GoLinks: true, Signer: signer}),
+		userToken: test.Token(test.KID, "user@example.com", "gophers"),
+	}
+
+	t.Run("postGolink401", tests.postGolink401)
+	t.Run("getGolinkExpanded", tests.getGolinkExpanded)
+	t.Run("getGolinkDotted", tests.getGolinkDotted)
+}
+
+// postGolink401 validates a golink can't be created without a token.
+func (gt *GolinkTests) postGolink401(t *testing.T) {
+	body, err := json.Marshal(&golink.NewGolink{Keyword: "bug", Template: "https://tracker.example/issue/{1}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/golinks", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	gt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate a golink can't be created by an anonymous user.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen creating a golink without a token.", testID)
+		{
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// getGolinkExpanded validates a golink keyword redirects to its expanded
+// template.
+func (gt *GolinkTests) getGolinkExpanded(t *testing.T) {
+	body, err := json.Marshal(&golink.NewGolink{Keyword: "bug", Template: "https://tracker.example/{project}/issue/{1}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/golinks", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+gt.userToken)
+	gt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to resolve golink keywords.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen visiting a keyword with parameters.", testID)
+		{
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/bug/1234?project=core", nil)
+			w = httptest.NewRecorder()
+			gt.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusSeeOther {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 303 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 303 for the response.", tests.Success, testID)
+
+			want := "https://tracker.example/core/issue/1234"
+			if got := w.Header().Get("Location"); got != want {
+				t.Fatalf("\t%s\tTest %d:\tShould redirect to %s : %s", tests.Failed, testID, want, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould redirect to the expanded template.", tests.Success, testID)
+		}
+	}
+}
+
+// getGolinkDotted validates a keyword with dots in it is resolved as a golink
+// and not taken for a signed code.
+func (gt *GolinkTests) getGolinkDotted(t *testing.T) {
+	body, err := json.Marshal(&golink.NewGolink{Keyword: "team.docs", Template: "https://docs.example/team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/golinks", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+gt.userToken)
+	gt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to resolve golink keywords with dots.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen visiting a dotted keyword.", testID)
+		{
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/team.docs", nil)
+			w = httptest.NewRecorder()
+			gt.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusSeeOther {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 303 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 303 for the response.", tests.Success, testID)
+
+			want := "https://docs.example/team"
+			if got := w.Header().Get("Location"); got != want {
+				t.Fatalf("\t%s\tTest %d:\tShould redirect to %s : %s", tests.Failed, testID, want, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould redirect to the golink template.", tests.Success, testID)
+		}
+	}
+}
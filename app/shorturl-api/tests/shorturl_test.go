@@ -6,12 +6,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/mitrovicsinisaa/shorturl/app/shorturl-api/handlers"
 	"github.com/mitrovicsinisaa/shorturl/business/base62"
 	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/pow"
+	"github.com/mitrovicsinisaa/shorturl/business/signed"
 	"github.com/mitrovicsinisaa/shorturl/business/tests"
 	"github.com/mitrovicsinisaa/shorturl/foundation/web"
 )
@@ -30,17 +34,28 @@ func TestShorturl(t *testing.T) {
 	test := tests.NewIntegration(t)
 	t.Cleanup(test.Teardown)
 
+	signer, err := signed.New("k1", signed.Keys{"k1": []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	shutdown := make(chan os.Signal, 1)
 	tests := ShorturlTests{
-		app:        handlers.API("develop", shutdown, test.Log, test.Auth, test.DB),
+		app:        handlers.API("develop", shutdown, test.Log, test.Auth, test.DB, handlers.Config{Signer: signer}),
 		kid:        test.KID,
 		adminToken: test.Token(test.KID, "admin@example.com", "gophers"),
 	}
 
 	t.Run("postShorturl400", tests.postShorturl400)
+	t.Run("putShorturl400", tests.putShorturl400)
 	t.Run("getShorturl404", tests.getShorturl404)
 	t.Run("getShorturl401", tests.getShorturl401)
 	t.Run("successShorturlActions", tests.successShorturlActions)
+	t.Run("redirectShorturl308", tests.redirectShorturl308)
+	t.Run("badgeShorturl200", tests.badgeShorturl200)
+	t.Run("comingSoonShorturl200", tests.comingSoonShorturl200)
+	t.Run("signedShorturl303", tests.signedShorturl303)
+	t.Run("reportShorturl201", tests.reportShorturl201)
 }
 
 // postShorturl400 validates a shorturl can't be created with the endpoint
@@ -75,6 +90,46 @@ func (st *ShorturlTests) postShorturl400(t *testing.T) {
 	}
 }
 
+// putShorturl400 validates a shorturl can't be updated to a destination that
+// can't be parsed.
+func (st *ShorturlTests) putShorturl400(t *testing.T) {
+	body, err := json.Marshal(&shorturl.NewShorturl{URL: "https://www.google.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/shorturl", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	st.app.ServeHTTP(w, r)
+
+	var created struct {
+		ShortUrl string `json:"shorturl"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	code := created.ShortUrl[strings.LastIndex(created.ShortUrl, "/")+1:]
+
+	body = []byte(`{"fallback_url":"https://example.com/%zz"}`)
+	r = httptest.NewRequest(http.MethodPut, "/api/shorturl/"+code, bytes.NewBuffer(body))
+	w = httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+st.adminToken)
+	st.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate a shorturl can't be updated with an invalid destination.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using a destination that can't be parsed.", testID)
+		{
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 for the response.", tests.Success, testID)
+		}
+	}
+}
+
 // getShorturl404 validates a shorturl request for a bad url.
 func (st *ShorturlTests) getShorturl404(t *testing.T) {
 	url := "owna"
@@ -202,6 +257,8 @@ func (st *ShorturlTests) getShorturlVisits200(t *testing.T, ID int) {
 	url := base62.Encode(ID)
 
 	rv := httptest.NewRequest(http.MethodGet, "/"+url, nil)
+	rv.Header.Set("User-Agent", "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+	rv.Header.Set("Accept", "text/html")
 	wv := httptest.NewRecorder()
 	st.app.ServeHTTP(wv, rv)
 	st.app.ServeHTTP(wv, rv)
@@ -257,3 +314,321 @@ func (st *ShorturlTests) deleteShorturl204(t *testing.T, ID int) {
 		}
 	}
 }
+
+// redirectShorturl308 validates a shorturl can be created with its own
+// redirect status code and is served with caching headers.
+func (st *ShorturlTests) redirectShorturl308(t *testing.T) {
+	body, err := json.Marshal(&shorturl.NewShorturl{
+		URL:          "https://www.google.com/",
+		RedirectCode: http.StatusPermanentRedirect,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/shorturl", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	st.app.ServeHTTP(w, r)
+
+	var created struct {
+		ShortUrl string `json:"shorturl"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	code := created.ShortUrl[strings.LastIndex(created.ShortUrl, "/")+1:]
+
+	r = httptest.NewRequest(http.MethodGet, "/"+code, nil)
+	w = httptest.NewRecorder()
+	st.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate a shorturl redirects with its own status code.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen visiting the shorturl %s.", testID, code)
+		{
+			if w.Code != http.StatusPermanentRedirect {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 308 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 308 for the response.", tests.Success, testID)
+
+			if cc := w.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "public, max-age=") {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a cacheable response : %q", tests.Failed, testID, cc)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a cacheable response.", tests.Success, testID)
+		}
+	}
+}
+
+// comingSoonShorturl200 validates that a shorturl that is not active yet
+// shows the coming soon page without revealing its destination.
+func (st *ShorturlTests) comingSoonShorturl200(t *testing.T) {
+	from := time.Now().Add(14 * 24 * time.Hour)
+	body, err := json.Marshal(&shorturl.NewShorturl{
+		URL:        "https://www.google.com/launch",
+		ActiveFrom: &from,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/shorturl", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	st.app.ServeHTTP(w, r)
+
+	var created struct {
+		ShortUrl string `json:"shorturl"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	code := created.ShortUrl[strings.LastIndex(created.ShortUrl, "/")+1:]
+
+	r = httptest.NewRequest(http.MethodGet, "/"+code, nil)
+	w = httptest.NewRecorder()
+	st.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to validate a shorturl is not visited before its window.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen visiting the shorturl %s.", testID, code)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive an uncached response : %q", tests.Failed, testID, cc)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive an uncached response.", tests.Success, testID)
+
+			if strings.Contains(w.Body.String(), "/launch") || w.Header().Get("Location") != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould not reveal the destination : %s", tests.Failed, testID, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould not reveal the destination.", tests.Success, testID)
+		}
+	}
+}
+
+// signedShorturl303 validates that a signed only shorturl redirects through
+// signed codes only.
+func (st *ShorturlTests) signedShorturl303(t *testing.T) {
+	body, err := json.Marshal(&shorturl.NewShorturl{
+		URL:        "https://www.google.com/",
+		SignedOnly: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/shorturl", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	st.app.ServeHTTP(w, r)
+
+	var created struct {
+		ShortUrl string `json:"shorturl"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	code := created.ShortUrl[strings.LastIndex(created.ShortUrl, "/")+1:]
+
+	r = httptest.NewRequest(http.MethodPost, "/api/shorturl/"+code+"/sign", strings.NewReader(`{}`))
+	r.Header.Set("Authorization", "Bearer "+st.adminToken)
+	w = httptest.NewRecorder()
+	st.app.ServeHTTP(w, r)
+
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	signedCode := created.ShortUrl[strings.LastIndex(created.ShortUrl, "/")+1:]
+	forged := signedCode[:len(signedCode)-1] + "x"
+	if forged == signedCode {
+		forged = signedCode[:len(signedCode)-1] + "y"
+	}
+
+	tt := []struct {
+		name   string
+		code   string
+		status int
+	}{
+		{"plain", code, http.StatusNotFound},
+		{"signed", signedCode, http.StatusSeeOther},
+		{"forged", forged, http.StatusNotFound},
+	}
+
+	t.Log("Given the need to validate a signed only shorturl.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen visiting the %s code %s.", testID, tst.name, tst.code)
+			{
+				r := httptest.NewRequest(http.MethodGet, "/"+tst.code, nil)
+				w := httptest.NewRecorder()
+				st.app.ServeHTTP(w, r)
+
+				if w.Code != tst.status {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of %d for the response : %v", tests.Failed, testID, tst.status, w.Code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould receive a status code of %d for the response.", tests.Success, testID, tst.status)
+			}
+		}
+	}
+}
+
+// reportShorturl201 validates reporting a shorturl from its preview page with
+// a proof of work.
+func (st *ShorturlTests) reportShorturl201(t *testing.T) {
+	body, err := json.Marshal(&shorturl.NewShorturl{
+		URL: "https://www.google.com/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/shorturl", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	st.app.ServeHTTP(w, r)
+
+	var created struct {
+		ShortUrl string `json:"shorturl"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	code := created.ShortUrl[strings.LastIndex(created.ShortUrl, "/")+1:]
+
+	t.Log("Given the need to validate reporting a shorturl.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen previewing the shorturl %s.", testID, code)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/-/"+code+"/preview", nil)
+			w := httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), "https://www.google.com/") {
+				t.Fatalf("\t%s\tTest %d:\tShould show the destination : %s", tests.Failed, testID, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould show the destination.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen reporting the shorturl %s.", testID, code)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/api/reports/challenge", nil)
+			w := httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			var challenge struct {
+				Challenge  string `json:"challenge"`
+				Difficulty int    `json:"difficulty"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&challenge); err != nil {
+				t.Fatal(err)
+			}
+
+			report := map[string]string{
+				"code":      code,
+				"reason":    "phishing",
+				"challenge": challenge.Challenge,
+				"nonce":     "x",
+			}
+			body, err := json.Marshal(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r = httptest.NewRequest(http.MethodPost, "/api/reports", bytes.NewBuffer(body))
+			w = httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 400 without a proof of work : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 400 without a proof of work.", tests.Success, testID)
+
+			report["nonce"] = pow.Solve(challenge.Challenge, challenge.Difficulty)
+			body, err = json.Marshal(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r = httptest.NewRequest(http.MethodPost, "/api/reports", bytes.NewBuffer(body))
+			w = httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 201 for the response.", tests.Success, testID)
+		}
+	}
+}
+
+// badgeShorturl200 validates the visit count badge of a shorturl with public
+// stats and its revalidation through the ETag.
+func (st *ShorturlTests) badgeShorturl200(t *testing.T) {
+	body, err := json.Marshal(&shorturl.NewShorturl{
+		URL:         "https://www.google.com/",
+		PublicStats: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/shorturl", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	st.app.ServeHTTP(w, r)
+
+	var created struct {
+		ShortUrl string `json:"shorturl"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	code := created.ShortUrl[strings.LastIndex(created.ShortUrl, "/")+1:]
+
+	r = httptest.NewRequest(http.MethodGet, "/-/"+code+"/badge.svg?label=visits&color=green", nil)
+	w = httptest.NewRecorder()
+	st.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to embed the visit count of a shorturl as a badge.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen requesting the badge of %s.", testID, code)
+		{
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", tests.Success, testID)
+
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "image/svg+xml") {
+				t.Fatalf("\t%s\tTest %d:\tShould receive an SVG image : %q", tests.Failed, testID, ct)
+			}
+			if !strings.Contains(w.Body.String(), "visits: 0") {
+				t.Fatalf("\t%s\tTest %d:\tShould show the label and visit count : %s", tests.Failed, testID, w.Body.String())
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive an SVG image with the visit count.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen revalidating the badge of %s.", testID, code)
+		{
+			etag := w.Header().Get("ETag")
+
+			r = httptest.NewRequest(http.MethodGet, "/-/"+code+"/badge.svg?label=visits&color=green", nil)
+			r.Header.Set("If-None-Match", etag)
+			w = httptest.NewRecorder()
+			st.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNotModified {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 304 for the response : %v", tests.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 304 for the response.", tests.Success, testID)
+		}
+	}
+}
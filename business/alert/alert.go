@@ -0,0 +1,114 @@
+// Package alert delivers alerts about unusual link traffic and failing
+// destinations through pluggable notifiers.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Set of alert kinds.
+const (
+	KindSpike  = "spike"
+	KindDrop   = "drop"
+	KindBroken = "broken"
+)
+
+// Alert describes a sudden change in the click rate of a shorturl, or a
+// destination that keeps failing. Rates are zero for the latter.
+type Alert struct {
+	ShorturlID   int       `json:"id"`
+	Code         string    `json:"code"`
+	URL          string    `json:"url"`
+	Kind         string    `json:"kind"`
+	RecentRate   float64   `json:"recent_rate"`
+	BaselineRate float64   `json:"baseline_rate"`
+	Message      string    `json:"message"`
+	Time         time.Time `json:"time"`
+}
+
+// Notifier delivers alerts to the people or systems watching link traffic.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Notifiers delivers each alert to every notifier in the list.
+type Notifiers []Notifier
+
+// Notify implements the Notifier interface. Every notifier is attempted and
+// the first error is returned.
+func (ns Notifiers) Notify(ctx context.Context, a Alert) error {
+	var first error
+	for _, n := range ns {
+		if err := n.Notify(ctx, a); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Log writes alerts to a logger.
+type Log struct {
+	log *log.Logger
+}
+
+// NewLog constructs a notifier that writes alerts to the logger.
+func NewLog(log *log.Logger) Log {
+	return Log{
+		log: log,
+	}
+}
+
+// Notify implements the Notifier interface.
+func (l Log) Notify(ctx context.Context, a Alert) error {
+	l.log.Printf("ALERT : %s : %s : %s", a.Kind, a.Code, a.Message)
+	return nil
+}
+
+// Webhook posts alerts as JSON documents to a URL.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook constructs a notifier that posts alerts to the URL using the
+// provided client.
+func NewWebhook(url string, client *http.Client) Webhook {
+	return Webhook{
+		url:    url,
+		client: client,
+	}
+}
+
+// Notify implements the Notifier interface.
+func (wh Webhook) Notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return errors.Wrap(err, "encoding alert")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "creating alert request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "posting alert")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("posting alert: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package alert_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mitrovicsinisaa/shorturl/business/alert"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestWebhook(t *testing.T) {
+	var got alert.Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	t.Log("Given the need to deliver alerts to a webhook.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the webhook accepts the alert.", testID)
+		{
+			wh := alert.NewWebhook(srv.URL, srv.Client())
+			a := alert.Alert{ShorturlID: 1, Code: "owna", Kind: alert.KindSpike}
+
+			if err := wh.Notify(context.Background(), a); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to deliver the alert : %s.", failed, testID, err)
+			}
+			if got.Code != a.Code || got.Kind != a.Kind {
+				t.Fatalf("\t%s\tTest %d:\tShould receive the alert : %+v.", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to deliver the alert.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the webhook rejects the alert.", testID)
+		{
+			wh := alert.NewWebhook(srv.URL+"/missing", srv.Client())
+			srv.Config.Handler = http.NotFoundHandler()
+
+			if err := wh.Notify(context.Background(), alert.Alert{}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail to deliver the alert.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail to deliver the alert.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package bot classifies requests made by link unfurlers, crawlers, security
+// scanners and other automated clients so they can be kept apart from human
+// visits.
+package bot
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Set of reasons reported by Detect.
+const (
+	ReasonSignature = "signature"
+	ReasonHead      = "head request"
+	ReasonNoAgent   = "missing user agent"
+	ReasonNoAccept  = "missing accept header"
+)
+
+// signatures are lowercase User-Agent fragments of known automated clients.
+// Keep the list sorted by kind so it stays easy to maintain.
+var signatures = []string{
+
+	// Link unfurlers and preview generators. iMessage previews identify as
+	// the Facebook and Twitter crawlers.
+	"slackbot",
+	"slack-imgproxy",
+	"twitterbot",
+	"facebookexternalhit",
+	"facebot",
+	"linkedinbot",
+	"whatsapp",
+	"telegrambot",
+	"discordbot",
+	"skypeuripreview",
+	"microsoftpreview",
+	"pinterestbot",
+	"redditbot",
+	"embedly",
+	"iframely",
+	"vkshare",
+	"google-pagerenderer",
+
+	// Search engine crawlers.
+	"googlebot",
+	"adsbot-google",
+	"bingbot",
+	"applebot",
+	"duckduckbot",
+	"yandexbot",
+	"baiduspider",
+	"sogou",
+	"petalbot",
+
+	// Security scanners and reputation checkers.
+	"urlscan",
+	"virustotal",
+	"safebrowsing",
+	"barracuda",
+	"proofpoint",
+	"mimecast",
+	"zgrab",
+	"nmap",
+	"masscan",
+
+	// Tools, libraries and headless browsers.
+	"curl/",
+	"wget/",
+	"python-requests",
+	"python-urllib",
+	"go-http-client",
+	"okhttp",
+	"java/",
+	"libwww-perl",
+	"headlesschrome",
+	"phantomjs",
+
+	// Generic markers, checked last.
+	"bot",
+	"crawler",
+	"spider",
+	"preview",
+}
+
+// Detect reports whether the request was most likely made by an automated
+// client, together with the reason for the decision.
+func Detect(r *http.Request) (bool, string) {
+	ua := strings.ToLower(r.UserAgent())
+
+	switch {
+	case r.Method == http.MethodHead:
+		return true, ReasonHead
+	case strings.TrimSpace(ua) == "":
+		return true, ReasonNoAgent
+	}
+
+	for _, sig := range signatures {
+		if strings.Contains(ua, sig) {
+			return true, ReasonSignature
+		}
+	}
+
+	// Browsers always send an Accept header on navigation.
+	if r.Header.Get("Accept") == "" {
+		return true, ReasonNoAccept
+	}
+
+	return false, ""
+}
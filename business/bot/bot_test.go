@@ -0,0 +1,54 @@
+package bot_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mitrovicsinisaa/shorturl/business/bot"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+const browserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Safari/605.1.15"
+
+func TestDetect(t *testing.T) {
+	tt := []struct {
+		name   string
+		method string
+		ua     string
+		accept string
+		bot    bool
+		reason string
+	}{
+		{"browser", http.MethodGet, browserAgent, "text/html", false, ""},
+		{"slack", http.MethodGet, "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", "*/*", true, bot.ReasonSignature},
+		{"imessage", http.MethodGet, "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_11_1) AppleWebKit/601.2.4 (KHTML, like Gecko) Version/9.0.1 Safari/601.2.4 facebookexternalhit/1.1 Facebot Twitterbot/1.0", "*/*", true, bot.ReasonSignature},
+		{"googlebot", http.MethodGet, "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "*/*", true, bot.ReasonSignature},
+		{"head", http.MethodHead, browserAgent, "text/html", true, bot.ReasonHead},
+		{"noAgent", http.MethodGet, "", "text/html", true, bot.ReasonNoAgent},
+		{"noAccept", http.MethodGet, browserAgent, "", true, bot.ReasonNoAccept},
+	}
+
+	t.Log("Given the need to tell automated clients from human visitors.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen handling the %s request.", testID, tst.name)
+			{
+				r := httptest.NewRequest(tst.method, "/abcd", nil)
+				r.Header.Set("User-Agent", tst.ua)
+				r.Header.Set("Accept", tst.accept)
+
+				isBot, reason := bot.Detect(r)
+				if isBot != tst.bot || reason != tst.reason {
+					t.Fatalf("\t%s\tTest %d:\tShould get %v %q : got %v %q.", failed, testID, tst.bot, tst.reason, isBot, reason)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected classification.", success, testID)
+			}
+		}
+	}
+}
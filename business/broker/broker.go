@@ -0,0 +1,133 @@
+// Package broker provides an in-process publish/subscribe broker for visit
+// events. Publishing never blocks: events for subscribers that do not keep
+// up are dropped and counted. Every event is numbered and the most recent
+// ones are kept, so a subscriber that reconnects can resume where it left
+// off.
+package broker
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event describes a single visit of a shorturl.
+type Event struct {
+	ID         uint64    `json:"-"`
+	ShorturlID int       `json:"-"`
+	Code       string    `json:"code"`
+	Time       time.Time `json:"time"`
+	Referrer   string    `json:"referrer"`
+	Device     string    `json:"device"`
+	Bot        bool      `json:"bot"`
+}
+
+// Subscription receives the events of a single shorturl, or of every
+// shorturl when subscribed with an ID of zero.
+type Subscription struct {
+	C <-chan Event
+
+	c          chan Event
+	shorturlID int
+	dropped    uint64
+}
+
+// Dropped returns the number of events that were dropped because the
+// subscriber did not keep up.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Broker fans events out to the current subscribers.
+type Broker struct {
+	buffer int
+
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	lastID  uint64
+	history []Event
+	next    int
+}
+
+// New constructs a broker whose subscriptions buffer up to buffer events and
+// that keeps the last history events for replay.
+func New(buffer int, history int) *Broker {
+	return &Broker{
+		buffer:  buffer,
+		subs:    make(map[*Subscription]struct{}),
+		history: make([]Event, 0, history),
+	}
+}
+
+// Subscribe registers a subscription for the events of the specified
+// shorturl. A shorturlID of zero subscribes to every shorturl. The kept
+// events published after lastID are replayed first; a lastID of zero, or one
+// the broker never handed out, replays nothing.
+func (b *Broker) Subscribe(shorturlID int, lastID uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if lastID != 0 && lastID < b.lastID {
+		for i := range b.history {
+			e := b.history[(b.next+i)%len(b.history)]
+			if e.ID <= lastID || (shorturlID != 0 && shorturlID != e.ShorturlID) {
+				continue
+			}
+			replay = append(replay, e)
+		}
+	}
+
+	c := make(chan Event, b.buffer+len(replay))
+	for _, e := range replay {
+		c <- e
+	}
+
+	sub := Subscription{
+		C:          c,
+		c:          c,
+		shorturlID: shorturlID,
+	}
+	b.subs[&sub] = struct{}{}
+
+	return &sub
+}
+
+// Unsubscribe removes the subscription. No events are delivered to it
+// afterwards.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	delete(b.subs, sub)
+	b.mu.Unlock()
+}
+
+// Publish numbers the event, keeps it for replay and delivers it to every
+// matching subscription without blocking.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+
+	switch {
+	case cap(b.history) == 0:
+	case len(b.history) < cap(b.history):
+		b.history = append(b.history, e)
+	default:
+		b.history[b.next] = e
+		b.next = (b.next + 1) % len(b.history)
+	}
+
+	for sub := range b.subs {
+		if sub.shorturlID != 0 && sub.shorturlID != e.ShorturlID {
+			continue
+		}
+
+		select {
+		case sub.c <- e:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package broker_test
+
+import (
+	"testing"
+
+	"github.com/mitrovicsinisaa/shorturl/business/broker"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestBroker(t *testing.T) {
+	t.Log("Given the need to fan out visit events.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen subscribing to one and to every shorturl.", testID)
+		{
+			b := broker.New(10, 0)
+			one := b.Subscribe(1, 0)
+			all := b.Subscribe(0, 0)
+			defer b.Unsubscribe(one)
+			defer b.Unsubscribe(all)
+
+			b.Publish(broker.Event{ShorturlID: 1})
+			b.Publish(broker.Event{ShorturlID: 2})
+
+			if len(one.C) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould deliver only the events of the shorturl : %d.", failed, testID, len(one.C))
+			}
+			t.Logf("\t%s\tTest %d:\tShould deliver only the events of the shorturl.", success, testID)
+
+			if len(all.C) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould deliver the events of every shorturl : %d.", failed, testID, len(all.C))
+			}
+			t.Logf("\t%s\tTest %d:\tShould deliver the events of every shorturl.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a subscriber does not keep up.", testID)
+		{
+			b := broker.New(1, 0)
+			slow := b.Subscribe(0, 0)
+
+			for i := 0; i < 5; i++ {
+				b.Publish(broker.Event{ShorturlID: 1})
+			}
+
+			if slow.Dropped() != 4 {
+				t.Fatalf("\t%s\tTest %d:\tShould drop the events that do not fit : %d.", failed, testID, slow.Dropped())
+			}
+			t.Logf("\t%s\tTest %d:\tShould drop the events that do not fit.", success, testID)
+
+			b.Unsubscribe(slow)
+			<-slow.C
+			b.Publish(broker.Event{ShorturlID: 1})
+			if len(slow.C) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould not deliver after unsubscribing.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not deliver after unsubscribing.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen a subscriber reconnects with the last event it saw.", testID)
+		{
+			b := broker.New(10, 3)
+			for i := 0; i < 5; i++ {
+				b.Publish(broker.Event{ShorturlID: 1 + i%2})
+			}
+
+			all := b.Subscribe(0, 3)
+			defer b.Unsubscribe(all)
+			if len(all.C) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould replay the events published after it : %d.", failed, testID, len(all.C))
+			}
+			if e := <-all.C; e.ID != 4 {
+				t.Fatalf("\t%s\tTest %d:\tShould replay the events in order : %d.", failed, testID, e.ID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould replay the events published after it.", success, testID)
+
+			one := b.Subscribe(1, 1)
+			defer b.Unsubscribe(one)
+			if len(one.C) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould replay only the kept events of the shorturl : %d.", failed, testID, len(one.C))
+			}
+			t.Logf("\t%s\tTest %d:\tShould replay only the kept events of the shorturl.", success, testID)
+
+			fresh := b.Subscribe(0, 0)
+			defer b.Unsubscribe(fresh)
+			if len(fresh.C) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould not replay for a new subscriber : %d.", failed, testID, len(fresh.C))
+			}
+			t.Logf("\t%s\tTest %d:\tShould not replay for a new subscriber.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Package abuse collects abuse reports of shorturls from the public and keeps
+// the queue moderators work through. Moderators disable, delete or allowlist
+// reported shorturls and every action is recorded with its reason.
+package abuse
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/data/webhook"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a reported or moderated shorturl does not
+	// exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrReplayed occurs when a report is sent with a proof of work that was
+	// already used.
+	ErrReplayed = errors.New("proof of work already used")
+)
+
+// Abuse manages the set of API's for abuse reports and moderation.
+type Abuse struct {
+	log      *log.Logger
+	db       *sqlx.DB
+	webhook  webhook.Webhook
+	shorturl shorturl.Shorturl
+}
+
+// New constructs an Abuse for api access.
+func New(log *log.Logger, db *sqlx.DB) Abuse {
+	return Abuse{
+		log:      log,
+		db:       db,
+		webhook:  webhook.New(log, db),
+		shorturl: shorturl.New(log, db, nil),
+	}
+}
+
+// Report files a report of the shorturl. The proof of work must be verified
+// by the caller, its challenge is stored so it can only be used once.
+func (a Abuse) Report(ctx context.Context, traceID string, shorturlID int, nr NewReport, now time.Time) (Report, error) {
+
+	const q = `
+	INSERT INTO abuse_reports
+		(shorturl_id, reason, details, challenge, status, date_created)
+	SELECT
+		shorturl_id, $2, $3, $4, CASE WHEN allowlisted THEN $5 ELSE $6 END, $7
+	FROM
+		shorturls
+	WHERE
+		shorturl_id = $1
+		RETURNING *`
+
+	args := []interface{}{shorturlID, nr.Reason, nr.Details, nr.Challenge, StatusDismissed, StatusOpen, now.UTC()}
+
+	a.log.Printf("%s : %s : query : %s", traceID, "abuse.Report",
+		database.Log(q, args...))
+
+	var r Report
+	if err := a.db.GetContext(ctx, &r, q, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return Report{}, ErrNotFound
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return Report{}, ErrReplayed
+		}
+		return Report{}, errors.Wrapf(err, "inserting report of shorturl %d", shorturlID)
+	}
+
+	return r, nil
+}
+
+// Queue retrieves the shorturls with open reports, the most reported first.
+func (a Abuse) Queue(ctx context.Context, traceID string, pageNumber int, rowsPerPage int) ([]QueueItem, error) {
+
+	const q = `
+	SELECT
+		s.shorturl_id, s.url, s.disabled,
+		COUNT(*) AS reports,
+		ARRAY_AGG(DISTINCT r.reason) AS reasons,
+		MIN(r.date_created) AS first_reported,
+		MAX(r.date_created) AS last_reported
+	FROM
+		abuse_reports AS r
+	JOIN
+		shorturls AS s ON s.shorturl_id = r.shorturl_id
+	WHERE
+		r.status = $1
+	GROUP BY
+		s.shorturl_id
+	ORDER BY
+		reports DESC, last_reported DESC
+	OFFSET $2 ROWS FETCH NEXT $3 ROWS ONLY`
+
+	a.log.Printf("%s : %s : query : %s", traceID, "abuse.Queue",
+		database.Log(q, StatusOpen, pageNumber, rowsPerPage))
+
+	items := []QueueItem{}
+	if err := a.db.SelectContext(ctx, &items, q, StatusOpen, pageNumber, rowsPerPage); err != nil {
+		return nil, errors.Wrap(err, "selecting moderation queue")
+	}
+	for i := range items {
+		items[i].Code = base62.Encode(items[i].ShorturlID)
+	}
+
+	return items, nil
+}
+
+// Moderate takes the action on the shorturl, closes its open reports and
+// records who took the action and why.
+func (a Abuse) Moderate(ctx context.Context, traceID string, moderator string, shorturlID int, m Moderation, now time.Time) error {
+	tx, err := a.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	var destination string
+	var q string
+	var args []interface{}
+	status := StatusResolved
+	event := ""
+
+	switch m.Action {
+	case ActionDisable:
+		q = `
+		UPDATE
+			shorturls
+		SET
+			"disabled" = true,
+			"disabled_reason" = $2,
+			"allowlisted" = false
+		WHERE
+			shorturl_id = $1
+			RETURNING url`
+		args = []interface{}{shorturlID, m.Reason}
+		event = webhook.EventLinkDisabled
+
+	case ActionAllowlist:
+		q = `
+		UPDATE
+			shorturls
+		SET
+			"disabled" = false,
+			"disabled_reason" = '',
+			"allowlisted" = true
+		WHERE
+			shorturl_id = $1
+			RETURNING url`
+		args = []interface{}{shorturlID}
+		status = StatusDismissed
+
+	case ActionDelete:
+
+		// Reports go with the shorturl, the recorded action keeps its
+		// destination. Deleting publishes the link.deleted event.
+		destination, err = a.shorturl.DeleteTx(ctx, traceID, tx, shorturlID, now)
+		if err != nil {
+			if errors.Cause(err) == shorturl.ErrNotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+
+	default:
+		return errors.Errorf("unknown moderation action %q", m.Action)
+	}
+
+	if q != "" {
+		a.log.Printf("%s : %s : query : %s", traceID, "abuse.Moderate",
+			database.Log(q, args...))
+
+		if err := tx.GetContext(ctx, &destination, q, args...); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return errors.Wrapf(err, "moderating shorturl %d", shorturlID)
+		}
+	}
+
+	if m.Action != ActionDelete {
+		const qr = `
+		UPDATE
+			abuse_reports
+		SET
+			status = $2
+		WHERE
+			shorturl_id = $1 AND status = $3`
+
+		a.log.Printf("%s : %s : query : %s", traceID, "abuse.Moderate",
+			database.Log(qr, shorturlID, status, StatusOpen))
+
+		if _, err := tx.ExecContext(ctx, qr, shorturlID, status, StatusOpen); err != nil {
+			return errors.Wrapf(err, "closing reports of shorturl %d", shorturlID)
+		}
+	}
+
+	const qa = `
+	INSERT INTO moderation_actions
+		(shorturl_id, url, action, reason, moderator, date_created)
+	VALUES
+		($1, $2, $3, $4, $5, $6)`
+
+	argsa := []interface{}{shorturlID, destination, m.Action, m.Reason, moderator, now.UTC()}
+
+	a.log.Printf("%s : %s : query : %s", traceID, "abuse.Moderate",
+		database.Log(qa, argsa...))
+
+	if _, err := tx.ExecContext(ctx, qa, argsa...); err != nil {
+		return errors.Wrapf(err, "recording moderation of shorturl %d", shorturlID)
+	}
+
+	if event != "" {
+		ev := webhook.Event{
+			Type: event,
+			Data: webhook.LinkData{ID: shorturlID, Code: base62.Encode(shorturlID), URL: destination},
+		}
+		if err := a.webhook.Enqueue(ctx, traceID, tx, ev, now); err != nil {
+			return errors.Wrapf(err, "publishing shorturl %d", shorturlID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrapf(err, "committing moderation of shorturl %d", shorturlID)
+	}
+
+	return nil
+}
+
+// QueryActions retrieves the moderation actions taken, the latest first.
+func (a Abuse) QueryActions(ctx context.Context, traceID string, pageNumber int, rowsPerPage int) ([]Action, error) {
+
+	const q = `
+	SELECT
+		*
+	FROM
+		moderation_actions
+	ORDER BY
+		action_id DESC
+	OFFSET $1 ROWS FETCH NEXT $2 ROWS ONLY`
+
+	a.log.Printf("%s : %s : query : %s", traceID, "abuse.QueryActions",
+		database.Log(q, pageNumber, rowsPerPage))
+
+	actions := []Action{}
+	if err := a.db.SelectContext(ctx, &actions, q, pageNumber, rowsPerPage); err != nil {
+		return nil, errors.Wrap(err, "selecting moderation actions")
+	}
+
+	return actions, nil
+}
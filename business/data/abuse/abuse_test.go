@@ -0,0 +1,182 @@
+package abuse_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/data/abuse"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/pow"
+	"github.com/mitrovicsinisaa/shorturl/business/reputation"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+	"github.com/pkg/errors"
+)
+
+func TestAbuse(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	ab := abuse.New(log, db)
+	su := shorturl.New(log, db, nil)
+
+	t.Log("Given the need to moderate reported shorturls.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen reporting a shorturl.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 15, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			surl, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://phishing.example/login", PublicStats: true}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			for _, reason := range []string{abuse.ReasonPhishing, abuse.ReasonSpam} {
+				challenge, err := pow.NewChallenge(now)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to issue a challenge : %s.", tests.Failed, testID, err)
+				}
+				nr := abuse.NewReport{Reason: reason, Challenge: challenge}
+
+				if _, err := ab.Report(ctx, traceID, surl.ID, nr, now); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to report shorturl : %s.", tests.Failed, testID, err)
+				}
+				if _, err := ab.Report(ctx, traceID, surl.ID, nr, now); err != abuse.ErrReplayed {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT be able to reuse a challenge : %v.", tests.Failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to report shorturl once per challenge.", tests.Success, testID)
+
+			queue, err := ab.Queue(ctx, traceID, 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the queue : %s.", tests.Failed, testID, err)
+			}
+			if len(queue) != 1 || queue[0].ShorturlID != surl.ID || queue[0].Reports != 2 || len(queue[0].Reasons) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould get the reported shorturl in the queue : %+v.", tests.Failed, testID, queue)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the reported shorturl in the queue.", tests.Success, testID)
+
+			m := abuse.Moderation{Action: abuse.ActionDisable, Reason: "credential phishing"}
+			if err := ab.Moderate(ctx, traceID, "admin@example.com", surl.ID, m, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to disable shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			dest, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now)
+			if err != nil || !dest.Disabled || dest.URL != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT redirect a disabled shorturl : %+v : %v.", tests.Failed, testID, dest, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT redirect a disabled shorturl.", tests.Success, testID)
+
+			if _, err := su.QueryPublicStats(ctx, traceID, surl.ID, now.Add(-24*time.Hour), now); errors.Cause(err) != shorturl.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT show the stats of a disabled shorturl : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT show the stats of a disabled shorturl.", tests.Success, testID)
+
+			queue, err = ab.Queue(ctx, traceID, 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the queue : %s.", tests.Failed, testID, err)
+			}
+			if len(queue) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould close the reports of a moderated shorturl : %+v.", tests.Failed, testID, queue)
+			}
+			t.Logf("\t%s\tTest %d:\tShould close the reports of a moderated shorturl.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen allowlisting and deleting shorturls.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 16, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			surl, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://github.com/mitrovicsinisaa/shorturl"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			m := abuse.Moderation{Action: abuse.ActionAllowlist, Reason: "our own repository"}
+			if err := ab.Moderate(ctx, traceID, "admin@example.com", surl.ID, m, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to allowlist shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			challenge, err := pow.NewChallenge(now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to issue a challenge : %s.", tests.Failed, testID, err)
+			}
+			r, err := ab.Report(ctx, traceID, surl.ID, abuse.NewReport{Reason: abuse.ReasonSpam, Challenge: challenge}, now)
+			if err != nil || r.Status != abuse.StatusDismissed {
+				t.Fatalf("\t%s\tTest %d:\tShould dismiss reports of an allowlisted shorturl : %+v : %v.", tests.Failed, testID, r, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould dismiss reports of an allowlisted shorturl.", tests.Success, testID)
+
+			m = abuse.Moderation{Action: abuse.ActionDelete, Reason: "no longer needed"}
+			if err := ab.Moderate(ctx, traceID, "admin@example.com", surl.ID, m, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete shorturl : %s.", tests.Failed, testID, err)
+			}
+			if _, err := su.QueryInfo(ctx, traceID, surl.ID); err != shorturl.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould delete the shorturl : %v.", tests.Failed, testID, err)
+			}
+			if err := ab.Moderate(ctx, traceID, "admin@example.com", surl.ID, m, now); err != abuse.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to moderate a deleted shorturl : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete shorturl.", tests.Success, testID)
+
+			actions, err := ab.QueryActions(ctx, traceID, 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve actions : %s.", tests.Failed, testID, err)
+			}
+			if len(actions) != 3 || actions[0].Action != abuse.ActionDelete || actions[0].URL != surl.URL || actions[0].Reason != m.Reason {
+				t.Fatalf("\t%s\tTest %d:\tShould record every action with its reason : %+v.", tests.Failed, testID, actions)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record every action with its reason.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen rescreening shorturls against a reputation list.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 17, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			bad, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://malware.example/payload.exe"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			good, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://github.com/mitrovicsinisaa/shorturl"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			checker := reputation.NewBlocklist("blocklist", []string{"malware.example"})
+			// A batch of one shorturl still screens both in a single run.
+			cfg := abuse.RescreenConfig{Batch: 1, Recheck: 24 * time.Hour}
+
+			hits, err := ab.Rescreen(ctx, traceID, checker, cfg, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to rescreen shorturls : %s.", tests.Failed, testID, err)
+			}
+			if len(hits) != 1 || hits[0].ShorturlID != bad.ID || hits[0].Threat != "blocklist: malware.example/" {
+				t.Fatalf("\t%s\tTest %d:\tShould find the blocked destination : %+v.", tests.Failed, testID, hits)
+			}
+			t.Logf("\t%s\tTest %d:\tShould find the blocked destination.", tests.Success, testID)
+
+			surl, err := su.QueryInfo(ctx, traceID, bad.ID)
+			if err != nil || !surl.Disabled || surl.DisabledReason != "reputation: "+hits[0].Threat {
+				t.Fatalf("\t%s\tTest %d:\tShould disable the blocked shorturl : %+v : %v.", tests.Failed, testID, surl, err)
+			}
+			surl, err = su.QueryInfo(ctx, traceID, good.ID)
+			if err != nil || surl.Disabled || surl.ScreenedAt == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould mark the clean shorturl screened : %+v : %v.", tests.Failed, testID, surl, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould disable the blocked shorturl only.", tests.Success, testID)
+
+			hits, err = ab.Rescreen(ctx, traceID, checker, cfg, now.Add(time.Hour))
+			if err != nil || len(hits) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT rescreen within the recheck interval : %+v : %v.", tests.Failed, testID, hits, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT rescreen within the recheck interval.", tests.Success, testID)
+		}
+	}
+}
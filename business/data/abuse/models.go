@@ -0,0 +1,100 @@
+package abuse
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Set of reasons a shorturl can be reported for.
+const (
+	ReasonSpam     = "spam"
+	ReasonPhishing = "phishing"
+	ReasonMalware  = "malware"
+	ReasonOther    = "other"
+)
+
+// Set of report states. Reports of allowlisted shorturls are dismissed as
+// they come in.
+const (
+	StatusOpen      = "open"
+	StatusResolved  = "resolved"
+	StatusDismissed = "dismissed"
+)
+
+// Set of moderation actions.
+const (
+	ActionDisable   = "disable"
+	ActionDelete    = "delete"
+	ActionAllowlist = "allowlist"
+)
+
+// NewReport contains information needed to report a shorturl. Challenge and
+// Nonce are the proof of work the report was sent with.
+type NewReport struct {
+	Code      string `json:"code" validate:"required"`
+	Reason    string `json:"reason" validate:"required,oneof=spam phishing malware other"`
+	Details   string `json:"details" validate:"max=1000"`
+	Challenge string `json:"challenge" validate:"required"`
+	Nonce     string `json:"nonce" validate:"required"`
+}
+
+// Report represents an individual abuse report.
+type Report struct {
+	ID          int       `db:"report_id" json:"id"`
+	ShorturlID  int       `db:"shorturl_id" json:"shorturl_id"`
+	Reason      string    `db:"reason" json:"reason"`
+	Details     string    `db:"details" json:"details"`
+	Challenge   string    `db:"challenge" json:"-"`
+	Status      string    `db:"status" json:"status"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// QueueItem is a shorturl in the moderation queue with its open reports.
+type QueueItem struct {
+	ShorturlID    int            `db:"shorturl_id" json:"id"`
+	Code          string         `db:"-" json:"code"`
+	URL           string         `db:"url" json:"url"`
+	Disabled      bool           `db:"disabled" json:"disabled"`
+	Reports       int            `db:"reports" json:"reports"`
+	Reasons       pq.StringArray `db:"reasons" json:"reasons"`
+	FirstReported time.Time      `db:"first_reported" json:"first_reported"`
+	LastReported  time.Time      `db:"last_reported" json:"last_reported"`
+}
+
+// Moderation contains the action a moderator takes on a shorturl and why.
+type Moderation struct {
+	Action string `json:"action" validate:"required,oneof=disable delete allowlist"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// Action represents a moderation action that was taken. It outlives the
+// shorturl it was taken on.
+type Action struct {
+	ID          int       `db:"action_id" json:"id"`
+	ShorturlID  int       `db:"shorturl_id" json:"shorturl_id"`
+	URL         string    `db:"url" json:"url"`
+	Action      string    `db:"action" json:"action"`
+	Reason      string    `db:"reason" json:"reason"`
+	Moderator   string    `db:"moderator" json:"moderator"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// RescreenConfig holds the parameters of rescreening shorturls against the
+// reputation checker.
+//
+// Every rescreen checks the shorturls not screened within Recheck, Batch at
+// a time.
+type RescreenConfig struct {
+	Batch   int
+	Recheck time.Duration
+}
+
+// Hit is a shorturl that was disabled because a destination matched a
+// threat when it was rescreened.
+type Hit struct {
+	ShorturlID int    `json:"id"`
+	Code       string `json:"code"`
+	URL        string `json:"url"`
+	Threat     string `json:"threat"`
+}
@@ -0,0 +1,143 @@
+package abuse
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/reputation"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// Rescreener is the moderator recorded for shorturls disabled by rescreening.
+const Rescreener = "reputation"
+
+// Rescreen checks the destinations of the shorturls not screened within the
+// recheck interval, the longest unscreened first, against the checker as
+// reputation lists change. Shorturls are checked batch by batch until none
+// is due, or until the context ends; the next rescreen picks up where this
+// one stopped. Shorturls with a destination matching a threat are disabled
+// like a moderator would. Disabled and allowlisted shorturls are left alone,
+// and shorturls the checker failed on are retried on the next rescreen.
+func (a Abuse) Rescreen(ctx context.Context, traceID string, checker reputation.Checker, cfg RescreenConfig, now time.Time) ([]Hit, error) {
+	now = now.UTC()
+
+	hits := []Hit{}
+	failed := []int64{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return hits, errors.Wrap(err, "rescreening")
+		}
+
+		batch, n, err := a.rescreenBatch(ctx, traceID, checker, cfg, &failed, now)
+		if err != nil {
+			return hits, err
+		}
+		hits = append(hits, batch...)
+
+		if n == 0 || n < cfg.Batch {
+			return hits, nil
+		}
+	}
+}
+
+// rescreenBatch checks the next batch of due shorturls, skipping the ones the
+// checker already failed on, which are added to failed. It returns the hits
+// and the number of shorturls in the batch.
+func (a Abuse) rescreenBatch(ctx context.Context, traceID string, checker reputation.Checker, cfg RescreenConfig, failed *[]int64, now time.Time) ([]Hit, int, error) {
+	const q = `
+	SELECT
+		s.shorturl_id, s.url, s.fallback_url, s.prelaunch_url, s.postcampaign_url,
+		ARRAY(SELECT v.url FROM shorturl_variants AS v WHERE v.shorturl_id = s.shorturl_id) AS variants
+	FROM
+		shorturls AS s
+	WHERE
+		NOT s.disabled AND NOT s.allowlisted AND
+		(s.screened_at IS NULL OR s.screened_at <= $1) AND
+		NOT s.shorturl_id = ANY($3)
+	ORDER BY
+		s.screened_at NULLS FIRST, s.shorturl_id
+	LIMIT $2`
+
+	a.log.Printf("%s : %s : query : %s", traceID, "abuse.Rescreen",
+		database.Log(q, now.Add(-cfg.Recheck), cfg.Batch, pq.Array(*failed)))
+
+	var due []struct {
+		ID              int            `db:"shorturl_id"`
+		URL             string         `db:"url"`
+		FallbackURL     string         `db:"fallback_url"`
+		PrelaunchURL    string         `db:"prelaunch_url"`
+		PostcampaignURL string         `db:"postcampaign_url"`
+		Variants        pq.StringArray `db:"variants"`
+	}
+	if err := a.db.SelectContext(ctx, &due, q, now.Add(-cfg.Recheck), cfg.Batch, pq.Array(*failed)); err != nil {
+		return nil, 0, errors.Wrap(err, "selecting due shorturls")
+	}
+
+	hits := []Hit{}
+	screened := make([]int64, 0, len(due))
+	for _, d := range due {
+		urls := append([]string{d.URL, d.FallbackURL, d.PrelaunchURL, d.PostcampaignURL}, d.Variants...)
+
+		hit, err := screen(ctx, checker, urls)
+		if err != nil {
+			a.log.Printf("%s : %s : screening shorturl %d : %v", traceID, "abuse.Rescreen", d.ID, err)
+			*failed = append(*failed, int64(d.ID))
+			continue
+		}
+
+		if hit.Threat != "" {
+			m := Moderation{Action: ActionDisable, Reason: "reputation: " + hit.Threat}
+			if err := a.Moderate(ctx, traceID, Rescreener, d.ID, m, now); err != nil {
+				if errors.Cause(err) == ErrNotFound {
+					continue
+				}
+				return nil, 0, err
+			}
+
+			hit.ShorturlID = d.ID
+			hit.Code = base62.Encode(d.ID)
+			hits = append(hits, hit)
+		}
+		screened = append(screened, int64(d.ID))
+	}
+
+	const qs = `
+	UPDATE
+		shorturls
+	SET
+		"screened_at" = $2
+	WHERE
+		shorturl_id = ANY($1)`
+
+	a.log.Printf("%s : %s : query : %s", traceID, "abuse.Rescreen",
+		database.Log(qs, pq.Array(screened), now))
+
+	if _, err := a.db.ExecContext(ctx, qs, pq.Array(screened), now); err != nil {
+		return nil, 0, errors.Wrap(err, "marking shorturls screened")
+	}
+
+	return hits, len(due), nil
+}
+
+// screen checks the destinations and returns the first one matching a
+// threat.
+func screen(ctx context.Context, checker reputation.Checker, urls []string) (Hit, error) {
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+
+		threat, err := checker.Check(ctx, url)
+		if err != nil {
+			return Hit{}, errors.Wrapf(err, "screening %q", url)
+		}
+		if threat != "" {
+			return Hit{URL: url, Threat: threat}, nil
+		}
+	}
+
+	return Hit{}, nil
+}
@@ -0,0 +1,348 @@
+// Package anomaly watches the click rate of every shorturl and raises alerts
+// on sudden spikes or drops against the shorturl's own baseline. A spike can
+// mean abuse, a drop a broken campaign.
+package anomaly
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mitrovicsinisaa/shorturl/business/alert"
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is used when a shorturl or its rule does not exist.
+var ErrNotFound = errors.New("not found")
+
+// baselineWindow is the window preceding the last hour that the hourly
+// baseline rate is computed from.
+const baselineWindow = 7 * 24 * time.Hour
+
+// Detector manages the set of API's for anomaly detection.
+type Detector struct {
+	log      *log.Logger
+	db       *sqlx.DB
+	notifier alert.Notifier
+}
+
+// New constructs a detector that delivers its alerts through the notifier.
+func New(log *log.Logger, db *sqlx.DB, notifier alert.Notifier) Detector {
+	return Detector{
+		log:      log,
+		db:       db,
+		notifier: notifier,
+	}
+}
+
+// Detect compares the visits of the last complete hour with the hourly
+// baseline of every shorturl and raises an alert for each spike or drop.
+// Rates are read from the click rollups kept by the leaderboard refresh, so
+// it must run after the rollups are brought up to date.
+func (d Detector) Detect(ctx context.Context, traceID string, now time.Time) ([]Info, error) {
+	now = now.UTC()
+
+	// Shorturls without a rule of their own fall back to the workspace rule,
+	// which always exists.
+	const q = `
+	SELECT
+		r.shorturl_id, s.url, r.recent, r.baseline,
+		COALESCE(l.enabled, w.enabled) AS enabled,
+		COALESCE(l.spike_factor, w.spike_factor) AS spike_factor,
+		COALESCE(l.spike_min_visits, w.spike_min_visits) AS spike_min_visits,
+		COALESCE(l.drop_factor, w.drop_factor) AS drop_factor,
+		COALESCE(l.drop_min_baseline, w.drop_min_baseline) AS drop_min_baseline,
+		COALESCE(l.quiet_minutes, w.quiet_minutes) AS quiet_minutes,
+		(SELECT MAX(date_created) FROM alerts AS a WHERE a.shorturl_id = r.shorturl_id) AS last_alert
+	FROM (
+		SELECT
+			shorturl_id,
+			COALESCE(SUM(visits) FILTER (WHERE hour >= $2), 0)::DOUBLE PRECISION AS recent,
+			COALESCE(SUM(visits) FILTER (WHERE hour < $2), 0) / $4::DOUBLE PRECISION AS baseline
+		FROM
+			click_rollups
+		WHERE
+			hour >= $1 AND hour < $3
+		GROUP BY
+			shorturl_id
+	) AS r
+	JOIN
+		shorturls AS s ON s.shorturl_id = r.shorturl_id
+	CROSS JOIN
+		(SELECT * FROM alert_rules WHERE shorturl_id IS NULL) AS w
+	LEFT JOIN
+		alert_rules AS l ON l.shorturl_id = r.shorturl_id
+	ORDER BY
+		r.shorturl_id`
+
+	to := now.Truncate(time.Hour)
+	recentFrom := to.Add(-time.Hour)
+	baselineFrom := recentFrom.Add(-baselineWindow)
+
+	d.log.Printf("%s : %s : query : %s", traceID, "anomaly.Detect",
+		database.Log(q, baselineFrom, recentFrom, to, baselineWindow.Hours()))
+
+	var rates []rate
+	if err := d.db.SelectContext(ctx, &rates, q, baselineFrom, recentFrom, to, baselineWindow.Hours()); err != nil {
+		return nil, errors.Wrap(err, "selecting rates")
+	}
+
+	const qi = `
+	INSERT INTO alerts
+		(shorturl_id, kind, recent_rate, baseline_rate, date_created)
+	VALUES
+		($1, $2, $3, $4, $5)
+	RETURNING
+		alert_id`
+
+	raised := []Info{}
+	for _, r := range rates {
+		kind, ok := evaluate(r, now)
+		if !ok {
+			continue
+		}
+
+		a := Info{
+			ShorturlID:   r.ShorturlID,
+			URL:          r.URL,
+			Kind:         kind,
+			RecentRate:   r.Recent,
+			BaselineRate: r.Baseline,
+			DateCreated:  now,
+		}
+
+		d.log.Printf("%s : %s : query : %s", traceID, "anomaly.Detect",
+			database.Log(qi, a.ShorturlID, a.Kind, a.RecentRate, a.BaselineRate, a.DateCreated))
+
+		if err := d.db.GetContext(ctx, &a.ID, qi, a.ShorturlID, a.Kind, a.RecentRate, a.BaselineRate, a.DateCreated); err != nil {
+			return nil, errors.Wrapf(err, "inserting alert for shorturl %d", a.ShorturlID)
+		}
+		raised = append(raised, a)
+
+		// The alert is recorded either way, so a notifier that is down does
+		// not stop the remaining shorturls from being checked.
+		if err := d.notifier.Notify(ctx, toAlert(a)); err != nil {
+			d.log.Printf("%s : %s : ERROR : notifying alert %d : %v", traceID, "anomaly.Detect", a.ID, err)
+		}
+	}
+
+	return raised, nil
+}
+
+// evaluate decides whether the rates of a shorturl call for an alert under
+// its rule and which kind of alert it is.
+func evaluate(r rate, now time.Time) (string, bool) {
+	if !r.Enabled {
+		return "", false
+	}
+	if r.LastAlert.Valid && now.Sub(r.LastAlert.Time) < time.Duration(r.QuietMinutes)*time.Minute {
+		return "", false
+	}
+
+	switch {
+	case r.Recent >= float64(r.SpikeMinVisits) && r.Recent >= r.SpikeFactor*r.Baseline && r.Recent > r.Baseline:
+		return alert.KindSpike, true
+	case r.Baseline > 0 && r.Baseline >= r.DropMinBaseline && r.Recent <= r.DropFactor*r.Baseline:
+		return alert.KindDrop, true
+	}
+
+	return "", false
+}
+
+// toAlert converts a recorded alert into the form delivered to notifiers.
+func toAlert(a Info) alert.Alert {
+	var msg string
+	switch a.Kind {
+	case alert.KindSpike:
+		msg = fmt.Sprintf("visits spiked to %.0f in the last hour against a baseline of %.1f per hour", a.RecentRate, a.BaselineRate)
+	case alert.KindDrop:
+		msg = fmt.Sprintf("visits dropped to %.0f in the last hour against a baseline of %.1f per hour", a.RecentRate, a.BaselineRate)
+	}
+
+	return alert.Alert{
+		ShorturlID:   a.ShorturlID,
+		Code:         base62.Encode(a.ShorturlID),
+		URL:          a.URL,
+		Kind:         a.Kind,
+		RecentRate:   a.RecentRate,
+		BaselineRate: a.BaselineRate,
+		Message:      msg,
+		Time:         a.DateCreated,
+	}
+}
+
+// Query retrieves a list of raised alerts, most recent first.
+func (d Detector) Query(ctx context.Context, traceID string, pageNumber int, rowsPerPage int) ([]Info, error) {
+
+	const q = `
+	SELECT
+		a.alert_id, a.shorturl_id, s.url, a.kind, a.recent_rate, a.baseline_rate, a.date_created
+	FROM
+		alerts AS a
+	JOIN
+		shorturls AS s ON s.shorturl_id = a.shorturl_id
+	ORDER BY
+		a.date_created DESC, a.alert_id DESC
+	OFFSET $1 ROWS FETCH NEXT $2 ROWS ONLY`
+
+	d.log.Printf("%s : %s : query : %s", traceID, "anomaly.Query",
+		database.Log(q, pageNumber, rowsPerPage))
+
+	alerts := []Info{}
+	if err := d.db.SelectContext(ctx, &alerts, q, pageNumber, rowsPerPage); err != nil {
+		return nil, errors.Wrap(err, "selecting alerts")
+	}
+
+	return alerts, nil
+}
+
+// QueryWorkspaceRule retrieves the rule that applies to every shorturl
+// without a rule of its own.
+func (d Detector) QueryWorkspaceRule(ctx context.Context, traceID string) (Rule, error) {
+
+	const q = `
+	SELECT
+		enabled, spike_factor, spike_min_visits, drop_factor, drop_min_baseline, quiet_minutes, date_updated
+	FROM
+		alert_rules
+	WHERE
+		shorturl_id IS NULL`
+
+	d.log.Printf("%s : %s : query : %s", traceID, "anomaly.QueryWorkspaceRule",
+		database.Log(q))
+
+	var r Rule
+	if err := d.db.GetContext(ctx, &r, q); err != nil {
+		return Rule{}, errors.Wrap(err, "selecting workspace rule")
+	}
+
+	return r, nil
+}
+
+// UpdateWorkspaceRule replaces the thresholds of the workspace rule.
+func (d Detector) UpdateWorkspaceRule(ctx context.Context, traceID string, nr NewRule, now time.Time) (Rule, error) {
+	r := toRule(nr, now)
+
+	const q = `
+	UPDATE
+		alert_rules
+	SET
+		"enabled" = $1,
+		"spike_factor" = $2,
+		"spike_min_visits" = $3,
+		"drop_factor" = $4,
+		"drop_min_baseline" = $5,
+		"quiet_minutes" = $6,
+		"date_updated" = $7
+	WHERE
+		shorturl_id IS NULL`
+
+	args := []interface{}{r.Enabled, r.SpikeFactor, r.SpikeMinVisits, r.DropFactor, r.DropMinBaseline, r.QuietMinutes, r.DateUpdated}
+
+	d.log.Printf("%s : %s : query : %s", traceID, "anomaly.UpdateWorkspaceRule",
+		database.Log(q, args...))
+
+	if _, err := d.db.ExecContext(ctx, q, args...); err != nil {
+		return Rule{}, errors.Wrap(err, "updating workspace rule")
+	}
+
+	return r, nil
+}
+
+// QueryRule retrieves the rule of the specified shorturl.
+func (d Detector) QueryRule(ctx context.Context, traceID string, shorturlID int) (Rule, error) {
+
+	const q = `
+	SELECT
+		enabled, spike_factor, spike_min_visits, drop_factor, drop_min_baseline, quiet_minutes, date_updated
+	FROM
+		alert_rules
+	WHERE
+		shorturl_id = $1`
+
+	d.log.Printf("%s : %s : query : %s", traceID, "anomaly.QueryRule",
+		database.Log(q, shorturlID))
+
+	var r Rule
+	if err := d.db.GetContext(ctx, &r, q, shorturlID); err != nil {
+		if err == sql.ErrNoRows {
+			return Rule{}, ErrNotFound
+		}
+		return Rule{}, errors.Wrapf(err, "selecting rule for shorturl %d", shorturlID)
+	}
+
+	return r, nil
+}
+
+// SaveRule creates or replaces the rule of the specified shorturl, which then
+// takes precedence over the workspace rule.
+func (d Detector) SaveRule(ctx context.Context, traceID string, shorturlID int, nr NewRule, now time.Time) (Rule, error) {
+	r := toRule(nr, now)
+
+	const q = `
+	INSERT INTO alert_rules
+		(shorturl_id, enabled, spike_factor, spike_min_visits, drop_factor, drop_min_baseline, quiet_minutes, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (shorturl_id) DO UPDATE SET
+		enabled = EXCLUDED.enabled,
+		spike_factor = EXCLUDED.spike_factor,
+		spike_min_visits = EXCLUDED.spike_min_visits,
+		drop_factor = EXCLUDED.drop_factor,
+		drop_min_baseline = EXCLUDED.drop_min_baseline,
+		quiet_minutes = EXCLUDED.quiet_minutes,
+		date_updated = EXCLUDED.date_updated`
+
+	args := []interface{}{shorturlID, r.Enabled, r.SpikeFactor, r.SpikeMinVisits, r.DropFactor, r.DropMinBaseline, r.QuietMinutes, r.DateUpdated}
+
+	d.log.Printf("%s : %s : query : %s", traceID, "anomaly.SaveRule",
+		database.Log(q, args...))
+
+	if _, err := d.db.ExecContext(ctx, q, args...); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return Rule{}, ErrNotFound
+		}
+		return Rule{}, errors.Wrapf(err, "saving rule for shorturl %d", shorturlID)
+	}
+
+	return r, nil
+}
+
+// DeleteRule removes the rule of the specified shorturl so the workspace rule
+// applies to it again.
+func (d Detector) DeleteRule(ctx context.Context, traceID string, shorturlID int) error {
+
+	const q = `
+	DELETE FROM
+		alert_rules
+	WHERE
+		shorturl_id = $1`
+
+	d.log.Printf("%s : %s : query : %s", traceID, "anomaly.DeleteRule",
+		database.Log(q, shorturlID))
+
+	if _, err := d.db.ExecContext(ctx, q, shorturlID); err != nil {
+		return errors.Wrapf(err, "deleting rule for shorturl %d", shorturlID)
+	}
+
+	return nil
+}
+
+// toRule converts the thresholds being saved into a rule.
+func toRule(nr NewRule, now time.Time) Rule {
+	return Rule{
+		Enabled:         nr.Enabled,
+		SpikeFactor:     nr.SpikeFactor,
+		SpikeMinVisits:  nr.SpikeMinVisits,
+		DropFactor:      nr.DropFactor,
+		DropMinBaseline: nr.DropMinBaseline,
+		QuietMinutes:    nr.QuietMinutes,
+		DateUpdated:     now.UTC(),
+	}
+}
@@ -0,0 +1,135 @@
+package anomaly_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/alert"
+	"github.com/mitrovicsinisaa/shorturl/business/data/anomaly"
+	"github.com/mitrovicsinisaa/shorturl/business/data/leaderboard"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+// recorder is a notifier that keeps every alert it is given.
+type recorder struct {
+	alerts []alert.Alert
+}
+
+func (r *recorder) Notify(ctx context.Context, a alert.Alert) error {
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+func TestDetect(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	su := shorturl.New(log, db, nil)
+	lb := leaderboard.New(log, db)
+	rec := recorder{}
+	d := anomaly.New(log, db, &rec)
+
+	t.Log("Given the need to raise alerts on unusual click rates.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen one shorturl spikes and another goes quiet.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.June, 10, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			workspace := anomaly.NewRule{
+				Enabled:         true,
+				SpikeFactor:     5,
+				SpikeMinVisits:  10,
+				DropFactor:      0.2,
+				DropMinBaseline: 1,
+				QuietMinutes:    60,
+			}
+			if _, err := d.UpdateWorkspaceRule(ctx, traceID, workspace, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update the workspace rule : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update the workspace rule.", tests.Success, testID)
+
+			hot, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://example.com/hot"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			quiet, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://example.com/quiet"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			// The quiet shorturl only gets a visit a day, which is below the
+			// workspace baseline, so it needs a rule of its own.
+			rule := workspace
+			rule.DropMinBaseline = 0
+			if _, err := d.SaveRule(ctx, traceID, quiet.ID, rule, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to save a shorturl rule : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to save a shorturl rule.", tests.Success, testID)
+
+			for i := 0; i < 12; i++ {
+				if _, err := su.QueryByID(ctx, traceID, hot.ID, shorturl.NewVisit{}, now.Add(-30*time.Minute)); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to visit shorturl : %s.", tests.Failed, testID, err)
+				}
+			}
+			for day := 1; day <= 3; day++ {
+				if _, err := su.QueryByID(ctx, traceID, quiet.ID, shorturl.NewVisit{}, now.Add(-time.Duration(day)*24*time.Hour)); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to visit shorturl : %s.", tests.Failed, testID, err)
+				}
+			}
+
+			if err := lb.Refresh(ctx, traceID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to roll up clicks : %s.", tests.Failed, testID, err)
+			}
+
+			raised, err := d.Detect(ctx, traceID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to detect anomalies : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to detect anomalies.", tests.Success, testID)
+
+			if len(raised) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould raise two alerts : %+v.", tests.Failed, testID, raised)
+			}
+			if raised[0].ShorturlID != hot.ID || raised[0].Kind != alert.KindSpike {
+				t.Fatalf("\t%s\tTest %d:\tShould raise a spike for the hot shorturl : %+v.", tests.Failed, testID, raised[0])
+			}
+			if raised[1].ShorturlID != quiet.ID || raised[1].Kind != alert.KindDrop {
+				t.Fatalf("\t%s\tTest %d:\tShould raise a drop for the quiet shorturl : %+v.", tests.Failed, testID, raised[1])
+			}
+			t.Logf("\t%s\tTest %d:\tShould raise a spike and a drop.", tests.Success, testID)
+
+			if len(rec.alerts) != 2 || rec.alerts[0].Message == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould notify both alerts : %+v.", tests.Failed, testID, rec.alerts)
+			}
+			t.Logf("\t%s\tTest %d:\tShould notify both alerts.", tests.Success, testID)
+
+			raised, err = d.Detect(ctx, traceID, now.Add(30*time.Minute))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to detect anomalies : %s.", tests.Failed, testID, err)
+			}
+			if len(raised) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT raise alerts within the quiet period : %+v.", tests.Failed, testID, raised)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT raise alerts within the quiet period.", tests.Success, testID)
+
+			alerts, err := d.Query(ctx, traceID, 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query alerts : %s.", tests.Failed, testID, err)
+			}
+			if len(alerts) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould list both alerts : %+v.", tests.Failed, testID, alerts)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list both alerts.", tests.Success, testID)
+
+			if _, err := d.SaveRule(ctx, traceID, 9999, rule, now); err != anomaly.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to save a rule for a missing shorturl : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to save a rule for a missing shorturl.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package anomaly
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Rule holds the thresholds an alert is raised on. The workspace rule applies
+// to every shorturl without a rule of its own.
+type Rule struct {
+	Enabled         bool      `db:"enabled" json:"enabled"`
+	SpikeFactor     float64   `db:"spike_factor" json:"spike_factor"`
+	SpikeMinVisits  int       `db:"spike_min_visits" json:"spike_min_visits"`
+	DropFactor      float64   `db:"drop_factor" json:"drop_factor"`
+	DropMinBaseline float64   `db:"drop_min_baseline" json:"drop_min_baseline"`
+	QuietMinutes    int       `db:"quiet_minutes" json:"quiet_minutes"`
+	DateUpdated     time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// NewRule contains the thresholds of a rule being saved.
+//
+// A spike is raised when the visits of the last hour reach SpikeFactor times
+// the hourly baseline and at least SpikeMinVisits. A drop is raised when the
+// visits of the last hour fall to DropFactor times the hourly baseline while
+// the baseline is at least DropMinBaseline. No further alert is raised for a
+// shorturl within QuietMinutes of its last one.
+type NewRule struct {
+	Enabled         bool    `json:"enabled"`
+	SpikeFactor     float64 `json:"spike_factor" validate:"gt=1"`
+	SpikeMinVisits  int     `json:"spike_min_visits" validate:"gte=0"`
+	DropFactor      float64 `json:"drop_factor" validate:"gte=0,lt=1"`
+	DropMinBaseline float64 `json:"drop_min_baseline" validate:"gte=0"`
+	QuietMinutes    int     `json:"quiet_minutes" validate:"gte=0"`
+}
+
+// Info represents an alert that was raised.
+type Info struct {
+	ID           int       `db:"alert_id" json:"alert_id"`
+	ShorturlID   int       `db:"shorturl_id" json:"id"`
+	URL          string    `db:"url" json:"url"`
+	Kind         string    `db:"kind" json:"kind"`
+	RecentRate   float64   `db:"recent_rate" json:"recent_rate"`
+	BaselineRate float64   `db:"baseline_rate" json:"baseline_rate"`
+	DateCreated  time.Time `db:"date_created" json:"date_created"`
+}
+
+// rate holds the click rates of a shorturl together with the rule that
+// applies to it.
+type rate struct {
+	ShorturlID      int          `db:"shorturl_id"`
+	URL             string       `db:"url"`
+	Recent          float64      `db:"recent"`
+	Baseline        float64      `db:"baseline"`
+	Enabled         bool         `db:"enabled"`
+	SpikeFactor     float64      `db:"spike_factor"`
+	SpikeMinVisits  int          `db:"spike_min_visits"`
+	DropFactor      float64      `db:"drop_factor"`
+	DropMinBaseline float64      `db:"drop_min_baseline"`
+	QuietMinutes    int          `db:"quiet_minutes"`
+	LastAlert       sql.NullTime `db:"last_alert"`
+}
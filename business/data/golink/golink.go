@@ -0,0 +1,365 @@
+// Package golink contains go links related CRUD functionality. A go link maps
+// a short keyword to a destination template that is expanded with the extra
+// path segments and query parameters of each request.
+package golink
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mitrovicsinisaa/shorturl/business/auth"
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/reputation"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific Golink is requested but does not exists.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidKeyword occurs when a keyword is not in its proper form.
+	ErrInvalidKeyword = errors.New("keyword must contain only lowercase letters, digits, '-', '_' and '.'")
+
+	// ErrKeywordExists occurs when a keyword is already taken.
+	ErrKeywordExists = errors.New("keyword already exists")
+
+	// ErrKeywordIsCode occurs when a keyword is the code of an existing
+	// shorturl.
+	ErrKeywordIsCode = errors.New("keyword is the code of an existing shorturl")
+
+	// ErrMissingParam occurs when a template placeholder has no value.
+	ErrMissingParam = errors.New("missing template parameter")
+
+	// ErrForbidden occurs when a user tries to do something that is forbiden.
+	ErrForbidden = errors.New("action is not allowed")
+
+	// ErrMalicious occurs when a template matches a list of known malicious
+	// URLs.
+	ErrMalicious = errors.New("template has a bad reputation")
+)
+
+// keywordRE describes the keywords that can be used as a single path segment.
+var keywordRE = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// reserved keywords are taken by the routes of the service itself.
+var reserved = map[string]bool{
+	"api":       true,
+	"readiness": true,
+	"liveness":  true,
+}
+
+// placeholderRE matches the {1} and {name} placeholders of a template.
+var placeholderRE = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// likeEscaper escapes the wildcards of LIKE patterns, with backslash as the
+// escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Golink manages the set of API's for golink access.
+type Golink struct {
+	log     *log.Logger
+	db      *sqlx.DB
+	checker reputation.Checker
+}
+
+// New constructs a golink for api access. Templates are screened with the
+// checker when one is given.
+func New(log *log.Logger, db *sqlx.DB, checker reputation.Checker) Golink {
+	return Golink{
+		log:     log,
+		db:      db,
+		checker: checker,
+	}
+}
+
+// Create inserts a new golink owned by the calling user into the database.
+func (g Golink) Create(ctx context.Context, traceID string, claims auth.Claims, ngl NewGolink, now time.Time) (Info, error) {
+
+	keyword := strings.ToLower(ngl.Keyword)
+	if !keywordRE.MatchString(keyword) || reserved[keyword] {
+		return Info{}, ErrInvalidKeyword
+	}
+
+	if err := g.checkCode(ctx, traceID, keyword); err != nil {
+		return Info{}, err
+	}
+
+	if err := g.screen(ctx, ngl.Template); err != nil {
+		return Info{}, err
+	}
+
+	gl := Info{
+		Keyword:     keyword,
+		Template:    ngl.Template,
+		Description: ngl.Description,
+		OwnerID:     claims.Subject,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO golinks
+		(keyword, template, description, owner_id, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5, $6)`
+
+	g.log.Printf("%s : %s : query : %s", traceID, "golink.Create",
+		database.Log(q, gl.Keyword, gl.Template, gl.Description, gl.OwnerID, gl.DateCreated, gl.DateUpdated))
+
+	if _, err := g.db.ExecContext(ctx, q, gl.Keyword, gl.Template, gl.Description, gl.OwnerID, gl.DateCreated, gl.DateUpdated); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return Info{}, ErrKeywordExists
+		}
+		return Info{}, errors.Wrap(err, "inserting golink")
+	}
+
+	return gl, nil
+}
+
+// Delete removes a golink from the database. Only the owner or an admin can
+// delete a golink.
+func (g Golink) Delete(ctx context.Context, traceID string, claims auth.Claims, keyword string) error {
+	keyword = strings.ToLower(keyword)
+
+	const q = `
+	DELETE FROM
+		golinks
+	WHERE
+		keyword = $1 AND
+		($2 OR owner_id::TEXT = $3)`
+
+	admin := claims.Authorize(auth.RoleAdmin)
+
+	g.log.Printf("%s : %s : query : %s", traceID, "golink.Delete",
+		database.Log(q, keyword, admin, claims.Subject))
+
+	res, err := g.db.ExecContext(ctx, q, keyword, admin, claims.Subject)
+	if err != nil {
+		return errors.Wrapf(err, "deleting golink %s", keyword)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if _, err := g.queryByKeyword(ctx, traceID, keyword); err != nil {
+			return err
+		}
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// Search retrieves a page of golinks whose keyword or description contains
+// the search text. An empty text lists every golink.
+func (g Golink) Search(ctx context.Context, traceID string, text string, pageNumber int, rowsPerPage int) ([]Info, error) {
+
+	const q = `
+	SELECT
+		gl.keyword, gl.template, gl.description, gl.visits, gl.date_created, gl.date_updated,
+		COALESCE(gl.owner_id::TEXT, '') AS owner_id,
+		COALESCE(u.name, '') AS owner_name
+	FROM
+		golinks AS gl
+	LEFT JOIN
+		users AS u ON u.user_id = gl.owner_id
+	WHERE
+		gl.keyword ILIKE '%' || $1 || '%' ESCAPE '\' OR
+		gl.description ILIKE '%' || $1 || '%' ESCAPE '\'
+	ORDER BY
+		gl.keyword
+	OFFSET $2 ROWS FETCH NEXT $3 ROWS ONLY`
+
+	text = likeEscaper.Replace(text)
+
+	g.log.Printf("%s : %s : query : %s", traceID, "golink.Search",
+		database.Log(q, text, pageNumber, rowsPerPage))
+
+	golinks := []Info{}
+	if err := g.db.SelectContext(ctx, &golinks, q, text, pageNumber, rowsPerPage); err != nil {
+		return nil, errors.Wrap(err, "selecting golinks")
+	}
+
+	return golinks, nil
+}
+
+// Suggest retrieves up to limit keywords starting with the specified prefix.
+func (g Golink) Suggest(ctx context.Context, traceID string, prefix string, limit int) ([]string, error) {
+
+	const q = `
+	SELECT
+		keyword
+	FROM
+		golinks
+	WHERE
+		keyword LIKE $1 || '%' ESCAPE '\'
+	ORDER BY
+		visits DESC, keyword
+	LIMIT $2`
+
+	prefix = likeEscaper.Replace(strings.ToLower(prefix))
+
+	g.log.Printf("%s : %s : query : %s", traceID, "golink.Suggest",
+		database.Log(q, prefix, limit))
+
+	keywords := []string{}
+	if err := g.db.SelectContext(ctx, &keywords, q, prefix, limit); err != nil {
+		return nil, errors.Wrap(err, "selecting golink keywords")
+	}
+
+	return keywords, nil
+}
+
+// QueryByKeyword gets the specified golink from the database and records
+// the visit.
+func (g Golink) QueryByKeyword(ctx context.Context, traceID string, keyword string) (Info, error) {
+	keyword = strings.ToLower(keyword)
+
+	const q = `
+	UPDATE
+		golinks
+	SET
+		visits = visits + 1
+	WHERE
+		keyword = $1
+		RETURNING keyword, template, description, visits, date_created, date_updated,
+			COALESCE(owner_id::TEXT, '') AS owner_id`
+
+	g.log.Printf("%s : %s : query : %s", traceID, "golink.QueryByKeyword",
+		database.Log(q, keyword))
+
+	var gl Info
+	if err := g.db.GetContext(ctx, &gl, q, keyword); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting golink %q", keyword)
+	}
+
+	return gl, nil
+}
+
+// queryByKeyword gets the specified golink without recording a visit.
+func (g Golink) queryByKeyword(ctx context.Context, traceID string, keyword string) (Info, error) {
+
+	const q = `
+	SELECT
+		keyword, template, description, visits, date_created, date_updated,
+		COALESCE(owner_id::TEXT, '') AS owner_id
+	FROM
+		golinks
+	WHERE
+		keyword = $1`
+
+	g.log.Printf("%s : %s : query : %s", traceID, "golink.queryByKeyword",
+		database.Log(q, keyword))
+
+	var gl Info
+	if err := g.db.GetContext(ctx, &gl, q, keyword); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting golink %q", keyword)
+	}
+
+	return gl, nil
+}
+
+// Expand replaces the placeholders of a template. Numbered placeholders such
+// as {1} take the positional arguments, other placeholders such as {id} take
+// the named arguments. Values are escaped for use inside a URL path, or as
+// query values when the placeholder is in the query of the template, so a
+// value can never add parameters of its own.
+func Expand(template string, positional []string, named url.Values) (string, error) {
+	query := strings.Index(template, "?")
+
+	var missing string
+	var b strings.Builder
+	last := 0
+	for _, m := range placeholderRE.FindAllStringSubmatchIndex(template, -1) {
+		b.WriteString(template[last:m[0]])
+		last = m[1]
+
+		escape := url.PathEscape
+		if query >= 0 && m[0] > query {
+			escape = url.QueryEscape
+		}
+
+		name := template[m[2]:m[3]]
+		if n, err := strconv.Atoi(name); err == nil {
+			if n >= 1 && n <= len(positional) && positional[n-1] != "" {
+				b.WriteString(escape(positional[n-1]))
+				continue
+			}
+		} else if v := named.Get(name); v != "" {
+			b.WriteString(escape(v))
+			continue
+		}
+
+		if missing == "" {
+			missing = name
+		}
+		b.WriteString(template[m[0]:m[1]])
+	}
+	b.WriteString(template[last:])
+
+	if missing != "" {
+		return "", errors.Wrapf(ErrMissingParam, "{%s}", missing)
+	}
+
+	return b.String(), nil
+}
+
+// screen checks the template with the reputation checker, if there is one.
+// Placeholders are dropped so the fixed part of the template, usually its
+// host, is what gets screened.
+func (g Golink) screen(ctx context.Context, template string) error {
+	if g.checker == nil {
+		return nil
+	}
+
+	threat, err := g.checker.Check(ctx, placeholderRE.ReplaceAllString(template, ""))
+	if err != nil {
+		return errors.Wrapf(err, "screening %q", template)
+	}
+	if threat != "" {
+		return errors.Wrapf(ErrMalicious, "%s matched %s", template, threat)
+	}
+
+	return nil
+}
+
+// checkCode rejects keywords that are the code of an existing shorturl.
+// Shorturl codes are resolved before keywords, such a go link could never be
+// visited.
+func (g Golink) checkCode(ctx context.Context, traceID string, keyword string) error {
+	shorturlID, err := base62.Decode(keyword)
+	if err != nil {
+		return nil
+	}
+
+	const q = `
+	SELECT EXISTS (
+		SELECT 1 FROM shorturls WHERE shorturl_id = $1
+	)`
+
+	g.log.Printf("%s : %s : query : %s", traceID, "golink.checkCode",
+		database.Log(q, shorturlID))
+
+	var exists bool
+	if err := g.db.GetContext(ctx, &exists, q, shorturlID); err != nil {
+		return errors.Wrapf(err, "checking keyword %q", keyword)
+	}
+	if exists {
+		return ErrKeywordIsCode
+	}
+
+	return nil
+}
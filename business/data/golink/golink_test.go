@@ -0,0 +1,166 @@
+package golink_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/mitrovicsinisaa/shorturl/business/auth"
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/data/golink"
+	"github.com/mitrovicsinisaa/shorturl/business/data/schema"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/reputation"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+	"github.com/pkg/errors"
+)
+
+func TestGolink(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	gl := golink.New(log, db, nil)
+
+	t.Log("Given the need to work with Golink records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Golink.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			claims := auth.Claims{
+				StandardClaims: jwt.StandardClaims{
+					Issuer:    "service project",
+					Subject:   "ac8e61dc-d4f6-11eb-b8bc-0242ac130003",
+					ExpiresAt: now.Add(time.Hour).Unix(),
+					IssuedAt:  now.Unix(),
+				},
+				Roles: []string{auth.RoleUser},
+			}
+
+			ngl := golink.NewGolink{
+				Keyword:     "bug",
+				Template:    "https://tracker.example/issue/{1}",
+				Description: "Open an issue in the tracker",
+			}
+
+			if _, err := gl.Create(ctx, traceID, claims, ngl, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create golink : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create golink.", tests.Success, testID)
+
+			if _, err := gl.Create(ctx, traceID, claims, ngl, now); errors.Cause(err) != golink.ErrKeywordExists {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create golink twice : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create golink twice.", tests.Success, testID)
+
+			found, err := gl.Search(ctx, traceID, "tracker", 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to search golinks : %s.", tests.Failed, testID, err)
+			}
+			if len(found) != 1 || found[0].OwnerName != "User Gopher" {
+				t.Fatalf("\t%s\tTest %d:\tShould find the golink with its owner : %+v.", tests.Failed, testID, found)
+			}
+			t.Logf("\t%s\tTest %d:\tShould find the golink with its owner.", tests.Success, testID)
+
+			found, err = gl.Search(ctx, traceID, "%", 0, 10)
+			if err != nil || len(found) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould search for wildcards literally : %+v : %v.", tests.Failed, testID, found, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould search for wildcards literally.", tests.Success, testID)
+
+			keywords, err := gl.Suggest(ctx, traceID, "b", 10)
+			if err != nil || len(keywords) != 1 || keywords[0] != "bug" {
+				t.Fatalf("\t%s\tTest %d:\tShould suggest the keyword : %v %s.", tests.Failed, testID, keywords, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould suggest the keyword.", tests.Success, testID)
+
+			if _, err := gl.QueryByKeyword(ctx, traceID, "Bug"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve golink : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve golink.", tests.Success, testID)
+
+			if err := gl.Delete(ctx, traceID, claims, "BUG"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete golink : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete golink.", tests.Success, testID)
+
+			if _, err := gl.QueryByKeyword(ctx, traceID, "bug"); errors.Cause(err) != golink.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve golink : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve golink.", tests.Success, testID)
+
+			screened := golink.New(log, db, reputation.NewBlocklist("blocklist", []string{"phishing.example"}))
+			bad := golink.NewGolink{
+				Keyword:  "login",
+				Template: "https://{1}.phishing.example/login?user={user}",
+			}
+			if _, err := screened.Create(ctx, traceID, claims, bad, now); errors.Cause(err) != golink.ErrMalicious {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create golink to a blocked destination : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create golink to a blocked destination.", tests.Success, testID)
+
+			surl, err := shorturl.New(log, db, nil).Create(ctx, traceID, shorturl.NewShorturl{URL: "https://github.com/mitrovicsinisaa/shorturl"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			code := golink.NewGolink{
+				Keyword:  base62.Encode(surl.ID),
+				Template: "https://phishing.example/",
+			}
+			if _, err := gl.Create(ctx, traceID, claims, code, now); errors.Cause(err) != golink.ErrKeywordIsCode {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to take over a shorturl code : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to take over a shorturl code.", tests.Success, testID)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tt := []struct {
+		name       string
+		template   string
+		positional []string
+		named      url.Values
+		want       string
+	}{
+		{"positional", "https://tracker.example/issue/{1}", []string{"1234"}, nil, "https://tracker.example/issue/1234"},
+		{"named", "https://tracker.example/{project}/issue/{1}", []string{"1234"}, url.Values{"project": {"core"}}, "https://tracker.example/core/issue/1234"},
+		{"escaped", "https://wiki.example/search/{1}", []string{"a b"}, nil, "https://wiki.example/search/a%20b"},
+		{"plain", "https://wiki.example", []string{"ignored"}, nil, "https://wiki.example"},
+		{"query", "https://wiki.example/search/{1}?q={q}", []string{"a&b"}, url.Values{"q": {"a&admin=1 +"}}, "https://wiki.example/search/a&b?q=a%26admin%3D1+%2B"},
+	}
+
+	t.Log("Given the need to expand golink templates.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen handling the %s case.", testID, tst.name)
+			{
+				got, err := golink.Expand(tst.template, tst.positional, tst.named)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to expand the template : %s.", tests.Failed, testID, err)
+				}
+				if got != tst.want {
+					t.Fatalf("\t%s\tTest %d:\tShould get %q : got %q.", tests.Failed, testID, tst.want, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected destination.", tests.Success, testID)
+			}
+		}
+
+		testID := len(tt)
+		t.Logf("\tTest %d:\tWhen a parameter is missing.", testID)
+		{
+			if _, err := golink.Expand("https://tracker.example/issue/{1}", nil, nil); errors.Cause(err) != golink.ErrMissingParam {
+				t.Fatalf("\t%s\tTest %d:\tShould fail with a missing parameter : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail with a missing parameter.", tests.Success, testID)
+		}
+	}
+}
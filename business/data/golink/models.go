@@ -0,0 +1,24 @@
+package golink
+
+import (
+	"time"
+)
+
+// Info represents an individual Golink.
+type Info struct {
+	Keyword     string    `db:"keyword" json:"keyword"`
+	Template    string    `db:"template" json:"template"`
+	Description string    `db:"description" json:"description"`
+	OwnerID     string    `db:"owner_id" json:"owner_id"`
+	OwnerName   string    `db:"owner_name" json:"owner_name"`
+	Visits      int       `db:"visits" json:"visits"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// NewGolink contains information needed to create a new Golink.
+type NewGolink struct {
+	Keyword     string `json:"keyword" validate:"required,max=64"`
+	Template    string `json:"template" validate:"required"`
+	Description string `json:"description"`
+}
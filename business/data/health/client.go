@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrForbiddenAddress occurs when a destination resolves to an address on
+// the network of the service rather than the public internet.
+var ErrForbiddenAddress = errors.New("destination address is not public")
+
+// privateNets are the private address ranges of RFC 1918, RFC 6598 and RFC
+// 4193.
+var privateNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// NewClient constructs the client destinations are probed with. Anyone can
+// create a shorturl, so the client refuses to connect to loopback, private,
+// link-local and unspecified addresses. The address is checked after it was
+// resolved, which covers redirects and host names pointing inside as well.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			return checkAddress(address)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// checkAddress returns ErrForbiddenAddress for a resolved address that isn't
+// public.
+func checkAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrap(err, "parsing address")
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Wrapf(ErrForbiddenAddress, "%s", address)
+	}
+
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return errors.Wrapf(ErrForbiddenAddress, "%s", address)
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return errors.Wrapf(ErrForbiddenAddress, "%s", address)
+		}
+	}
+
+	return nil
+}
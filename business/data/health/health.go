@@ -0,0 +1,210 @@
+// Package health checks that the destinations of shorturls still respond.
+// Destinations are probed on an interval, the outcome is stored with the
+// shorturl and a destination failing for a number of checks in a row marks
+// the shorturl broken and notifies the people watching the links.
+package health
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mitrovicsinisaa/shorturl/business/alert"
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// maxErrorLength bounds the error stored for failed checks.
+const maxErrorLength = 512
+
+// Monitor manages the set of API's for destination health checks.
+type Monitor struct {
+	log      *log.Logger
+	db       *sqlx.DB
+	client   *http.Client
+	limiter  *limiter
+	notifier alert.Notifier
+	cfg      Config
+}
+
+// New constructs a Monitor that probes destinations with the client and
+// delivers its alerts through the notifier.
+func New(log *log.Logger, db *sqlx.DB, client *http.Client, notifier alert.Notifier, cfg Config) Monitor {
+	return Monitor{
+		log:      log,
+		db:       db,
+		client:   client,
+		limiter:  newLimiter(cfg.HostInterval),
+		notifier: notifier,
+		cfg:      cfg,
+	}
+}
+
+// Check probes the destinations not checked within the recheck interval, the
+// longest unchecked first, and stores the outcome with each shorturl.
+func (m Monitor) Check(ctx context.Context, traceID string, now time.Time) ([]Info, error) {
+	now = now.UTC()
+
+	const q = `
+	SELECT
+		shorturl_id, url
+	FROM
+		shorturls
+	WHERE
+		health_checked IS NULL OR health_checked <= $1
+	ORDER BY
+		health_checked NULLS FIRST, shorturl_id
+	LIMIT $2`
+
+	m.log.Printf("%s : %s : query : %s", traceID, "health.Check",
+		database.Log(q, now.Add(-m.cfg.Recheck), m.cfg.Batch))
+
+	var due []struct {
+		ID  int    `db:"shorturl_id"`
+		URL string `db:"url"`
+	}
+	if err := m.db.SelectContext(ctx, &due, q, now.Add(-m.cfg.Recheck), m.cfg.Batch); err != nil {
+		return nil, errors.Wrap(err, "selecting due shorturls")
+	}
+
+	// Destinations are probed concurrently, the limiter keeps requests to a
+	// single host apart.
+	checked := make([]Info, len(due))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	workers := m.cfg.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				checked[i] = m.probe(ctx, due[i].ID, due[i].URL)
+			}
+		}()
+	}
+	for i := range due {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	list := make([]Info, 0, len(checked))
+	for _, hi := range checked {
+		if hi.Skipped {
+			continue
+		}
+
+		saved, err := m.save(ctx, traceID, hi, now)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, saved)
+
+		if saved.Failures == m.cfg.Threshold {
+			m.notify(ctx, traceID, saved, now)
+		}
+	}
+
+	return list, nil
+}
+
+// probe checks a single destination once the host allows it. A probe cut
+// short by the context is skipped rather than counted as a failure.
+func (m Monitor) probe(ctx context.Context, id int, destination string) Info {
+	hi := Info{
+		ShorturlID: id,
+		URL:        destination,
+	}
+
+	if err := m.limiter.wait(ctx, destination); err != nil {
+		if ctx.Err() != nil {
+			hi.Skipped = true
+			return hi
+		}
+		hi.Error = err.Error()
+		return hi
+	}
+
+	res := Probe(ctx, m.client, destination)
+	if ctx.Err() != nil {
+		hi.Skipped = true
+		return hi
+	}
+
+	hi.StatusCode = res.StatusCode
+	hi.LatencyMS = int(res.Latency / time.Millisecond)
+	hi.Healthy = res.Healthy()
+	if res.Err != nil {
+		hi.Error = res.Err.Error()
+	}
+	if len(hi.Error) > maxErrorLength {
+		hi.Error = hi.Error[:maxErrorLength]
+	}
+
+	return hi
+}
+
+// save stores the outcome of a check and returns it with the number of
+// failed checks in a row.
+func (m Monitor) save(ctx context.Context, traceID string, hi Info, now time.Time) (Info, error) {
+
+	const q = `
+	UPDATE
+		shorturls
+	SET
+		"health_status" = $2,
+		"health_latency_ms" = $3,
+		"health_error" = $4,
+		"health_checked" = $5,
+		"health_failures" = CASE WHEN $6 THEN 0 ELSE health_failures + 1 END,
+		"broken" = NOT $6 AND health_failures + 1 >= $7
+	WHERE
+		shorturl_id = $1
+		RETURNING health_failures, broken`
+
+	args := []interface{}{hi.ShorturlID, hi.StatusCode, hi.LatencyMS, hi.Error, now, hi.Healthy, m.cfg.Threshold}
+
+	m.log.Printf("%s : %s : query : %s", traceID, "health.save",
+		database.Log(q, args...))
+
+	var row struct {
+		Failures int  `db:"health_failures"`
+		Broken   bool `db:"broken"`
+	}
+	if err := m.db.GetContext(ctx, &row, q, args...); err != nil {
+		return Info{}, errors.Wrapf(err, "updating health of shorturl %d", hi.ShorturlID)
+	}
+	hi.Failures = row.Failures
+	hi.Broken = row.Broken
+
+	return hi, nil
+}
+
+// notify tells the people watching the links that a destination keeps
+// failing. Notifier errors are logged, the outcome is already stored.
+func (m Monitor) notify(ctx context.Context, traceID string, hi Info, now time.Time) {
+	reason := hi.Error
+	if reason == "" {
+		reason = fmt.Sprintf("status %d", hi.StatusCode)
+	}
+
+	a := alert.Alert{
+		ShorturlID: hi.ShorturlID,
+		Code:       base62.Encode(hi.ShorturlID),
+		URL:        hi.URL,
+		Kind:       alert.KindBroken,
+		Message:    fmt.Sprintf("destination failed %d checks in a row: %s", hi.Failures, reason),
+		Time:       now,
+	}
+	if err := m.notifier.Notify(ctx, a); err != nil {
+		m.log.Printf("%s : %s : ERROR : notifying broken shorturl %d : %v", traceID, "health.notify", hi.ShorturlID, err)
+	}
+}
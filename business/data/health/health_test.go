@@ -0,0 +1,99 @@
+package health_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/alert"
+	"github.com/mitrovicsinisaa/shorturl/business/data/health"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+// notifier records the alerts it is asked to deliver.
+type notifier struct {
+	alerts []alert.Alert
+}
+
+func (n *notifier) Notify(ctx context.Context, a alert.Alert) error {
+	n.alerts = append(n.alerts, a)
+	return nil
+}
+
+func TestHealth(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	var n notifier
+	cfg := health.Config{
+		Batch:       10,
+		Concurrency: 2,
+		Threshold:   2,
+	}
+	m := health.New(log, db, srv.Client(), &n, cfg)
+	su := shorturl.New(log, db, nil)
+
+	t.Log("Given the need to monitor the destinations of shorturls.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a destination keeps failing.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 1, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			ok, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: srv.URL + "/ok"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			gone, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: srv.URL + "/gone"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			for i := 0; i < 3; i++ {
+				checked, err := m.Check(ctx, traceID, now.Add(time.Duration(i)*time.Minute))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to check destinations : %s.", tests.Failed, testID, err)
+				}
+				if len(checked) != 2 {
+					t.Fatalf("\t%s\tTest %d:\tShould check every due destination : %+v.", tests.Failed, testID, checked)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to check destinations.", tests.Success, testID)
+
+			info, err := su.QueryInfo(ctx, traceID, gone.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl : %s.", tests.Failed, testID, err)
+			}
+			if !info.Broken || info.HealthStatus != http.StatusNotFound || info.HealthFailures != 3 || info.HealthChecked == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould store the health of the destination : %+v.", tests.Failed, testID, info)
+			}
+			t.Logf("\t%s\tTest %d:\tShould store the health of the destination.", tests.Success, testID)
+
+			if len(n.alerts) != 1 || n.alerts[0].ShorturlID != gone.ID || n.alerts[0].Kind != alert.KindBroken {
+				t.Fatalf("\t%s\tTest %d:\tShould notify once when the destination breaks : %+v.", tests.Failed, testID, n.alerts)
+			}
+			t.Logf("\t%s\tTest %d:\tShould notify once when the destination breaks.", tests.Success, testID)
+
+			broken, err := su.Query(ctx, traceID, shorturl.QueryFilter{Broken: true}, 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to list broken shorturls : %s.", tests.Failed, testID, err)
+			}
+			if len(broken) != 1 || broken[0].ID != gone.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould only list broken shorturls : %+v, healthy %d.", tests.Failed, testID, broken, ok.ID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only list broken shorturls.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package health
+
+import "time"
+
+// Config holds the parameters of the health checks.
+//
+// Every check probes up to Batch destinations not checked within Recheck,
+// Concurrency at a time and at most one per HostInterval to the same host. A
+// shorturl is broken once its destination failed Threshold checks in a row.
+type Config struct {
+	Batch        int
+	Recheck      time.Duration
+	Concurrency  int
+	HostInterval time.Duration
+	Threshold    int
+}
+
+// Info is the outcome of checking the destination of a shorturl.
+type Info struct {
+	ShorturlID int    `json:"id"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	LatencyMS  int    `json:"latency_ms"`
+	Healthy    bool   `json:"healthy"`
+	Error      string `json:"error,omitempty"`
+	Failures   int    `json:"failures"`
+	Broken     bool   `json:"broken"`
+	Skipped    bool   `json:"-"`
+}
@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// userAgent identifies health checks to the destinations.
+const userAgent = "shorturl-health/1.0"
+
+// maxBodyLength bounds how much of a GET response is read.
+const maxBodyLength = 64 << 10
+
+// Result is the outcome of probing a destination. StatusCode is zero when no
+// response was received.
+type Result struct {
+	StatusCode int
+	Latency    time.Duration
+	Err        error
+}
+
+// Healthy reports whether the destination responded without an error status.
+func (r Result) Healthy() bool {
+	return r.Err == nil && r.StatusCode < http.StatusBadRequest
+}
+
+// Probe requests the destination with HEAD and falls back to GET when HEAD
+// fails, since some servers do not implement it or answer it differently.
+// Redirects are followed as the client is configured to.
+func Probe(ctx context.Context, client *http.Client, destination string) Result {
+	res := request(ctx, client, http.MethodHead, destination)
+	if res.Healthy() {
+		return res
+	}
+	return request(ctx, client, http.MethodGet, destination)
+}
+
+// request sends a single request and measures the time to its response.
+func request(ctx context.Context, client *http.Client, method string, destination string) Result {
+	req, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return Result{Err: errors.Wrap(err, "creating request")}
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Latency: time.Since(start), Err: err}
+	}
+	defer resp.Body.Close()
+
+	res := Result{
+		StatusCode: resp.StatusCode,
+		Latency:    time.Since(start),
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxBodyLength))
+
+	if !res.Healthy() {
+		res.Err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return res
+}
+
+// limiter spaces out requests to the same host.
+type limiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     map[string]time.Time
+}
+
+// newLimiter constructs a limiter allowing a request per host per interval.
+func newLimiter(interval time.Duration) *limiter {
+	return &limiter{
+		interval: interval,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// wait blocks until a request to the host of the destination is allowed.
+func (l *limiter) wait(ctx context.Context, destination string) error {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return errors.Wrap(err, "parsing destination")
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	at := l.next[u.Host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[u.Host] = at.Add(l.interval)
+
+	// Hosts whose slot passed are dropped so the map only holds the hosts
+	// being checked.
+	for host, next := range l.next {
+		if next.Before(now) {
+			delete(l.next, host)
+		}
+	}
+	l.mu.Unlock()
+
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
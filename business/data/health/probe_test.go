@@ -0,0 +1,83 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/data/health"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+func TestProbe(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.URL.Path {
+		case "/ok":
+		case "/nohead":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	tt := []struct {
+		name    string
+		url     string
+		healthy bool
+		status  int
+		methods int
+	}{
+		{"ok", srv.URL + "/ok", true, http.StatusOK, 1},
+		{"head not allowed", srv.URL + "/nohead", true, http.StatusOK, 2},
+		{"failing", srv.URL + "/fail", false, http.StatusInternalServerError, 2},
+		{"unreachable", "http://127.0.0.1:1/", false, 0, 0},
+	}
+
+	t.Log("Given the need to probe destinations.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen probing a destination that is %s.", testID, tst.name)
+			{
+				methods = nil
+				res := health.Probe(context.Background(), srv.Client(), tst.url)
+				if res.Healthy() != tst.healthy || res.StatusCode != tst.status {
+					t.Fatalf("\t%s\tTest %d:\tShould report healthy %v with status %d : %+v.", tests.Failed, testID, tst.healthy, tst.status, res)
+				}
+				if len(methods) != tst.methods {
+					t.Fatalf("\t%s\tTest %d:\tShould send %d requests : %v.", tests.Failed, testID, tst.methods, methods)
+				}
+				t.Logf("\t%s\tTest %d:\tShould report healthy %v with status %d.", tests.Success, testID, tst.healthy, tst.status)
+			}
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	t.Log("Given the need to keep health checks off the internal network.")
+	{
+		for testID, url := range []string{srv.URL, "http://localhost:1/", "http://169.254.169.254/latest/meta-data/", "http://10.0.0.1/", "http://[::1]:1/", "http://0.0.0.0:1/"} {
+			t.Logf("\tTest %d:\tWhen probing %s.", testID, url)
+			{
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				res := health.Probe(ctx, health.NewClient(time.Second), url)
+				cancel()
+
+				if res.StatusCode != 0 || !errors.Is(res.Err, health.ErrForbiddenAddress) {
+					t.Fatalf("\t%s\tTest %d:\tShould refuse to connect : %+v.", tests.Failed, testID, res)
+				}
+				t.Logf("\t%s\tTest %d:\tShould refuse to connect.", tests.Success, testID)
+			}
+		}
+	}
+}
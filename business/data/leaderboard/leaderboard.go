@@ -0,0 +1,219 @@
+// Package leaderboard ranks shorturls by their recent visits. Rankings are
+// computed periodically from hourly click rollups so requests only read the
+// stored snapshot.
+package leaderboard
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownPeriod is used when a leaderboard is requested for a period that
+// is not computed.
+var ErrUnknownPeriod = errors.New("unknown period")
+
+// Trending is the period under which the trending ranking is stored.
+const Trending = "trending"
+
+// Periods maps the periods of the top ranking to their window.
+var Periods = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// Set of parameters of the rankings.
+const (
+
+	// size is the number of shorturls kept per ranking.
+	size = 100
+
+	// trendingWindow is the recent window compared against the baseline.
+	trendingWindow = 3 * time.Hour
+
+	// baselineWindow is the window preceding the recent window that the
+	// baseline rate is computed from.
+	baselineWindow = 7 * 24 * time.Hour
+
+	// trendingMinVisits keeps shorturls with a handful of visits from
+	// dominating the trending ranking.
+	trendingMinVisits = 10
+
+	// lateClicks is how far back before the latest rolled up hour clicks
+	// are rolled up again, so clicks committed after their hour was rolled
+	// up are still counted.
+	lateClicks = 2 * time.Hour
+)
+
+// Leaderboard manages the set of API's for leaderboard access.
+type Leaderboard struct {
+	log *log.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a leaderboard for api access.
+func New(log *log.Logger, db *sqlx.DB) Leaderboard {
+	return Leaderboard{
+		log: log,
+		db:  db,
+	}
+}
+
+// Refresh rolls up the recent human clicks per shorturl and hour and then
+// recomputes every ranking from the rollups.
+func (lb Leaderboard) Refresh(ctx context.Context, traceID string, now time.Time) error {
+	now = now.UTC()
+
+	tx, err := lb.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// The latest rolled up hour may have been incomplete and the hours
+	// before it may have received late clicks, so rolling up restarts
+	// lateClicks before it. The first rollup covers the longest window.
+	const qr = `
+	INSERT INTO click_rollups
+		(shorturl_id, hour, visits)
+	SELECT
+		shorturl_id, date_trunc('hour', date_created), COUNT(*)
+	FROM
+		clicks
+	WHERE
+		NOT bot AND
+		date_created >= COALESCE((SELECT MAX(hour) FROM click_rollups) - make_interval(secs => $2), $1)
+	GROUP BY
+		1, 2
+	ON CONFLICT (shorturl_id, hour) DO UPDATE SET
+		visits = EXCLUDED.visits`
+
+	since := now.Add(-baselineWindow - trendingWindow)
+
+	lb.log.Printf("%s : %s : query : %s", traceID, "leaderboard.Refresh",
+		database.Log(qr, since, lateClicks.Seconds()))
+
+	if _, err := tx.ExecContext(ctx, qr, since, lateClicks.Seconds()); err != nil {
+		return errors.Wrap(err, "rolling up clicks")
+	}
+
+	const qd = `
+	DELETE FROM
+		leaderboard`
+
+	lb.log.Printf("%s : %s : query : %s", traceID, "leaderboard.Refresh",
+		database.Log(qd))
+
+	if _, err := tx.ExecContext(ctx, qd); err != nil {
+		return errors.Wrap(err, "clearing leaderboard")
+	}
+
+	const qt = `
+	INSERT INTO leaderboard
+		(period, rank, shorturl_id, visits, score, date_computed)
+	SELECT
+		$1, ROW_NUMBER() OVER (ORDER BY SUM(visits) DESC, shorturl_id), shorturl_id, SUM(visits), SUM(visits), $3
+	FROM
+		click_rollups
+	WHERE
+		hour >= $2
+	GROUP BY
+		shorturl_id
+	ORDER BY
+		SUM(visits) DESC, shorturl_id
+	LIMIT $4`
+
+	for period, window := range Periods {
+		from := now.Add(-window)
+
+		lb.log.Printf("%s : %s : query : %s", traceID, "leaderboard.Refresh",
+			database.Log(qt, period, from, now, size))
+
+		if _, err := tx.ExecContext(ctx, qt, period, from, now, size); err != nil {
+			return errors.Wrapf(err, "ranking %s", period)
+		}
+	}
+
+	// Trending compares the hourly rate of the recent window with the hourly
+	// rate of the baseline window. Adding one to the baseline keeps new
+	// shorturls from getting an infinite score.
+	const qg = `
+	INSERT INTO leaderboard
+		(period, rank, shorturl_id, visits, score, date_computed)
+	SELECT
+		$1, ROW_NUMBER() OVER (ORDER BY score DESC, shorturl_id), shorturl_id, recent, score, $4
+	FROM (
+		SELECT
+			shorturl_id,
+			SUM(visits) FILTER (WHERE hour >= $3) AS recent,
+			(SUM(visits) FILTER (WHERE hour >= $3) / $5::DOUBLE PRECISION) /
+			(COALESCE(SUM(visits) FILTER (WHERE hour < $3), 0) / $6::DOUBLE PRECISION + 1) AS score
+		FROM
+			click_rollups
+		WHERE
+			hour >= $2
+		GROUP BY
+			shorturl_id
+	) AS rates
+	WHERE
+		recent >= $7
+	ORDER BY
+		score DESC, shorturl_id
+	LIMIT $8`
+
+	recentFrom := now.Add(-trendingWindow)
+	baselineFrom := recentFrom.Add(-baselineWindow)
+	args := []interface{}{
+		Trending, baselineFrom, recentFrom, now,
+		trendingWindow.Hours(), baselineWindow.Hours(), trendingMinVisits, size,
+	}
+
+	lb.log.Printf("%s : %s : query : %s", traceID, "leaderboard.Refresh",
+		database.Log(qg, args...))
+
+	if _, err := tx.ExecContext(ctx, qg, args...); err != nil {
+		return errors.Wrap(err, "ranking trending")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing leaderboard")
+	}
+
+	return nil
+}
+
+// Query retrieves the top ranked shorturls of the specified period from the
+// last computed snapshot.
+func (lb Leaderboard) Query(ctx context.Context, traceID string, period string, limit int) ([]Entry, error) {
+	if _, ok := Periods[period]; !ok && period != Trending {
+		return nil, ErrUnknownPeriod
+	}
+
+	const q = `
+	SELECT
+		l.rank, l.shorturl_id, s.url, l.visits, l.score, l.date_computed
+	FROM
+		leaderboard AS l
+	JOIN
+		shorturls AS s ON s.shorturl_id = l.shorturl_id
+	WHERE
+		l.period = $1
+	ORDER BY
+		l.rank
+	LIMIT $2`
+
+	lb.log.Printf("%s : %s : query : %s", traceID, "leaderboard.Query",
+		database.Log(q, period, limit))
+
+	entries := []Entry{}
+	if err := lb.db.SelectContext(ctx, &entries, q, period, limit); err != nil {
+		return nil, errors.Wrapf(err, "selecting leaderboard %s", period)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,98 @@
+package leaderboard_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/data/leaderboard"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+	"github.com/pkg/errors"
+)
+
+func TestLeaderboard(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	su := shorturl.New(log, db, nil)
+	lb := leaderboard.New(log, db)
+
+	t.Log("Given the need to rank shorturls by their recent visits.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen shorturls are visited at different rates.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.June, 10, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			steady, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://example.com/steady"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			hot, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://example.com/hot"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			// The steady shorturl gets two visits a day for a week, the hot one
+			// gets all of its visits within the last hour.
+			for d := 1; d <= 7; d++ {
+				for i := 0; i < 2; i++ {
+					if _, err := su.QueryByID(ctx, traceID, steady.ID, shorturl.NewVisit{}, now.Add(-time.Duration(d)*24*time.Hour)); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould be able to visit shorturl : %s.", tests.Failed, testID, err)
+					}
+				}
+			}
+			for i := 0; i < 12; i++ {
+				if _, err := su.QueryByID(ctx, traceID, hot.ID, shorturl.NewVisit{}, now.Add(-30*time.Minute)); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to visit shorturl : %s.", tests.Failed, testID, err)
+				}
+			}
+
+			if err := lb.Refresh(ctx, traceID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to refresh the leaderboard : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to refresh the leaderboard.", tests.Success, testID)
+
+			week, err := lb.Query(ctx, traceID, "week", 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the leaderboard : %s.", tests.Failed, testID, err)
+			}
+			if len(week) != 2 || week[0].ShorturlID != steady.ID || week[0].Visits != 14 {
+				t.Fatalf("\t%s\tTest %d:\tShould rank the steady shorturl first for the week : %+v.", tests.Failed, testID, week)
+			}
+			t.Logf("\t%s\tTest %d:\tShould rank the steady shorturl first for the week.", tests.Success, testID)
+
+			trending, err := lb.Query(ctx, traceID, leaderboard.Trending, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the trending list : %s.", tests.Failed, testID, err)
+			}
+			if len(trending) != 1 || trending[0].ShorturlID != hot.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould list only the hot shorturl as trending : %+v.", tests.Failed, testID, trending)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list only the hot shorturl as trending.", tests.Success, testID)
+
+			// A click recorded after its hour was rolled up.
+			if _, err := su.QueryByID(ctx, traceID, hot.ID, shorturl.NewVisit{}, now.Add(-90*time.Minute)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to visit shorturl : %s.", tests.Failed, testID, err)
+			}
+			if err := lb.Refresh(ctx, traceID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to refresh the leaderboard : %s.", tests.Failed, testID, err)
+			}
+			day, err := lb.Query(ctx, traceID, "day", 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the leaderboard : %s.", tests.Failed, testID, err)
+			}
+			if len(day) == 0 || day[0].ShorturlID != hot.ID || day[0].Visits != 13 {
+				t.Fatalf("\t%s\tTest %d:\tShould count clicks recorded late : %+v.", tests.Failed, testID, day)
+			}
+			t.Logf("\t%s\tTest %d:\tShould count clicks recorded late.", tests.Success, testID)
+
+			if _, err := lb.Query(ctx, traceID, "year", 10); errors.Cause(err) != leaderboard.ErrUnknownPeriod {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to query an unknown period : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to query an unknown period.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package leaderboard
+
+import (
+	"time"
+)
+
+// Entry represents a ranked shorturl of a leaderboard.
+type Entry struct {
+	Rank         int       `db:"rank" json:"rank"`
+	ShorturlID   int       `db:"shorturl_id" json:"id"`
+	URL          string    `db:"url" json:"url"`
+	Visits       int       `db:"visits" json:"visits"`
+	Score        float64   `db:"score" json:"score"`
+	DateComputed time.Time `db:"date_computed" json:"date_computed"`
+}
@@ -55,4 +55,400 @@ ALTER TABLE shorturls
 	ALTER COLUMN visits SET DEFAULT 0
 ;`,
 	},
+	{
+		Version:     1.4,
+		Description: "Create table shorturl_variants",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN sticky BOOLEAN NOT NULL DEFAULT false
+;
+
+CREATE TABLE shorturl_variants (
+	variant_id		SERIAL,
+	shorturl_id		INT REFERENCES shorturls (shorturl_id) ON DELETE CASCADE,
+	url 			TEXT,
+	weight 			INT,
+	visits 			INT DEFAULT 0,
+
+	PRIMARY KEY (variant_id)
+);`,
+	},
+	{
+		Version:     1.5,
+		Description: "Add redirect code to shorturls",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN redirect_code INT NOT NULL DEFAULT 0
+;`,
+	},
+	{
+		Version:     1.6,
+		Description: "Add passthrough forwarding to shorturls",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN passthrough BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN query_policy TEXT NOT NULL DEFAULT 'link'
+;`,
+	},
+	{
+		Version:     1.7,
+		Description: "Create table golinks",
+		Script: `
+CREATE TABLE golinks (
+	keyword			TEXT,
+	template 		TEXT,
+	description 	TEXT,
+	owner_id 		UUID REFERENCES users (user_id) ON DELETE SET NULL,
+	visits 			INT DEFAULT 0,
+	date_created 	TIMESTAMP,
+	date_updated	TIMESTAMP,
+
+	PRIMARY KEY (keyword)
+);
+
+CREATE INDEX golinks_keyword_prefix ON golinks (keyword text_pattern_ops);`,
+	},
+	{
+		Version:     1.8,
+		Description: "Add utm campaign fields to shorturls",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN utm_source TEXT NOT NULL DEFAULT '',
+	ADD COLUMN utm_medium TEXT NOT NULL DEFAULT '',
+	ADD COLUMN utm_campaign TEXT NOT NULL DEFAULT '',
+	ADD COLUMN utm_term TEXT NOT NULL DEFAULT '',
+	ADD COLUMN utm_content TEXT NOT NULL DEFAULT ''
+;
+
+CREATE INDEX shorturls_utm_campaign ON shorturls (utm_campaign);`,
+	},
+	{
+		Version:     1.9,
+		Description: "Create table clicks",
+		Script: `
+CREATE TABLE clicks (
+	click_id		BIGSERIAL,
+	shorturl_id		INT REFERENCES shorturls (shorturl_id) ON DELETE CASCADE,
+	variant_id		INT,
+	referrer 		TEXT,
+	browser 		TEXT,
+	os 				TEXT,
+	device 			TEXT,
+	date_created 	TIMESTAMP,
+
+	PRIMARY KEY (click_id)
+);
+
+CREATE INDEX clicks_shorturl_date ON clicks (shorturl_id, date_created);`,
+	},
+	{
+		Version:     2.1,
+		Description: "Add bot visits to shorturls and clicks",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN bot_visits INT NOT NULL DEFAULT 0
+;
+
+ALTER TABLE clicks
+	ADD COLUMN bot BOOLEAN NOT NULL DEFAULT false
+;`,
+	},
+	{
+		Version:     2.2,
+		Description: "Create table shorturl_uniques",
+		Script: `
+CREATE TABLE shorturl_uniques (
+	shorturl_id		INT REFERENCES shorturls (shorturl_id) ON DELETE CASCADE,
+	day 			DATE,
+	sketch 			BYTEA,
+
+	PRIMARY KEY (shorturl_id, day)
+);`,
+	},
+	{
+		Version:     2.3,
+		Description: "Create tables click_rollups and leaderboard",
+		Script: `
+CREATE TABLE click_rollups (
+	shorturl_id		INT REFERENCES shorturls (shorturl_id) ON DELETE CASCADE,
+	hour 			TIMESTAMP,
+	visits 			INT,
+
+	PRIMARY KEY (shorturl_id, hour)
+);
+
+CREATE INDEX click_rollups_hour ON click_rollups (hour);
+
+CREATE TABLE leaderboard (
+	period			TEXT,
+	rank 			INT,
+	shorturl_id		INT REFERENCES shorturls (shorturl_id) ON DELETE CASCADE,
+	visits 			INT,
+	score 			DOUBLE PRECISION,
+	date_computed 	TIMESTAMP,
+
+	PRIMARY KEY (period, rank)
+);`,
+	},
+	{
+		Version:     2.4,
+		Description: "Create tables alert_rules and alerts",
+		Script: `
+CREATE TABLE alert_rules (
+	rule_id 			SERIAL,
+	shorturl_id			INT UNIQUE REFERENCES shorturls (shorturl_id) ON DELETE CASCADE,
+	enabled 			BOOLEAN NOT NULL,
+	spike_factor 		DOUBLE PRECISION NOT NULL,
+	spike_min_visits 	INT NOT NULL,
+	drop_factor 		DOUBLE PRECISION NOT NULL,
+	drop_min_baseline 	DOUBLE PRECISION NOT NULL,
+	quiet_minutes 		INT NOT NULL,
+	date_updated 		TIMESTAMP,
+
+	PRIMARY KEY (rule_id)
+);
+
+INSERT INTO alert_rules
+	(shorturl_id, enabled, spike_factor, spike_min_visits, drop_factor, drop_min_baseline, quiet_minutes, date_updated)
+VALUES
+	(NULL, true, 5, 50, 0.2, 10, 360, NOW());
+
+CREATE TABLE alerts (
+	alert_id 		SERIAL,
+	shorturl_id		INT REFERENCES shorturls (shorturl_id) ON DELETE CASCADE,
+	kind 			TEXT,
+	recent_rate 	DOUBLE PRECISION,
+	baseline_rate 	DOUBLE PRECISION,
+	date_created 	TIMESTAMP,
+
+	PRIMARY KEY (alert_id)
+);
+
+CREATE INDEX alerts_shorturl_date ON alerts (shorturl_id, date_created);`,
+	},
+	{
+		Version:     2.5,
+		Description: "Add conversion tracking to shorturls and clicks",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN conversion_tracking BOOLEAN NOT NULL DEFAULT false
+;
+
+ALTER TABLE clicks
+	ADD COLUMN tracking_id UUID UNIQUE
+;
+
+CREATE TABLE conversions (
+	click_id		BIGINT REFERENCES clicks (click_id) ON DELETE CASCADE,
+	shorturl_id		INT REFERENCES shorturls (shorturl_id) ON DELETE CASCADE,
+	variant_id		INT,
+	value 			NUMERIC(14, 4),
+	currency 		TEXT,
+	date_created 	TIMESTAMP,
+
+	PRIMARY KEY (click_id)
+);
+
+CREATE INDEX conversions_shorturl ON conversions (shorturl_id);`,
+	},
+	{
+		Version:     2.6,
+		Description: "Add public stats to shorturls",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN public_stats BOOLEAN NOT NULL DEFAULT false
+;`,
+	},
+	{
+		Version:     2.7,
+		Description: "Create webhook tables",
+		Script: `
+CREATE TABLE webhook_subscriptions (
+	subscription_id 	SERIAL,
+	url 				TEXT,
+	secret 				TEXT,
+	events 				TEXT[],
+	milestone_every 	INT NOT NULL DEFAULT 0,
+	date_created 		TIMESTAMP,
+
+	PRIMARY KEY (subscription_id)
+);
+
+CREATE TABLE webhook_deliveries (
+	delivery_id 		BIGSERIAL,
+	subscription_id 	INT REFERENCES webhook_subscriptions (subscription_id) ON DELETE CASCADE,
+	event 				TEXT,
+	payload 			JSONB,
+	status 				TEXT,
+	attempts 			INT NOT NULL DEFAULT 0,
+	next_attempt 		TIMESTAMP,
+	response_code 		INT NOT NULL DEFAULT 0,
+	error 				TEXT NOT NULL DEFAULT '',
+	date_created 		TIMESTAMP,
+	date_delivered 		TIMESTAMP,
+
+	PRIMARY KEY (delivery_id)
+);
+
+CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt);
+CREATE INDEX webhook_deliveries_subscription ON webhook_deliveries (subscription_id, delivery_id);
+
+CREATE TABLE webhook_milestones (
+	subscription_id 	INT REFERENCES webhook_subscriptions (subscription_id) ON DELETE CASCADE,
+	shorturl_id			INT REFERENCES shorturls (shorturl_id) ON DELETE CASCADE,
+	reached 			INT,
+
+	PRIMARY KEY (subscription_id, shorturl_id)
+);`,
+	},
+	{
+		Version:     2.8,
+		Description: "Create jobs table",
+		Script: `
+CREATE TABLE jobs (
+	job_id 			BIGSERIAL,
+	kind 			TEXT,
+	payload 		JSONB,
+	status 			TEXT,
+	attempts 		INT NOT NULL DEFAULT 0,
+	max_attempts 	INT,
+	run_at 			TIMESTAMP,
+	last_error 		TEXT NOT NULL DEFAULT '',
+	date_created 	TIMESTAMP,
+	date_updated 	TIMESTAMP,
+
+	PRIMARY KEY (job_id)
+);
+
+CREATE INDEX jobs_due ON jobs (run_at) WHERE status = 'pending';
+
+INSERT INTO jobs
+	(kind, payload, status, attempts, max_attempts, run_at, last_error, date_created, date_updated)
+SELECT
+	'webhook.deliver', json_build_object('delivery_id', delivery_id), 'pending', attempts, 8, next_attempt, error, date_created, date_created
+FROM
+	webhook_deliveries
+WHERE
+	status = 'pending';`,
+	},
+	{
+		Version:     2.9,
+		Description: "Create scheduled tasks table",
+		Script: `
+CREATE TABLE scheduled_tasks (
+	name 				TEXT,
+	schedule 			TEXT,
+	next_run 			TIMESTAMP,
+	last_run 			TIMESTAMP,
+	last_duration_ms 	BIGINT NOT NULL DEFAULT 0,
+	last_status 		TEXT NOT NULL DEFAULT '',
+	last_error 			TEXT NOT NULL DEFAULT '',
+	last_host 			TEXT NOT NULL DEFAULT '',
+
+	PRIMARY KEY (name)
+);`,
+	},
+	{
+		Version:     3.0,
+		Description: "Add destination health to shorturls",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN health_status INT NOT NULL DEFAULT 0,
+	ADD COLUMN health_latency_ms INT NOT NULL DEFAULT 0,
+	ADD COLUMN health_error TEXT NOT NULL DEFAULT '',
+	ADD COLUMN health_failures INT NOT NULL DEFAULT 0,
+	ADD COLUMN health_checked TIMESTAMP,
+	ADD COLUMN broken BOOLEAN NOT NULL DEFAULT false
+;
+
+CREATE INDEX shorturls_health_checked ON shorturls (health_checked NULLS FIRST);`,
+	},
+	{
+		Version:     3.1,
+		Description: "Add fallback destination to shorturls",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN fallback_url TEXT NOT NULL DEFAULT ''
+;
+
+ALTER TABLE clicks
+	ADD COLUMN fallback BOOLEAN NOT NULL DEFAULT false
+;`,
+	},
+	{
+		Version:     3.2,
+		Description: "Add activation window to shorturls",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN active_from TIMESTAMP,
+	ADD COLUMN active_until TIMESTAMP,
+	ADD COLUMN prelaunch_url TEXT NOT NULL DEFAULT '',
+	ADD COLUMN postcampaign_url TEXT NOT NULL DEFAULT '',
+	ADD COLUMN expired_notified BOOLEAN NOT NULL DEFAULT false
+;`,
+	},
+	{
+		Version:     3.3,
+		Description: "Add burn after reading to shorturls",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN burn_after_reading BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN burn_confirm BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN burned_at TIMESTAMP
+;`,
+	},
+	{
+		Version:     3.4,
+		Description: "Add signed only shorturls",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN signed_only BOOLEAN NOT NULL DEFAULT false
+;`,
+	},
+	{
+		Version:     3.5,
+		Description: "Create abuse report and moderation tables",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN disabled_reason TEXT NOT NULL DEFAULT '',
+	ADD COLUMN allowlisted BOOLEAN NOT NULL DEFAULT false
+;
+
+CREATE TABLE abuse_reports (
+	report_id 			SERIAL,
+	shorturl_id			INT REFERENCES shorturls (shorturl_id) ON DELETE CASCADE,
+	reason 				TEXT,
+	details 			TEXT NOT NULL DEFAULT '',
+	challenge 			TEXT UNIQUE,
+	status 				TEXT,
+	date_created 		TIMESTAMP,
+
+	PRIMARY KEY (report_id)
+);
+
+CREATE INDEX abuse_reports_open ON abuse_reports (shorturl_id) WHERE status = 'open';
+
+CREATE TABLE moderation_actions (
+	action_id 			SERIAL,
+	shorturl_id			INT,
+	url 				TEXT,
+	action 				TEXT,
+	reason 				TEXT,
+	moderator 			TEXT,
+	date_created 		TIMESTAMP,
+
+	PRIMARY KEY (action_id)
+);`,
+	},
+	{
+		Version:     3.6,
+		Description: "Add reputation screening to shorturls",
+		Script: `
+ALTER TABLE shorturls
+	ADD COLUMN screened_at TIMESTAMP
+;
+
+CREATE INDEX shorturls_screened_at ON shorturls (screened_at NULLS FIRST);`,
+	},
 }
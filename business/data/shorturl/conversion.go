@@ -0,0 +1,175 @@
+package shorturl
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// ClickIDParam is the query parameter that carries the click ID to the
+// destination of shorturls with conversion tracking.
+const ClickIDParam = "sclid"
+
+// AppendClickID adds the click ID to the query of the destination, replacing
+// any click ID already present.
+func AppendClickID(destination string, clickID string) (string, error) {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing destination")
+	}
+
+	query := u.Query()
+	query.Set(ClickIDParam, clickID)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// CreateConversion attributes a conversion to the click it was posted back
+// for. A click converts at most once.
+func (su Shorturl) CreateConversion(ctx context.Context, traceID string, nc NewConversion, now time.Time) (Conversion, error) {
+	tx, err := su.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Conversion{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const qs = `
+	SELECT
+		click_id, shorturl_id, COALESCE(variant_id, 0) AS variant_id
+	FROM
+		clicks
+	WHERE
+		tracking_id = $1`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.CreateConversion",
+		database.Log(qs, nc.ClickID))
+
+	var click struct {
+		ID         int64 `db:"click_id"`
+		ShorturlID int   `db:"shorturl_id"`
+		VariantID  int   `db:"variant_id"`
+	}
+	if err := tx.GetContext(ctx, &click, qs, nc.ClickID); err != nil {
+		if err == sql.ErrNoRows {
+			return Conversion{}, ErrNotFound
+		}
+		return Conversion{}, errors.Wrapf(err, "selecting click %s", nc.ClickID)
+	}
+
+	c := Conversion{
+		ClickID:     nc.ClickID,
+		ShorturlID:  click.ShorturlID,
+		VariantID:   click.VariantID,
+		Value:       nc.Value,
+		Currency:    strings.ToUpper(nc.Currency),
+		DateCreated: now.UTC(),
+	}
+
+	const qi = `
+	INSERT INTO conversions
+		(click_id, shorturl_id, variant_id, value, currency, date_created)
+	VALUES
+		($1, $2, NULLIF($3, 0), $4, $5, $6)
+	ON CONFLICT (click_id) DO NOTHING`
+
+	args := []interface{}{click.ID, c.ShorturlID, c.VariantID, c.Value, c.Currency, c.DateCreated}
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.CreateConversion",
+		database.Log(qi, args...))
+
+	res, err := tx.ExecContext(ctx, qi, args...)
+	if err != nil {
+		return Conversion{}, errors.Wrapf(err, "inserting conversion for click %s", nc.ClickID)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return Conversion{}, errors.Wrapf(err, "inserting conversion for click %s", nc.ClickID)
+	}
+	if n == 0 {
+		return Conversion{}, ErrConversionExists
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Conversion{}, errors.Wrap(err, "committing conversion")
+	}
+
+	return c, nil
+}
+
+// queryConversions adds the conversions, conversion rates and revenue of the
+// shorturl and of each of its variants to the visits. Rates are always taken
+// over human visits, whatever the bots filter of the visits.
+func (su Shorturl) queryConversions(ctx context.Context, traceID string, shorturl_id int, visits *ShorturlVisits) error {
+
+	const q = `
+	SELECT
+		COALESCE(variant_id, 0) AS variant_id, currency, COUNT(*) AS conversions, SUM(value)::DOUBLE PRECISION AS value
+	FROM
+		conversions
+	WHERE
+		shorturl_id = $1
+	GROUP BY
+		1, 2
+	ORDER BY
+		1, 2`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.queryConversions",
+		database.Log(q, shorturl_id))
+
+	var rows []struct {
+		VariantID int `db:"variant_id"`
+		Revenue
+	}
+	if err := su.db.SelectContext(ctx, &rows, q, shorturl_id); err != nil {
+		return errors.Wrapf(err, "selecting conversions %d", shorturl_id)
+	}
+
+	variants := make(map[int]*Variant, len(visits.Variants))
+	for i := range visits.Variants {
+		variants[visits.Variants[i].ID] = &visits.Variants[i]
+	}
+
+	visits.Revenue = []Revenue{}
+	for _, row := range rows {
+		visits.Conversions += row.Conversions
+		visits.Revenue = addRevenue(visits.Revenue, row.Revenue)
+
+		if v, ok := variants[row.VariantID]; ok {
+			v.Conversions += row.Conversions
+			v.Revenue = addRevenue(v.Revenue, row.Revenue)
+		}
+	}
+
+	visits.ConversionRate = conversionRate(visits.Conversions, visits.HumanVisits)
+	for _, v := range variants {
+		v.ConversionRate = conversionRate(v.Conversions, v.Visits)
+	}
+
+	return nil
+}
+
+// addRevenue merges the revenue into the list by currency.
+func addRevenue(list []Revenue, r Revenue) []Revenue {
+	for i := range list {
+		if list[i].Currency == r.Currency {
+			list[i].Conversions += r.Conversions
+			list[i].Value += r.Value
+			return list
+		}
+	}
+	return append(list, r)
+}
+
+// conversionRate returns the share of visits that converted.
+func conversionRate(conversions int, visits int) float64 {
+	if visits == 0 {
+		return 0
+	}
+	return float64(conversions) / float64(visits)
+}
@@ -0,0 +1,116 @@
+package shorturl
+
+import (
+	"context"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// ExportClicks streams the recorded clicks matching the filter to fn, oldest
+// first. Rows are read one at a time so exports of any size stay in constant
+// memory. An error from fn stops the export and is returned.
+func (su Shorturl) ExportClicks(ctx context.Context, traceID string, filter ExportFilter, fn func(Click) error) error {
+
+	const q = `
+	SELECT
+		c.click_id, c.shorturl_id, COALESCE(c.variant_id, 0) AS variant_id,
+		c.referrer, c.browser, c.os, c.device, c.bot, c.date_created
+	FROM
+		clicks AS c
+	JOIN
+		shorturls AS s ON s.shorturl_id = c.shorturl_id
+	WHERE
+		($1 = 0 OR c.shorturl_id = $1) AND
+		($2 = '' OR s.utm_campaign = $2) AND
+		c.date_created >= $3 AND c.date_created < $4
+	ORDER BY
+		c.click_id`
+
+	from, to := exportRange(filter)
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.ExportClicks",
+		database.Log(q, filter.ShorturlID, filter.Campaign, from, to))
+
+	rows, err := su.db.QueryxContext(ctx, q, filter.ShorturlID, filter.Campaign, from, to)
+	if err != nil {
+		return errors.Wrap(err, "selecting clicks")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Click
+		if err := rows.StructScan(&c); err != nil {
+			return errors.Wrap(err, "scanning click")
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterating clicks")
+	}
+
+	return nil
+}
+
+// ExportDaily streams the daily visits of every shorturl matching the filter
+// to fn, ordered by day and shorturl. An error from fn stops the export and
+// is returned.
+func (su Shorturl) ExportDaily(ctx context.Context, traceID string, filter ExportFilter, fn func(DailyVisits) error) error {
+
+	const q = `
+	SELECT
+		date_trunc('day', c.date_created) AS day, c.shorturl_id,
+		COUNT(*) FILTER (WHERE NOT c.bot) AS visits,
+		COUNT(*) FILTER (WHERE c.bot) AS bot_visits
+	FROM
+		clicks AS c
+	JOIN
+		shorturls AS s ON s.shorturl_id = c.shorturl_id
+	WHERE
+		($1 = 0 OR c.shorturl_id = $1) AND
+		($2 = '' OR s.utm_campaign = $2) AND
+		c.date_created >= $3 AND c.date_created < $4
+	GROUP BY
+		1, 2
+	ORDER BY
+		1, 2`
+
+	from, to := exportRange(filter)
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.ExportDaily",
+		database.Log(q, filter.ShorturlID, filter.Campaign, from, to))
+
+	rows, err := su.db.QueryxContext(ctx, q, filter.ShorturlID, filter.Campaign, from, to)
+	if err != nil {
+		return errors.Wrap(err, "selecting daily visits")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d DailyVisits
+		if err := rows.StructScan(&d); err != nil {
+			return errors.Wrap(err, "scanning daily visits")
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterating daily visits")
+	}
+
+	return nil
+}
+
+// exportRange returns the half-open range of timestamps covering the days of
+// the filter.
+func exportRange(filter ExportFilter) (time.Time, time.Time) {
+	from := filter.From.UTC().Truncate(24 * time.Hour)
+	to := filter.To.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	return from, to
+}
@@ -0,0 +1,65 @@
+package shorturl
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Set of policies that decide which value is kept when the destination and
+// the visitor's request carry the same query key.
+const (
+	QueryPolicyLink    = "link"
+	QueryPolicyRequest = "request"
+	QueryPolicyAppend  = "append"
+)
+
+// Forward appends the extra path and query of a visitor's request to the
+// destination of a passthrough shorturl. Conflicting query keys are resolved
+// according to the policy.
+func Forward(destination string, path string, query url.Values, policy string) (string, error) {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing destination %q", destination)
+	}
+
+	if path = strings.TrimPrefix(path, "/"); path != "" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + path
+		u.RawPath = ""
+	}
+
+	if len(query) == 0 {
+		return u.String(), nil
+	}
+
+	link := u.Query()
+	merged := url.Values{}
+
+	switch policy {
+	case QueryPolicyRequest:
+		for k, vs := range link {
+			merged[k] = vs
+		}
+		for k, vs := range query {
+			merged[k] = vs
+		}
+	case QueryPolicyAppend:
+		for k, vs := range link {
+			merged[k] = append(merged[k], vs...)
+		}
+		for k, vs := range query {
+			merged[k] = append(merged[k], vs...)
+		}
+	default:
+		for k, vs := range query {
+			merged[k] = vs
+		}
+		for k, vs := range link {
+			merged[k] = vs
+		}
+	}
+
+	u.RawQuery = merged.Encode()
+	return u.String(), nil
+}
@@ -1,21 +1,102 @@
 package shorturl
 
 import (
+	"strconv"
 	"time"
 )
 
 // Info represents an individual Shorturl.
 type Info struct {
-	ID          int       `db:"shorturl_id" json:"id"`
-	URL         string    `db:"url" json:"url"`
-	Visits      int       `db:"visits" json:"visits"`
-	DateCreated time.Time `db:"date_created" json:"date_created"`
-	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+	ID                 int        `db:"shorturl_id" json:"id"`
+	URL                string     `db:"url" json:"url"`
+	Visits             int        `db:"visits" json:"visits"`
+	DateCreated        time.Time  `db:"date_created" json:"date_created"`
+	DateUpdated        time.Time  `db:"date_updated" json:"date_updated"`
+	Sticky             bool       `db:"sticky" json:"sticky"`
+	RedirectCode       int        `db:"redirect_code" json:"redirect_code"`
+	Passthrough        bool       `db:"passthrough" json:"passthrough"`
+	QueryPolicy        string     `db:"query_policy" json:"query_policy"`
+	UTMSource          string     `db:"utm_source" json:"utm_source"`
+	UTMMedium          string     `db:"utm_medium" json:"utm_medium"`
+	UTMCampaign        string     `db:"utm_campaign" json:"utm_campaign"`
+	UTMTerm            string     `db:"utm_term" json:"utm_term"`
+	UTMContent         string     `db:"utm_content" json:"utm_content"`
+	BotVisits          int        `db:"bot_visits" json:"bot_visits"`
+	ConversionTracking bool       `db:"conversion_tracking" json:"conversion_tracking"`
+	PublicStats        bool       `db:"public_stats" json:"public_stats"`
+	HealthStatus       int        `db:"health_status" json:"health_status"`
+	HealthLatencyMS    int        `db:"health_latency_ms" json:"health_latency_ms"`
+	HealthError        string     `db:"health_error" json:"health_error,omitempty"`
+	HealthFailures     int        `db:"health_failures" json:"health_failures"`
+	HealthChecked      *time.Time `db:"health_checked" json:"health_checked,omitempty"`
+	Broken             bool       `db:"broken" json:"broken"`
+	FallbackURL        string     `db:"fallback_url" json:"fallback_url,omitempty"`
+	ActiveFrom         *time.Time `db:"active_from" json:"active_from,omitempty"`
+	ActiveUntil        *time.Time `db:"active_until" json:"active_until,omitempty"`
+	PrelaunchURL       string     `db:"prelaunch_url" json:"prelaunch_url,omitempty"`
+	PostcampaignURL    string     `db:"postcampaign_url" json:"postcampaign_url,omitempty"`
+	ExpiredNotified    bool       `db:"expired_notified" json:"-"`
+	BurnAfterReading   bool       `db:"burn_after_reading" json:"burn_after_reading"`
+	BurnConfirm        bool       `db:"burn_confirm" json:"burn_confirm"`
+	BurnedAt           *time.Time `db:"burned_at" json:"burned_at,omitempty"`
+	SignedOnly         bool       `db:"signed_only" json:"signed_only"`
+	Disabled           bool       `db:"disabled" json:"disabled"`
+	DisabledReason     string     `db:"disabled_reason" json:"disabled_reason,omitempty"`
+	Allowlisted        bool       `db:"allowlisted" json:"allowlisted"`
+	ScreenedAt         *time.Time `db:"screened_at" json:"screened_at,omitempty"`
 }
 
-// NewShorturl contains information needed to create a new Shorturl.
+// NewShorturl contains information needed to create a new Shorturl. Visits
+// are sent to FallbackURL while the health checks find the primary
+// destination broken. A Shorturl with an activation window only sends visits
+// to its destination from ActiveFrom until ActiveUntil, before that to
+// PrelaunchURL and after that to PostcampaignURL. A Shorturl that burns after
+// reading redirects a single visit, which must be confirmed on an
+// interstitial page when BurnConfirm is set. A SignedOnly Shorturl can only be
+// visited through signed codes.
 type NewShorturl struct {
-	URL string `json:"url" validate:"required"`
+	URL                string       `json:"url" validate:"required"`
+	Variants           []NewVariant `json:"variants" validate:"dive"`
+	Sticky             bool         `json:"sticky"`
+	RedirectCode       int          `json:"redirect_code" validate:"omitempty,oneof=301 302 307 308"`
+	Passthrough        bool         `json:"passthrough"`
+	QueryPolicy        string       `json:"query_policy" validate:"omitempty,oneof=link request append"`
+	UTM                UTM          `json:"utm"`
+	ConversionTracking bool         `json:"conversion_tracking"`
+	PublicStats        bool         `json:"public_stats"`
+	FallbackURL        string       `json:"fallback_url" validate:"omitempty,url"`
+	ActiveFrom         *time.Time   `json:"active_from"`
+	ActiveUntil        *time.Time   `json:"active_until"`
+	PrelaunchURL       string       `json:"prelaunch_url" validate:"omitempty,url"`
+	PostcampaignURL    string       `json:"postcampaign_url" validate:"omitempty,url"`
+	BurnAfterReading   bool         `json:"burn_after_reading"`
+	BurnConfirm        bool         `json:"burn_confirm"`
+	SignedOnly         bool         `json:"signed_only"`
+}
+
+// UpdateShorturl defines what information may be provided to modify an
+// existing Shorturl. All fields are optional so clients can send just the
+// fields they want changed. ClearWindow removes the activation window before
+// ActiveFrom and ActiveUntil are applied.
+type UpdateShorturl struct {
+	PublicStats     *bool      `json:"public_stats"`
+	FallbackURL     *string    `json:"fallback_url" validate:"omitempty,url"`
+	ClearWindow     bool       `json:"clear_window"`
+	ActiveFrom      *time.Time `json:"active_from"`
+	ActiveUntil     *time.Time `json:"active_until"`
+	PrelaunchURL    *string    `json:"prelaunch_url" validate:"omitempty,url"`
+	PostcampaignURL *string    `json:"postcampaign_url" validate:"omitempty,url"`
+	SignedOnly      *bool      `json:"signed_only"`
+}
+
+// UTM contains the campaign fields that are merged into the destination of a
+// Shorturl as utm_* query parameters.
+type UTM struct {
+	Source   string `json:"source" validate:"required_with=Medium Campaign Term Content,max=100"`
+	Medium   string `json:"medium" validate:"max=100"`
+	Campaign string `json:"campaign" validate:"max=100"`
+	Term     string `json:"term" validate:"max=100"`
+	Content  string `json:"content" validate:"max=100"`
 }
 
 // CreateShorturl contains information needed after create a new Shorturl.
@@ -24,9 +105,235 @@ type CreateShorturl struct {
 	URL         string    `db:"url" json:"url"`
 	DateCreated time.Time `db:"date_created" json:"date_created"`
 	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+	Variants    []Variant `db:"-" json:"variants,omitempty"`
 }
 
 // ShorturlVisits contains information about number of visits for shorturl.
 type ShorturlVisits struct {
-	Visits int `db:"visits" json:"visits"`
+	Visits         int         `db:"visits" json:"visits"`
+	BotVisits      int         `db:"bot_visits" json:"bot_visits"`
+	HumanVisits    int         `db:"human_visits" json:"-"`
+	FallbackVisits int         `db:"fallback_visits" json:"fallback_visits"`
+	Variants       []Variant   `db:"-" json:"variants,omitempty"`
+	Referrers      []Breakdown `db:"-" json:"referrers"`
+	Browsers       []Breakdown `db:"-" json:"browsers"`
+	OSes           []Breakdown `db:"-" json:"oses"`
+	Devices        []Breakdown `db:"-" json:"devices"`
+	Conversions    int         `db:"-" json:"conversions"`
+	ConversionRate float64     `db:"-" json:"conversion_rate"`
+	Revenue        []Revenue   `db:"-" json:"revenue"`
+}
+
+// Set of filters that decide whether stats count human visits, bot visits or
+// both.
+const (
+	BotsExclude = "exclude"
+	BotsInclude = "include"
+	BotsOnly    = "only"
+)
+
+// Breakdown contains the number of visits sharing a single value of a visit
+// attribute such as the referrer domain or the browser family.
+type Breakdown struct {
+	Name   string `db:"name" json:"name"`
+	Visits int    `db:"visits" json:"visits"`
+}
+
+// QueryFilter narrows the list of Shorturls returned by Query. Empty fields
+// do not filter.
+type QueryFilter struct {
+	Campaign string
+	Broken   bool
+}
+
+// Campaign contains the aggregated visits of the Shorturls of a campaign.
+type Campaign struct {
+	Campaign string           `db:"utm_campaign" json:"campaign"`
+	Links    int              `db:"links" json:"links"`
+	Visits   int              `db:"visits" json:"visits"`
+	Sources  []CampaignSource `db:"-" json:"sources,omitempty"`
+}
+
+// CampaignSource contains the aggregated visits of a campaign for a single
+// source and medium.
+type CampaignSource struct {
+	Source string `db:"utm_source" json:"source"`
+	Medium string `db:"utm_medium" json:"medium"`
+	Links  int    `db:"links" json:"links"`
+	Visits int    `db:"visits" json:"visits"`
+}
+
+// Uniques contains the estimated unique visitors over a range of days and for
+// every day of the range.
+type Uniques struct {
+	Uniques uint64         `json:"uniques"`
+	Days    []DailyUniques `json:"days"`
+}
+
+// DailyUniques contains the estimated unique visitors of a single day.
+type DailyUniques struct {
+	Day     time.Time `json:"day"`
+	Uniques uint64    `json:"uniques"`
+}
+
+// Variant represents one weighted destination of a split-tested Shorturl.
+type Variant struct {
+	ID             int       `db:"variant_id" json:"id"`
+	ShorturlID     int       `db:"shorturl_id" json:"shorturl_id"`
+	URL            string    `db:"url" json:"url"`
+	Weight         int       `db:"weight" json:"weight"`
+	Visits         int       `db:"visits" json:"visits"`
+	Conversions    int       `db:"-" json:"conversions"`
+	ConversionRate float64   `db:"-" json:"conversion_rate"`
+	Revenue        []Revenue `db:"-" json:"revenue,omitempty"`
+}
+
+// NewVariant contains information needed to add a destination to a Shorturl.
+type NewVariant struct {
+	URL    string `json:"url" validate:"required"`
+	Weight int    `json:"weight" validate:"required,min=1"`
+}
+
+// NewVisit contains information about the visitor that is needed to resolve
+// the destination of a Shorturl. Confirmed is set when the visitor confirmed
+// the visit on the interstitial page, Preview for requests that must never
+// burn a Shorturl such as HEAD requests. Bot visits never burn a Shorturl
+// either. Signed is set when the visit came through a verified signed code.
+type NewVisit struct {
+	VariantID int
+	Referrer  string
+	UserAgent string
+	Bot       bool
+	Visitor   uint64
+	Confirmed bool
+	Preview   bool
+	Signed    bool
+}
+
+// Destination is the resolved target of a single Shorturl visit. A zero
+// RedirectCode means the deployment default should be used.
+type Destination struct {
+	ShorturlID         int        `db:"shorturl_id"`
+	VariantID          int        `db:"-"`
+	URL                string     `db:"url"`
+	Sticky             bool       `db:"sticky"`
+	RedirectCode       int        `db:"redirect_code"`
+	Passthrough        bool       `db:"passthrough"`
+	QueryPolicy        string     `db:"query_policy"`
+	ConversionTracking bool       `db:"conversion_tracking"`
+	FallbackURL        string     `db:"fallback_url"`
+	Broken             bool       `db:"broken"`
+	ActiveFrom         *time.Time `db:"active_from"`
+	ActiveUntil        *time.Time `db:"active_until"`
+	PrelaunchURL       string     `db:"prelaunch_url"`
+	PostcampaignURL    string     `db:"postcampaign_url"`
+	BurnAfterReading   bool       `db:"burn_after_reading"`
+	BurnConfirm        bool       `db:"burn_confirm"`
+	BurnedAt           *time.Time `db:"burned_at"`
+	SignedOnly         bool       `db:"signed_only"`
+	Disabled           bool       `db:"disabled"`
+
+	// Confirm is set when the shorturl burns after reading and the visit
+	// has to be confirmed first. URL is not set then.
+	Confirm bool `db:"-"`
+
+	// Fallback is set when the visit was sent to the fallback URL instead
+	// of the primary destination.
+	Fallback bool `db:"-"`
+
+	// Window is where the visit fell in the activation window. Outside of
+	// it URL is the pre-launch or post-campaign destination, which may be
+	// empty.
+	Window string `db:"-"`
+
+	// ClickID identifies the click for conversion postbacks. It is only set
+	// for human visits of shorturls with conversion tracking.
+	ClickID string `db:"-"`
+}
+
+// Revenue contains the conversions and their summed value in one currency.
+type Revenue struct {
+	Currency    string  `db:"currency" json:"currency"`
+	Conversions int     `db:"conversions" json:"conversions"`
+	Value       float64 `db:"value" json:"value"`
+}
+
+// NewConversion contains information needed to attribute a conversion to the
+// click it resulted from.
+type NewConversion struct {
+	ClickID  string  `json:"click_id" validate:"required,uuid"`
+	Value    float64 `json:"value" validate:"gte=0"`
+	Currency string  `json:"currency" validate:"required_with=Value,omitempty,len=3,alpha"`
+}
+
+// Conversion represents a conversion attributed to a click.
+type Conversion struct {
+	ClickID     string    `db:"-" json:"click_id"`
+	ShorturlID  int       `db:"shorturl_id" json:"id"`
+	VariantID   int       `db:"variant_id" json:"variant_id,omitempty"`
+	Value       float64   `db:"value" json:"value"`
+	Currency    string    `db:"currency" json:"currency"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// PublicStats contains the stats of a Shorturl that are shown to anyone
+// once its owner made them public.
+type PublicStats struct {
+	Visits    int           `json:"visits"`
+	Days      []DailyVisits `json:"days"`
+	Referrers []Breakdown   `json:"referrers"`
+}
+
+// ExportFilter narrows the clicks that are exported. A zero ShorturlID and an
+// empty Campaign export every shorturl. The range covers the days From to To
+// inclusive.
+type ExportFilter struct {
+	ShorturlID int
+	Campaign   string
+	From       time.Time
+	To         time.Time
+}
+
+// ClickColumns is the CSV header of exported clicks.
+var ClickColumns = []string{"click_id", "id", "variant_id", "referrer", "browser", "os", "device", "bot", "date_created"}
+
+// Click represents a single recorded visit.
+type Click struct {
+	ID          int64     `db:"click_id" json:"click_id"`
+	ShorturlID  int       `db:"shorturl_id" json:"id"`
+	VariantID   int       `db:"variant_id" json:"variant_id"`
+	Referrer    string    `db:"referrer" json:"referrer"`
+	Browser     string    `db:"browser" json:"browser"`
+	OS          string    `db:"os" json:"os"`
+	Device      string    `db:"device" json:"device"`
+	Bot         bool      `db:"bot" json:"bot"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// Fields returns the CSV fields of the click.
+func (c Click) Fields() []string {
+	return []string{
+		strconv.FormatInt(c.ID, 10), strconv.Itoa(c.ShorturlID), strconv.Itoa(c.VariantID),
+		c.Referrer, c.Browser, c.OS, c.Device, strconv.FormatBool(c.Bot),
+		c.DateCreated.Format(time.RFC3339),
+	}
+}
+
+// DailyColumns is the CSV header of exported daily aggregates.
+var DailyColumns = []string{"day", "id", "visits", "bot_visits"}
+
+// DailyVisits contains the visits of a shorturl on a single day.
+type DailyVisits struct {
+	Day        time.Time `db:"day" json:"day"`
+	ShorturlID int       `db:"shorturl_id" json:"id"`
+	Visits     int       `db:"visits" json:"visits"`
+	BotVisits  int       `db:"bot_visits" json:"bot_visits"`
+}
+
+// Fields returns the CSV fields of the daily visits.
+func (d DailyVisits) Fields() []string {
+	return []string{
+		d.Day.Format("2006-01-02"), strconv.Itoa(d.ShorturlID),
+		strconv.Itoa(d.Visits), strconv.Itoa(d.BotVisits),
+	}
 }
@@ -0,0 +1,73 @@
+package shorturl
+
+import (
+	"context"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// QueryPublicStats gets the human visits of the specified shorturl for every
+// day from the day of from to the day of to, together with its top referrer
+// domains. Shorturls whose stats are not public, or that were disabled, are
+// reported as not found so their existence is not revealed.
+func (su Shorturl) QueryPublicStats(ctx context.Context, traceID string, shorturl_id int, from time.Time, to time.Time) (PublicStats, error) {
+	surl, err := su.QueryInfo(ctx, traceID, shorturl_id)
+	if err != nil {
+		return PublicStats{}, err
+	}
+	if !surl.PublicStats || surl.Disabled {
+		return PublicStats{}, ErrNotFound
+	}
+
+	const q = `
+	SELECT
+		date_trunc('day', date_created) AS day, shorturl_id,
+		COUNT(*) AS visits, 0 AS bot_visits
+	FROM
+		clicks
+	WHERE
+		shorturl_id = $1 AND NOT bot AND
+		date_created >= $2 AND date_created < $3
+	GROUP BY
+		1, 2`
+
+	from, to = exportRange(ExportFilter{From: from, To: to})
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.QueryPublicStats",
+		database.Log(q, shorturl_id, from, to))
+
+	var visited []DailyVisits
+	if err := su.db.SelectContext(ctx, &visited, q, shorturl_id, from, to); err != nil {
+		return PublicStats{}, errors.Wrapf(err, "selecting daily visits %d", shorturl_id)
+	}
+
+	// Days without visits have no rows but still belong on the chart.
+	byDay := make(map[time.Time]int, len(visited))
+	for _, d := range visited {
+		byDay[d.Day.UTC()] = d.Visits
+	}
+
+	days := []DailyVisits{}
+	for day := from; day.Before(to); day = day.Add(24 * time.Hour) {
+		days = append(days, DailyVisits{
+			Day:        day,
+			ShorturlID: shorturl_id,
+			Visits:     byDay[day],
+		})
+	}
+
+	referrers, err := su.queryBreakdown(ctx, traceID, "referrer", shorturl_id, BotsExclude)
+	if err != nil {
+		return PublicStats{}, err
+	}
+
+	stats := PublicStats{
+		Visits:    surl.Visits,
+		Days:      days,
+		Referrers: referrers,
+	}
+
+	return stats, nil
+}
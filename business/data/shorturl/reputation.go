@@ -0,0 +1,31 @@
+package shorturl
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// screen checks every destination with the reputation checker. Empty
+// destinations are skipped, as is screening when there is no checker.
+func (su Shorturl) screen(ctx context.Context, urls ...string) error {
+	if su.checker == nil {
+		return nil
+	}
+
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+
+		threat, err := su.checker.Check(ctx, url)
+		if err != nil {
+			return errors.Wrapf(err, "screening %q", url)
+		}
+		if threat != "" {
+			return errors.Wrapf(ErrMalicious, "%s matched %s", url, threat)
+		}
+	}
+
+	return nil
+}
@@ -3,12 +3,21 @@ package shorturl
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"log"
+	"math/big"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/mitrovicsinisaa/shorturl/business/auth"
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/data/webhook"
+	"github.com/mitrovicsinisaa/shorturl/business/reputation"
+	"github.com/mitrovicsinisaa/shorturl/business/useragent"
 	"github.com/mitrovicsinisaa/shorturl/foundation/database"
 	"github.com/pkg/errors"
 )
@@ -23,86 +32,331 @@ var (
 
 	// ErrForbidden occurs when a user tries to do something that is forbiden.
 	ErrForbidden = errors.New("action is not allowed")
+
+	// ErrConversionExists occurs when a conversion is posted back for a click
+	// that already converted.
+	ErrConversionExists = errors.New("conversion already recorded")
+
+	// ErrInvalidWindow occurs when the activation window of a shorturl ends
+	// before it starts.
+	ErrInvalidWindow = errors.New("activation window ends before it starts")
+
+	// ErrBurned occurs when a shorturl that burns after reading was already
+	// visited.
+	ErrBurned = errors.New("shorturl was already visited")
+
+	// ErrMalicious occurs when a destination matches a list of known
+	// malicious URLs.
+	ErrMalicious = errors.New("destination has a bad reputation")
+
+	// ErrInvalidDestination occurs when a destination can't be parsed to
+	// merge the utm fields into it.
+	ErrInvalidDestination = errors.New("destination is not a valid url")
 )
 
+// breakdownLimit is the maximum number of values reported per breakdown.
+const breakdownLimit = 20
+
 // Shorturl manages the set of API's for shorturl access.
 type Shorturl struct {
-	log *log.Logger
-	db  *sqlx.DB
+	log     *log.Logger
+	db      *sqlx.DB
+	webhook webhook.Webhook
+	checker reputation.Checker
 }
 
-// New constructs a shorturl for api access.
-func New(log *log.Logger, db *sqlx.DB) Shorturl {
+// New constructs a shorturl for api access. Destinations are screened with
+// the checker when one is given.
+func New(log *log.Logger, db *sqlx.DB, checker reputation.Checker) Shorturl {
 	return Shorturl{
-		log: log,
-		db:  db,
+		log:     log,
+		db:      db,
+		webhook: webhook.New(log, db),
+		checker: checker,
 	}
 }
 
 // Create inserts a new shorturl into the database.
 func (su Shorturl) Create(ctx context.Context, traceID string, nsu NewShorturl, now time.Time) (CreateShorturl, error) {
+	if !validWindow(nsu.ActiveFrom, nsu.ActiveUntil) {
+		return CreateShorturl{}, ErrInvalidWindow
+	}
+
+	destination, err := nsu.UTM.Apply(nsu.URL)
+	if err != nil {
+		return CreateShorturl{}, errors.Wrap(err, "applying utm fields")
+	}
+
+	urls := []string{destination, nsu.FallbackURL, nsu.PrelaunchURL, nsu.PostcampaignURL}
+	for _, nv := range nsu.Variants {
+		urls = append(urls, nv.URL)
+	}
+	if err := su.screen(ctx, urls...); err != nil {
+		return CreateShorturl{}, err
+	}
+
+	// A shorturl screened on creation isn't due for rescreening until the
+	// recheck interval has passed.
+	var screenedAt *time.Time
+	if su.checker != nil {
+		t := now.UTC()
+		screenedAt = &t
+	}
 
 	shorturl := CreateShorturl{
-		URL:         nsu.URL,
+		URL:         destination,
 		DateCreated: now.UTC(),
 		DateUpdated: now.UTC(),
 	}
 
+	policy := nsu.QueryPolicy
+	if policy == "" {
+		policy = QueryPolicyLink
+	}
+
+	tx, err := su.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return CreateShorturl{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
 	const q = `
 	INSERT INTO shorturls
-		(url, date_created, date_updated)
+		(url, sticky, redirect_code, passthrough, query_policy,
+		utm_source, utm_medium, utm_campaign, utm_term, utm_content,
+		conversion_tracking, public_stats, fallback_url,
+		active_from, active_until, prelaunch_url, postcampaign_url,
+		burn_after_reading, burn_confirm, signed_only, screened_at, date_created, date_updated)
 	VALUES
-		($1, $2, $3)
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
 		RETURNING shorturl_id;`
 
+	args := []interface{}{
+		shorturl.URL, nsu.Sticky, nsu.RedirectCode, nsu.Passthrough, policy,
+		nsu.UTM.Source, nsu.UTM.Medium, nsu.UTM.Campaign, nsu.UTM.Term, nsu.UTM.Content,
+		nsu.ConversionTracking, nsu.PublicStats, nsu.FallbackURL,
+		utcTime(nsu.ActiveFrom), utcTime(nsu.ActiveUntil), nsu.PrelaunchURL, nsu.PostcampaignURL,
+		nsu.BurnAfterReading, nsu.BurnConfirm, nsu.SignedOnly, screenedAt, shorturl.DateCreated, shorturl.DateUpdated,
+	}
+
 	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.Create",
-		database.Log(q, shorturl.URL, shorturl.DateCreated, shorturl.DateUpdated))
+		database.Log(q, args...))
 
-	if err := su.db.GetContext(ctx, &shorturl, q, shorturl.URL, shorturl.DateCreated, shorturl.DateUpdated); err != nil {
+	if err := tx.GetContext(ctx, &shorturl, q, args...); err != nil {
 		return CreateShorturl{}, errors.Wrap(err, "inserting shorturl")
 	}
 
+	const qv = `
+	INSERT INTO shorturl_variants
+		(shorturl_id, url, weight)
+	VALUES
+		($1, $2, $3)
+		RETURNING variant_id;`
+
+	for _, nv := range nsu.Variants {
+		destination, err := nsu.UTM.Apply(nv.URL)
+		if err != nil {
+			return CreateShorturl{}, errors.Wrap(err, "applying utm fields")
+		}
+
+		variant := Variant{
+			ShorturlID: shorturl.ID,
+			URL:        destination,
+			Weight:     nv.Weight,
+		}
+
+		su.log.Printf("%s : %s : query : %s", traceID, "shorturl.Create",
+			database.Log(qv, variant.ShorturlID, variant.URL, variant.Weight))
+
+		if err := tx.GetContext(ctx, &variant, qv, variant.ShorturlID, variant.URL, variant.Weight); err != nil {
+			return CreateShorturl{}, errors.Wrap(err, "inserting shorturl variant")
+		}
+		shorturl.Variants = append(shorturl.Variants, variant)
+	}
+
+	ev := webhook.Event{
+		Type: webhook.EventLinkCreated,
+		Data: webhook.LinkData{ID: shorturl.ID, Code: base62.Encode(shorturl.ID), URL: shorturl.URL},
+	}
+	if err := su.webhook.Enqueue(ctx, traceID, tx, ev, now); err != nil {
+		return CreateShorturl{}, errors.Wrap(err, "publishing shorturl")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return CreateShorturl{}, errors.Wrap(err, "committing shorturl")
+	}
+
 	su.log.Printf("%s : %s : query : %v", traceID, "shorturlID", nsu.URL)
 
 	return shorturl, nil
 }
 
+// Update modifies the settings of an existing shorturl.
+func (su Shorturl) Update(ctx context.Context, traceID string, claims auth.Claims, shorturl_id int, usu UpdateShorturl, now time.Time) error {
+
+	surl, err := su.QueryInfo(ctx, traceID, shorturl_id)
+	if err != nil {
+		return errors.Wrap(err, "updating shorturl")
+	}
+
+	if usu.PublicStats != nil {
+		surl.PublicStats = *usu.PublicStats
+	}
+	if usu.FallbackURL != nil {
+		surl.FallbackURL = *usu.FallbackURL
+	}
+	if usu.ClearWindow {
+		surl.ActiveFrom = nil
+		surl.ActiveUntil = nil
+	}
+	if usu.ActiveFrom != nil {
+		surl.ActiveFrom = utcTime(usu.ActiveFrom)
+	}
+	if usu.ActiveUntil != nil {
+		surl.ActiveUntil = utcTime(usu.ActiveUntil)
+	}
+	if usu.PrelaunchURL != nil {
+		surl.PrelaunchURL = *usu.PrelaunchURL
+	}
+	if usu.PostcampaignURL != nil {
+		surl.PostcampaignURL = *usu.PostcampaignURL
+	}
+	if usu.SignedOnly != nil {
+		surl.SignedOnly = *usu.SignedOnly
+	}
+	surl.DateUpdated = now
+
+	if !validWindow(surl.ActiveFrom, surl.ActiveUntil) {
+		return ErrInvalidWindow
+	}
+
+	if err := su.screen(ctx, surl.FallbackURL, surl.PrelaunchURL, surl.PostcampaignURL); err != nil {
+		return err
+	}
+
+	const q = `
+	UPDATE
+		shorturls
+	SET
+		"public_stats" = $2,
+		"fallback_url" = $3,
+		"active_from" = $4,
+		"active_until" = $5,
+		"expired_notified" = expired_notified AND active_until IS NOT DISTINCT FROM $5,
+		"prelaunch_url" = $6,
+		"postcampaign_url" = $7,
+		"signed_only" = $8,
+		"date_updated" = $9
+	WHERE
+		shorturl_id = $1`
+
+	args := []interface{}{
+		surl.ID, surl.PublicStats, surl.FallbackURL,
+		surl.ActiveFrom, surl.ActiveUntil, surl.PrelaunchURL, surl.PostcampaignURL, surl.SignedOnly, surl.DateUpdated,
+	}
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.Update",
+		database.Log(q, args...))
+
+	tx, err := su.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+		return errors.Wrapf(err, "updating shorturl %d", surl.ID)
+	}
+
+	ev := webhook.Event{
+		Type: webhook.EventLinkUpdated,
+		Data: webhook.LinkData{ID: surl.ID, Code: base62.Encode(surl.ID), URL: surl.URL},
+	}
+	if err := su.webhook.Enqueue(ctx, traceID, tx, ev, now); err != nil {
+		return errors.Wrapf(err, "publishing shorturl %d", surl.ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrapf(err, "committing shorturl %d", surl.ID)
+	}
+
+	return nil
+}
+
 // Delete removes a shorturl from the database.
-func (su Shorturl) Delete(ctx context.Context, traceID string, claims auth.Claims, shorturl_id int) error {
+func (su Shorturl) Delete(ctx context.Context, traceID string, claims auth.Claims, shorturl_id int, now time.Time) error {
+	tx, err := su.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := su.DeleteTx(ctx, traceID, tx, shorturl_id, now); err != nil {
+		if errors.Cause(err) == ErrNotFound {
+			return nil
+		}
+		return err
+	}
 
+	if err := tx.Commit(); err != nil {
+		return errors.Wrapf(err, "committing shorturl %d", shorturl_id)
+	}
+
+	return nil
+}
+
+// DeleteTx removes a shorturl as part of the transaction and publishes the
+// link.deleted event. It returns the destination of the removed shorturl.
+func (su Shorturl) DeleteTx(ctx context.Context, traceID string, tx sqlx.ExtContext, shorturl_id int, now time.Time) (string, error) {
 	const q = `
 	DELETE FROM
 		shorturls
 	WHERE
-		shorturl_id = $1;`
+		shorturl_id = $1
+		RETURNING url;`
 
 	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.Delete",
 		database.Log(q, shorturl_id))
 
-	if _, err := su.db.ExecContext(ctx, q, shorturl_id); err != nil {
-		return errors.Wrapf(err, "deleting shorturl %d", shorturl_id)
+	var destination string
+	if err := sqlx.GetContext(ctx, tx, &destination, q, shorturl_id); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", errors.Wrapf(err, "deleting shorturl %d", shorturl_id)
 	}
 
-	return nil
+	ev := webhook.Event{
+		Type: webhook.EventLinkDeleted,
+		Data: webhook.LinkData{ID: shorturl_id, Code: base62.Encode(shorturl_id), URL: destination},
+	}
+	if err := su.webhook.Enqueue(ctx, traceID, tx, ev, now); err != nil {
+		return "", errors.Wrapf(err, "publishing shorturl %d", shorturl_id)
+	}
+
+	return destination, nil
 }
 
 // Query retrieves a list of existing shorturls from the database.
-func (su Shorturl) Query(ctx context.Context, traceID string, pageNumber int, rowsPerPage int) ([]Info, error) {
+func (su Shorturl) Query(ctx context.Context, traceID string, filter QueryFilter, pageNumber int, rowsPerPage int) ([]Info, error) {
 
 	const q = `
 	SELECT
 		*
 	FROM
 		shorturls
+	WHERE
+		($1 = '' OR utm_campaign = $1) AND
+		(NOT $4 OR broken)
 	ORDER BY
 		shorturl_id
-	OFFSET $1 ROWS FETCH NEXT $2 ROWS ONLY`
+	OFFSET $2 ROWS FETCH NEXT $3 ROWS ONLY`
 
 	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.Query",
-		database.Log(q, pageNumber, rowsPerPage))
+		database.Log(q, filter.Campaign, pageNumber, rowsPerPage, filter.Broken))
 
 	shorturls := []Info{}
-	if err := su.db.SelectContext(ctx, &shorturls, q, pageNumber, rowsPerPage); err != nil {
+	if err := su.db.SelectContext(ctx, &shorturls, q, filter.Campaign, pageNumber, rowsPerPage, filter.Broken); err != nil {
 		if err == ErrNotFound {
 			return nil, ErrNotFound
 		}
@@ -112,53 +366,454 @@ func (su Shorturl) Query(ctx context.Context, traceID string, pageNumber int, ro
 	return shorturls, nil
 }
 
-// QueryByURL gets the specified shorturl from the database.
-func (su Shorturl) QueryByID(ctx context.Context, traceID string, shorturl_id int) (Info, error) {
+// QueryInfo gets the specified shorturl from the database without counting a
+// visit.
+func (su Shorturl) QueryInfo(ctx context.Context, traceID string, shorturl_id int) (Info, error) {
+
+	const q = `
+	SELECT
+		*
+	FROM
+		shorturls
+	WHERE
+		shorturl_id = $1`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.QueryInfo",
+		database.Log(q, shorturl_id))
+
+	var surl Info
+	if err := su.db.GetContext(ctx, &surl, q, shorturl_id); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting shorturl %d", shorturl_id)
+	}
+
+	return surl, nil
+}
+
+// QueryCampaigns retrieves the visits of every campaign aggregated over the
+// shorturls that belong to it.
+func (su Shorturl) QueryCampaigns(ctx context.Context, traceID string) ([]Campaign, error) {
+
+	const q = `
+	SELECT
+		utm_campaign, COUNT(*) AS links, COALESCE(SUM(visits), 0) AS visits
+	FROM
+		shorturls
+	WHERE
+		utm_campaign <> ''
+	GROUP BY
+		utm_campaign
+	ORDER BY
+		visits DESC, utm_campaign`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.QueryCampaigns",
+		database.Log(q))
+
+	campaigns := []Campaign{}
+	if err := su.db.SelectContext(ctx, &campaigns, q); err != nil {
+		return nil, errors.Wrap(err, "selecting campaigns")
+	}
+
+	return campaigns, nil
+}
+
+// QueryCampaign retrieves the visits of the specified campaign aggregated over
+// its shorturls, together with a breakdown by source and medium.
+func (su Shorturl) QueryCampaign(ctx context.Context, traceID string, campaign string) (Campaign, error) {
+
+	const q = `
+	SELECT
+		utm_source, utm_medium, COUNT(*) AS links, COALESCE(SUM(visits), 0) AS visits
+	FROM
+		shorturls
+	WHERE
+		utm_campaign = $1
+	GROUP BY
+		utm_source, utm_medium
+	ORDER BY
+		visits DESC, utm_source, utm_medium`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.QueryCampaign",
+		database.Log(q, campaign))
+
+	sources := []CampaignSource{}
+	if err := su.db.SelectContext(ctx, &sources, q, campaign); err != nil {
+		return Campaign{}, errors.Wrapf(err, "selecting campaign %q", campaign)
+	}
+	if len(sources) == 0 {
+		return Campaign{}, ErrNotFound
+	}
+
+	c := Campaign{
+		Campaign: campaign,
+		Sources:  sources,
+	}
+	for _, src := range sources {
+		c.Links += src.Links
+		c.Visits += src.Visits
+	}
+
+	return c, nil
+}
+
+// QueryByID resolves the destination of the specified shorturl and records
+// the visit. When the shorturl has variants one of them is picked by weight,
+// unless the visitor is already bound to a variant of a sticky shorturl. While
+// the primary destination is broken, visits go to the fallback URL if there
+// is one. Outside of its activation window the pre-launch or post-campaign
+// destination is returned instead and the visit is not recorded. A shorturl
+// that burns after reading resolves for a single visit only. Signed only
+// shorturls are not found unless the visit came through a signed code, and
+// disabled shorturls resolve to no destination at all.
+func (su Shorturl) QueryByID(ctx context.Context, traceID string, shorturl_id int, nv NewVisit, now time.Time) (Destination, error) {
+
+	tx, err := su.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Destination{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
 
+	// Bot visits are counted apart so they never inflate the human visits.
 	const q = `
 	UPDATE
 		shorturls
 	SET 
-		visits = visits + 1
+		visits = visits + CASE WHEN $2 THEN 0 ELSE 1 END,
+		bot_visits = bot_visits + CASE WHEN $2 THEN 1 ELSE 0 END
 	WHERE
 		shorturl_id = $1
-		RETURNING url`
+		RETURNING shorturl_id, url, sticky, redirect_code, passthrough, query_policy, conversion_tracking, fallback_url, broken,
+			active_from, active_until, prelaunch_url, postcampaign_url,
+			burn_after_reading, burn_confirm, burned_at, signed_only, disabled`
 
 	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.QueryByID",
-		database.Log(q, shorturl_id))
+		database.Log(q, shorturl_id, nv.Bot))
 
-	var shorturl Info
-	if err := su.db.GetContext(ctx, &shorturl, q, shorturl_id); err != nil {
+	var dest Destination
+	if err := tx.GetContext(ctx, &dest, q, shorturl_id, nv.Bot); err != nil {
 		if err == sql.ErrNoRows {
-			return Info{}, ErrNotFound
+			return Destination{}, ErrNotFound
 		}
-		return Info{}, errors.Wrapf(err, "selecting shorturl %q", shorturl_id)
+		return Destination{}, errors.Wrapf(err, "selecting shorturl %q", shorturl_id)
 	}
 
-	return shorturl, nil
+	if dest.SignedOnly && !nv.Signed {
+		return Destination{}, ErrNotFound
+	}
+
+	// Disabled shorturls don't reveal their destination.
+	if dest.Disabled {
+		return Destination{ShorturlID: dest.ShorturlID, Disabled: true}, nil
+	}
+
+	// The destination of a shorturl outside of its window must never leak,
+	// so it is replaced before anything else looks at it. Returning without
+	// committing drops the counted visit.
+	dest.Window = Window(dest.ActiveFrom, dest.ActiveUntil, now.UTC())
+	switch dest.Window {
+	case WindowPending:
+		dest.URL = dest.PrelaunchURL
+		return dest, nil
+	case WindowEnded:
+		dest.URL = dest.PostcampaignURL
+		if dest.URL == "" {
+			dest.URL = dest.FallbackURL
+		}
+		return dest, nil
+	}
+
+	if dest.BurnAfterReading {
+		if dest.BurnedAt != nil {
+			return Destination{}, ErrBurned
+		}
+
+		// Visits that can't burn the shorturl see the interstitial page
+		// without learning the destination. Link unfurlers fetch shorturls
+		// as soon as they are shared, so bots never burn one.
+		if nv.Preview || nv.Bot || (dest.BurnConfirm && !nv.Confirmed) {
+			return Destination{ShorturlID: dest.ShorturlID, Window: dest.Window, Confirm: true}, nil
+		}
+
+		if err := su.burn(ctx, tx, traceID, shorturl_id, now); err != nil {
+			return Destination{}, err
+		}
+	}
+
+	// A shorturl falling back sends every visit to the fallback URL, its
+	// variants included.
+	if dest.Broken && dest.FallbackURL != "" {
+		dest.URL = dest.FallbackURL
+		dest.Fallback = true
+	}
+
+	var variants []Variant
+	if !dest.Fallback {
+		variants, err = su.queryVariants(ctx, tx, traceID, shorturl_id)
+		if err != nil {
+			return Destination{}, err
+		}
+	}
+
+	if len(variants) > 0 {
+		variant, err := chooseVariant(variants, dest.Sticky, nv.VariantID)
+		if err != nil {
+			return Destination{}, errors.Wrap(err, "choosing variant")
+		}
+
+		if !nv.Bot {
+			const qv = `
+			UPDATE
+				shorturl_variants
+			SET
+				visits = visits + 1
+			WHERE
+				variant_id = $1`
+
+			su.log.Printf("%s : %s : query : %s", traceID, "shorturl.QueryByID",
+				database.Log(qv, variant.ID))
+
+			if _, err := tx.ExecContext(ctx, qv, variant.ID); err != nil {
+				return Destination{}, errors.Wrapf(err, "updating shorturl variant %d", variant.ID)
+			}
+		}
+
+		dest.VariantID = variant.ID
+		dest.URL = variant.URL
+	}
+
+	// Only human clicks get a click ID so unfurlers and crawlers can never
+	// be credited with a conversion. Fallback destinations don't convert.
+	if dest.ConversionTracking && !nv.Bot && !dest.Fallback {
+		dest.ClickID = uuid.New().String()
+	}
+
+	ua := useragent.Parse(nv.UserAgent)
+
+	const qc = `
+	INSERT INTO clicks
+		(shorturl_id, variant_id, referrer, browser, os, device, bot, tracking_id, fallback, date_created)
+	VALUES
+		($1, NULLIF($2, 0), $3, $4, $5, $6, $7, NULLIF($8, '')::UUID, $9, $10)`
+
+	args := []interface{}{
+		dest.ShorturlID, dest.VariantID, ReferrerDomain(nv.Referrer), ua.Browser, ua.OS, ua.Device, nv.Bot, dest.ClickID, dest.Fallback, now.UTC(),
+	}
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.QueryByID",
+		database.Log(qc, args...))
+
+	if _, err := tx.ExecContext(ctx, qc, args...); err != nil {
+		return Destination{}, errors.Wrapf(err, "inserting click for shorturl %d", shorturl_id)
+	}
+
+	if !nv.Bot && nv.Visitor != 0 {
+		if err := su.recordUnique(ctx, tx, traceID, shorturl_id, nv.Visitor, now); err != nil {
+			return Destination{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Destination{}, errors.Wrap(err, "committing visit")
+	}
+
+	return dest, nil
 }
 
-// QueryVisitation gets the number of visits for specified shorturl from the database.
-func (su Shorturl) QueryVisitation(ctx context.Context, traceID string, claims auth.Claims, shorturl_id int) (ShorturlVisits, error) {
+// QueryVisitation gets the number of visits for specified shorturl from the
+// database. The bots filter decides whether human visits, bot visits or both
+// are counted.
+func (su Shorturl) QueryVisitation(ctx context.Context, traceID string, claims auth.Claims, shorturl_id int, bots string) (ShorturlVisits, error) {
 
 	const q = `
 	SELECT
-		visits
+		CASE $2
+			WHEN 'include' THEN visits + bot_visits
+			WHEN 'only' THEN bot_visits
+			ELSE visits
+		END AS visits,
+		bot_visits,
+		visits AS human_visits,
+		(
+			SELECT
+				COUNT(*)
+			FROM
+				clicks
+			WHERE
+				clicks.shorturl_id = shorturls.shorturl_id AND fallback AND
+				($2 = 'include' OR bot = ($2 = 'only'))
+		) AS fallback_visits
 	FROM
 		shorturls
 	WHERE 
 		shorturl_id = $1`
 
 	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.queryVisitation",
-		database.Log(q, shorturl_id))
+		database.Log(q, shorturl_id, bots))
 
 	var visits ShorturlVisits
-	if err := su.db.GetContext(ctx, &visits, q, shorturl_id); err != nil {
+	if err := su.db.GetContext(ctx, &visits, q, shorturl_id, bots); err != nil {
 		if err == sql.ErrNoRows {
 			return ShorturlVisits{}, ErrNotFound
 		}
 		return ShorturlVisits{}, errors.Wrapf(err, "selecting shorturl visits %q", shorturl_id)
 	}
 
+	variants, err := su.queryVariants(ctx, su.db, traceID, shorturl_id)
+	if err != nil {
+		return ShorturlVisits{}, err
+	}
+	visits.Variants = variants
+
+	for _, b := range []struct {
+		column string
+		dest   *[]Breakdown
+	}{
+		{"referrer", &visits.Referrers},
+		{"browser", &visits.Browsers},
+		{"os", &visits.OSes},
+		{"device", &visits.Devices},
+	} {
+		breakdown, err := su.queryBreakdown(ctx, traceID, b.column, shorturl_id, bots)
+		if err != nil {
+			return ShorturlVisits{}, err
+		}
+		*b.dest = breakdown
+	}
+
+	if err := su.queryConversions(ctx, traceID, shorturl_id, &visits); err != nil {
+		return ShorturlVisits{}, err
+	}
+
 	return visits, nil
 }
+
+// queryBreakdown counts the recorded clicks of the specified shorturl grouped
+// by a column of the clicks table. The column is never user input.
+func (su Shorturl) queryBreakdown(ctx context.Context, traceID string, column string, shorturl_id int, bots string) ([]Breakdown, error) {
+
+	q := `
+	SELECT
+		` + column + ` AS name, COUNT(*) AS visits
+	FROM
+		clicks
+	WHERE
+		shorturl_id = $1 AND
+		($3 = 'include' OR bot = ($3 = 'only'))
+	GROUP BY
+		` + column + `
+	ORDER BY
+		visits DESC, name
+	LIMIT $2`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.queryBreakdown",
+		database.Log(q, shorturl_id, breakdownLimit, bots))
+
+	breakdown := []Breakdown{}
+	if err := su.db.SelectContext(ctx, &breakdown, q, shorturl_id, breakdownLimit, bots); err != nil {
+		return nil, errors.Wrapf(err, "selecting %s breakdown %d", column, shorturl_id)
+	}
+
+	return breakdown, nil
+}
+
+// ReferrerDomain reduces a Referer header to its host name. Visits without a
+// usable referrer are reported as direct.
+func ReferrerDomain(referrer string) string {
+	u, err := url.Parse(referrer)
+	if err != nil || u.Hostname() == "" {
+		return "direct"
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// burn marks a shorturl that burns after reading as visited. Only the
+// visit that flips burned_at gets through, every concurrent visit finds it
+// set.
+func (su Shorturl) burn(ctx context.Context, tx sqlx.ExecerContext, traceID string, shorturl_id int, now time.Time) error {
+
+	const q = `
+	UPDATE
+		shorturls
+	SET
+		burned_at = $2
+	WHERE
+		shorturl_id = $1 AND burned_at IS NULL`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.burn",
+		database.Log(q, shorturl_id, now.UTC()))
+
+	res, err := tx.ExecContext(ctx, q, shorturl_id, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "burning shorturl %d", shorturl_id)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "burning shorturl %d", shorturl_id)
+	}
+	if n == 0 {
+		return ErrBurned
+	}
+
+	return nil
+}
+
+// queryVariants retrieves the variants of the specified shorturl.
+func (su Shorturl) queryVariants(ctx context.Context, db sqlx.QueryerContext, traceID string, shorturl_id int) ([]Variant, error) {
+
+	const q = `
+	SELECT
+		variant_id, shorturl_id, url, weight, visits
+	FROM
+		shorturl_variants
+	WHERE
+		shorturl_id = $1
+	ORDER BY
+		variant_id`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.queryVariants",
+		database.Log(q, shorturl_id))
+
+	variants := []Variant{}
+	if err := sqlx.SelectContext(ctx, db, &variants, q, shorturl_id); err != nil {
+		return nil, errors.Wrapf(err, "selecting shorturl variants %d", shorturl_id)
+	}
+
+	return variants, nil
+}
+
+// chooseVariant returns the variant the visitor is bound to when the shorturl
+// is sticky, otherwise it picks one at random proportionally to its weight.
+func chooseVariant(variants []Variant, sticky bool, variantID int) (Variant, error) {
+	if sticky {
+		for _, v := range variants {
+			if v.ID == variantID {
+				return v, nil
+			}
+		}
+	}
+
+	var total int64
+	for _, v := range variants {
+		total += int64(v.Weight)
+	}
+	if total <= 0 {
+		return variants[0], nil
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(total))
+	if err != nil {
+		return Variant{}, err
+	}
+
+	pick := n.Int64()
+	for _, v := range variants {
+		if pick < int64(v.Weight) {
+			return v, nil
+		}
+		pick -= int64(v.Weight)
+	}
+
+	return variants[len(variants)-1], nil
+}
@@ -1,13 +1,16 @@
 package shorturl_test
 
 import (
+	"net/url"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/go-cmp/cmp"
 	"github.com/mitrovicsinisaa/shorturl/business/auth"
 	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/reputation"
 	"github.com/mitrovicsinisaa/shorturl/business/tests"
 	"github.com/pkg/errors"
 )
@@ -16,7 +19,7 @@ func TestShorturl(t *testing.T) {
 	log, db, teardown := tests.NewUnit(t)
 	t.Cleanup(teardown)
 
-	su := shorturl.New(log, db)
+	su := shorturl.New(log, db, nil)
 
 	t.Log("Given the need to work with Shorturl records.")
 	{
@@ -37,7 +40,7 @@ func TestShorturl(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to create shorturl.", tests.Success, testID)
 
-			_, err = su.QueryByID(ctx, traceID, surl.ID)
+			_, err = su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
 			}
@@ -53,16 +56,734 @@ func TestShorturl(t *testing.T) {
 				Roles: []string{auth.RoleUser},
 			}
 
-			if err := su.Delete(ctx, traceID, claims, surl.ID); err != nil {
+			if err := su.Delete(ctx, traceID, claims, surl.ID, now); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to delete shorturl : %s.", tests.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to delete shorturl.", tests.Success, testID)
 
-			_, err = su.QueryByID(ctx, traceID, surl.ID)
+			_, err = su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now)
 			if errors.Cause(err) != shorturl.ErrNotFound {
 				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve shorturl : %s.", tests.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve shorturl.", tests.Success, testID)
 		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen handling a Shorturl with weighted variants.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			ns := shorturl.NewShorturl{
+				URL: "https://github.com/mitrovicsinisaa/shorturl",
+				Variants: []shorturl.NewVariant{
+					{URL: "https://github.com/mitrovicsinisaa/shorturl?variant=a", Weight: 1},
+					{URL: "https://github.com/mitrovicsinisaa/shorturl?variant=b", Weight: 3},
+				},
+				Sticky: true,
+			}
+
+			surl, err := su.Create(ctx, traceID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			if len(surl.Variants) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould get back two variants : %d.", tests.Failed, testID, len(surl.Variants))
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create shorturl with variants.", tests.Success, testID)
+
+			dest, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{VariantID: surl.Variants[0].ID}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+			}
+			if dest.VariantID != surl.Variants[0].ID || dest.URL != surl.Variants[0].URL {
+				t.Fatalf("\t%s\tTest %d:\tShould stick to the requested variant : got %d.", tests.Failed, testID, dest.VariantID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould stick to the requested variant.", tests.Success, testID)
+
+			for i := 0; i < 10; i++ {
+				if _, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+				}
+			}
+
+			visits, err := su.QueryVisitation(ctx, traceID, auth.Claims{}, surl.ID, shorturl.BotsExclude)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve visits : %s.", tests.Failed, testID, err)
+			}
+			var total int
+			for _, v := range visits.Variants {
+				total += v.Visits
+			}
+			if visits.Visits != 11 || total != 11 {
+				t.Fatalf("\t%s\tTest %d:\tShould attribute every visit to a variant : %d/%d.", tests.Failed, testID, total, visits.Visits)
+			}
+			t.Logf("\t%s\tTest %d:\tShould attribute every visit to a variant.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen handling Shorturls of a campaign.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			for _, src := range []string{"newsletter", "twitter"} {
+				ns := shorturl.NewShorturl{
+					URL: "https://github.com/mitrovicsinisaa/shorturl",
+					UTM: shorturl.UTM{Source: src, Medium: "social", Campaign: "launch"},
+				}
+
+				surl, err := su.Create(ctx, traceID, ns, now)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+				}
+
+				if _, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create shorturls with utm fields.", tests.Success, testID)
+
+			c, err := su.QueryCampaign(ctx, traceID, "launch")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the campaign : %s.", tests.Failed, testID, err)
+			}
+			if c.Links != 2 || c.Visits != 2 || len(c.Sources) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould aggregate visits across the campaign : %+v.", tests.Failed, testID, c)
+			}
+			t.Logf("\t%s\tTest %d:\tShould aggregate visits across the campaign.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen handling visits from different clients.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			surl, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://github.com/mitrovicsinisaa/shorturl"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			nv := shorturl.NewVisit{
+				Referrer:  "https://www.google.com/search?q=shorturl",
+				UserAgent: "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0",
+			}
+			if _, err := su.QueryByID(ctx, traceID, surl.ID, nv, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+			}
+
+			visits, err := su.QueryVisitation(ctx, traceID, auth.Claims{}, surl.ID, shorturl.BotsExclude)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve visits : %s.", tests.Failed, testID, err)
+			}
+
+			want := []shorturl.Breakdown{{Name: "google.com", Visits: 1}}
+			if diff := cmp.Diff(visits.Referrers, want); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the referrer breakdown. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			want = []shorturl.Breakdown{{Name: "Firefox", Visits: 1}}
+			if diff := cmp.Diff(visits.Browsers, want); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the browser breakdown. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the visit breakdowns.", tests.Success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen handling visits from bots.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			surl, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://github.com/mitrovicsinisaa/shorturl"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			if _, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+			}
+			if _, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{Bot: true}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+			}
+
+			for _, tst := range []struct {
+				bots string
+				want int
+			}{
+				{shorturl.BotsExclude, 1},
+				{shorturl.BotsOnly, 1},
+				{shorturl.BotsInclude, 2},
+			} {
+				visits, err := su.QueryVisitation(ctx, traceID, auth.Claims{}, surl.ID, tst.bots)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve visits : %s.", tests.Failed, testID, err)
+				}
+				if visits.Visits != tst.want {
+					t.Fatalf("\t%s\tTest %d:\tShould count %d visits with bots %s : %d.", tests.Failed, testID, tst.want, tst.bots, visits.Visits)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould count bot visits apart from human visits.", tests.Success, testID)
+		}
+
+		testID = 5
+		t.Logf("\tTest %d:\tWhen handling repeated visits of the same visitors.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+			salt := []byte("salt")
+
+			surl, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://github.com/mitrovicsinisaa/shorturl"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"} {
+				nv := shorturl.NewVisit{Visitor: shorturl.VisitorHash(salt, ip, "Firefox")}
+				if _, err := su.QueryByID(ctx, traceID, surl.ID, nv, now); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+				}
+			}
+
+			uniques, err := su.QueryUniques(ctx, traceID, surl.ID, now, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve unique visitors : %s.", tests.Failed, testID, err)
+			}
+			if uniques.Uniques != 2 || len(uniques.Days) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould estimate two unique visitors : %+v.", tests.Failed, testID, uniques)
+			}
+			t.Logf("\t%s\tTest %d:\tShould estimate two unique visitors.", tests.Success, testID)
+		}
+
+		testID = 6
+		t.Logf("\tTest %d:\tWhen handling conversions of a tracked shorturl.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			nsu := shorturl.NewShorturl{
+				URL:                "https://github.com/mitrovicsinisaa/shorturl",
+				ConversionTracking: true,
+			}
+			surl, err := su.Create(ctx, traceID, nsu, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			var clickIDs []string
+			for i := 0; i < 4; i++ {
+				dest, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+				}
+				if dest.ClickID == "" {
+					t.Fatalf("\t%s\tTest %d:\tShould get a click ID for a tracked shorturl.", tests.Failed, testID)
+				}
+				clickIDs = append(clickIDs, dest.ClickID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a click ID for a tracked shorturl.", tests.Success, testID)
+
+			dest, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{Bot: true}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+			}
+			if dest.ClickID != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT get a click ID for a bot : %s.", tests.Failed, testID, dest.ClickID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT get a click ID for a bot.", tests.Success, testID)
+
+			nc := shorturl.NewConversion{ClickID: clickIDs[0], Value: 19.5, Currency: "EUR"}
+			if _, err := su.CreateConversion(ctx, traceID, nc, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to record a conversion : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to record a conversion.", tests.Success, testID)
+
+			if _, err := su.CreateConversion(ctx, traceID, nc, now); errors.Cause(err) != shorturl.ErrConversionExists {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to convert a click twice : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to convert a click twice.", tests.Success, testID)
+
+			unknown := shorturl.NewConversion{ClickID: "8d7f0b9e-2f59-4b0e-9c3b-1f2d3c4b5a69"}
+			if _, err := su.CreateConversion(ctx, traceID, unknown, now); errors.Cause(err) != shorturl.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to convert an unknown click : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to convert an unknown click.", tests.Success, testID)
+
+			visits, err := su.QueryVisitation(ctx, traceID, auth.Claims{}, surl.ID, shorturl.BotsExclude)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl visits : %s.", tests.Failed, testID, err)
+			}
+			if visits.Conversions != 1 || visits.ConversionRate != 0.25 {
+				t.Fatalf("\t%s\tTest %d:\tShould get one conversion out of four visits : %+v.", tests.Failed, testID, visits)
+			}
+			want := []shorturl.Revenue{{Currency: "EUR", Conversions: 1, Value: 19.5}}
+			if diff := cmp.Diff(visits.Revenue, want); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the revenue of the conversion. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the conversion rate and revenue.", tests.Success, testID)
+		}
+
+		testID = 7
+		t.Logf("\tTest %d:\tWhen exporting the clicks of a shorturl.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 1, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			surl, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://github.com/mitrovicsinisaa/shorturl"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			for _, visit := range []struct {
+				bot bool
+				at  time.Time
+			}{
+				{false, now},
+				{true, now},
+				{false, now.Add(24 * time.Hour)},
+			} {
+				if _, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{Bot: visit.bot}, visit.at); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+				}
+			}
+
+			filter := shorturl.ExportFilter{ShorturlID: surl.ID, From: now, To: now.Add(24 * time.Hour)}
+
+			var clicks []shorturl.Click
+			err = su.ExportClicks(ctx, traceID, filter, func(c shorturl.Click) error {
+				clicks = append(clicks, c)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to export clicks : %s.", tests.Failed, testID, err)
+			}
+			if len(clicks) != 3 || !clicks[1].Bot {
+				t.Fatalf("\t%s\tTest %d:\tShould export every click in order : %+v.", tests.Failed, testID, clicks)
+			}
+			t.Logf("\t%s\tTest %d:\tShould export every click in order.", tests.Success, testID)
+
+			var days []shorturl.DailyVisits
+			err = su.ExportDaily(ctx, traceID, filter, func(d shorturl.DailyVisits) error {
+				days = append(days, d)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to export daily visits : %s.", tests.Failed, testID, err)
+			}
+			want := []shorturl.DailyVisits{
+				{Day: time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC), ShorturlID: surl.ID, Visits: 1, BotVisits: 1},
+				{Day: time.Date(2021, time.July, 2, 0, 0, 0, 0, time.UTC), ShorturlID: surl.ID, Visits: 1},
+			}
+			if diff := cmp.Diff(days, want); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould export the visits of each day. Diff:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould export the visits of each day.", tests.Success, testID)
+		}
+
+		testID = 8
+		t.Logf("\tTest %d:\tWhen making the stats of a shorturl public.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 10, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			surl, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://github.com/mitrovicsinisaa/shorturl"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			if _, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{Referrer: "https://news.ycombinator.com/"}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+			}
+
+			if _, err := su.QueryPublicStats(ctx, traceID, surl.ID, now.Add(-48*time.Hour), now); errors.Cause(err) != shorturl.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to see private stats : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to see private stats.", tests.Success, testID)
+
+			public := true
+			if err := su.Update(ctx, traceID, auth.Claims{}, surl.ID, shorturl.UpdateShorturl{PublicStats: &public}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update shorturl : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update shorturl.", tests.Success, testID)
+
+			stats, err := su.QueryPublicStats(ctx, traceID, surl.ID, now.Add(-48*time.Hour), now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to see public stats : %s.", tests.Failed, testID, err)
+			}
+			if stats.Visits != 1 || len(stats.Days) != 3 || stats.Days[2].Visits != 1 || stats.Days[0].Visits != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould get the visits of every day : %+v.", tests.Failed, testID, stats)
+			}
+			if len(stats.Referrers) != 1 || stats.Referrers[0].Name != "news.ycombinator.com" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the referrers : %+v.", tests.Failed, testID, stats.Referrers)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to see public stats.", tests.Success, testID)
+		}
+
+		testID = 9
+		t.Logf("\tTest %d:\tWhen visiting a shorturl whose destination is broken.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 11, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			ns := shorturl.NewShorturl{
+				URL:         "https://github.com/mitrovicsinisaa/shorturl",
+				FallbackURL: "https://github.com/mitrovicsinisaa",
+			}
+			surl, err := su.Create(ctx, traceID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create shorturl.", tests.Success, testID)
+
+			dest, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+			}
+			if dest.Fallback || dest.URL != ns.URL {
+				t.Fatalf("\t%s\tTest %d:\tShould go to the destination while it is healthy : %+v.", tests.Failed, testID, dest)
+			}
+			t.Logf("\t%s\tTest %d:\tShould go to the destination while it is healthy.", tests.Success, testID)
+
+			if _, err := db.ExecContext(ctx, `UPDATE shorturls SET broken = true WHERE shorturl_id = $1`, surl.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to mark the destination broken : %s.", tests.Failed, testID, err)
+			}
+
+			dest, err = su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+			}
+			if !dest.Fallback || dest.URL != ns.FallbackURL {
+				t.Fatalf("\t%s\tTest %d:\tShould go to the fallback while the destination is broken : %+v.", tests.Failed, testID, dest)
+			}
+			t.Logf("\t%s\tTest %d:\tShould go to the fallback while the destination is broken.", tests.Success, testID)
+
+			visits, err := su.QueryVisitation(ctx, traceID, auth.Claims{}, surl.ID, shorturl.BotsExclude)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve visits : %s.", tests.Failed, testID, err)
+			}
+			if visits.Visits != 2 || visits.FallbackVisits != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould count the visits that fell back : %+v.", tests.Failed, testID, visits)
+			}
+			t.Logf("\t%s\tTest %d:\tShould count the visits that fell back.", tests.Success, testID)
+		}
+
+		testID = 10
+		t.Logf("\tTest %d:\tWhen visiting a shorturl with an activation window.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 12, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			from := now.Add(time.Hour)
+			until := now.Add(2 * time.Hour)
+			ns := shorturl.NewShorturl{
+				URL:             "https://github.com/mitrovicsinisaa/shorturl",
+				ActiveFrom:      &from,
+				ActiveUntil:     &until,
+				PrelaunchURL:    "https://github.com/mitrovicsinisaa",
+				PostcampaignURL: "https://github.com",
+			}
+			surl, err := su.Create(ctx, traceID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create shorturl.", tests.Success, testID)
+
+			visits := []struct {
+				at     time.Time
+				window string
+				url    string
+			}{
+				{now, shorturl.WindowPending, ns.PrelaunchURL},
+				{from, shorturl.WindowActive, ns.URL},
+				{until, shorturl.WindowEnded, ns.PostcampaignURL},
+			}
+			for _, visit := range visits {
+				dest, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, visit.at)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shorturl by ID: %s.", tests.Failed, testID, err)
+				}
+				if dest.Window != visit.window || dest.URL != visit.url {
+					t.Fatalf("\t%s\tTest %d:\tShould go to %s while %s : %+v.", tests.Failed, testID, visit.url, visit.window, dest)
+				}
+				t.Logf("\t%s\tTest %d:\tShould go to %s while %s.", tests.Success, testID, visit.url, visit.window)
+			}
+
+			sv, err := su.QueryVisitation(ctx, traceID, auth.Claims{}, surl.ID, shorturl.BotsExclude)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve visits : %s.", tests.Failed, testID, err)
+			}
+			if sv.Visits != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould only count visits within the window : %d.", tests.Failed, testID, sv.Visits)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only count visits within the window.", tests.Success, testID)
+
+			early := until.Add(-3 * time.Hour)
+			if err := su.Update(ctx, traceID, auth.Claims{}, surl.ID, shorturl.UpdateShorturl{ActiveFrom: &early, ActiveUntil: &early}, now); errors.Cause(err) != shorturl.ErrInvalidWindow {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to end the window before it starts : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to end the window before it starts.", tests.Success, testID)
+
+			if err := su.Update(ctx, traceID, auth.Claims{}, surl.ID, shorturl.UpdateShorturl{ClearWindow: true}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to clear the window : %v.", tests.Failed, testID, err)
+			}
+			dest, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, until)
+			if err != nil || dest.Window != shorturl.WindowActive || dest.URL != ns.URL {
+				t.Fatalf("\t%s\tTest %d:\tShould always be active without a window : %+v : %v.", tests.Failed, testID, dest, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould always be active without a window.", tests.Success, testID)
+		}
+
+		testID = 11
+		t.Logf("\tTest %d:\tWhen visiting a shorturl that burns after reading.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 13, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			ns := shorturl.NewShorturl{
+				URL:              "https://github.com/mitrovicsinisaa/shorturl",
+				BurnAfterReading: true,
+			}
+			surl, err := su.Create(ctx, traceID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create shorturl.", tests.Success, testID)
+
+			dest, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{Preview: true}, now)
+			if err != nil || !dest.Confirm || dest.URL != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT burn the shorturl on a preview : %+v : %v.", tests.Failed, testID, dest, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT burn the shorturl on a preview.", tests.Success, testID)
+
+			dest, err = su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{Bot: true}, now)
+			if err != nil || !dest.Confirm || dest.URL != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT burn the shorturl on a bot visit : %+v : %v.", tests.Failed, testID, dest, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT burn the shorturl on a bot visit.", tests.Success, testID)
+
+			// Concurrent visits race for the shorturl, only one of them may
+			// get through.
+			const visitors = 5
+			results := make(chan error, visitors)
+			for i := 0; i < visitors; i++ {
+				go func() {
+					_, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now)
+					results <- err
+				}()
+			}
+			var ok, burned int
+			for i := 0; i < visitors; i++ {
+				switch err := <-results; err {
+				case nil:
+					ok++
+				case shorturl.ErrBurned:
+					burned++
+				default:
+					t.Fatalf("\t%s\tTest %d:\tShould be able to visit shorturl : %s.", tests.Failed, testID, err)
+				}
+			}
+			if ok != 1 || burned != visitors-1 {
+				t.Fatalf("\t%s\tTest %d:\tShould redirect exactly one visit : %d redirected, %d burned.", tests.Failed, testID, ok, burned)
+			}
+			t.Logf("\t%s\tTest %d:\tShould redirect exactly one visit.", tests.Success, testID)
+
+			ns.BurnConfirm = true
+			surl, err = su.Create(ctx, traceID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			dest, err = su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now)
+			if err != nil || !dest.Confirm || dest.URL != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould ask to confirm the visit : %+v : %v.", tests.Failed, testID, dest, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould ask to confirm the visit.", tests.Success, testID)
+
+			dest, err = su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{Confirmed: true}, now)
+			if err != nil || dest.URL != ns.URL {
+				t.Fatalf("\t%s\tTest %d:\tShould redirect a confirmed visit : %+v : %v.", tests.Failed, testID, dest, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould redirect a confirmed visit.", tests.Success, testID)
+
+			if _, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{Confirmed: true}, now); err != shorturl.ErrBurned {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT redirect a second confirmed visit : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT redirect a second confirmed visit.", tests.Success, testID)
+		}
+
+		testID = 12
+		t.Logf("\tTest %d:\tWhen visiting a signed only shorturl.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 14, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			ns := shorturl.NewShorturl{
+				URL:        "https://github.com/mitrovicsinisaa/shorturl",
+				SignedOnly: true,
+			}
+			surl, err := su.Create(ctx, traceID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create shorturl.", tests.Success, testID)
+
+			if _, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now); err != shorturl.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT find the shorturl by its plain code : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT find the shorturl by its plain code.", tests.Success, testID)
+
+			dest, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{Signed: true}, now)
+			if err != nil || dest.URL != ns.URL {
+				t.Fatalf("\t%s\tTest %d:\tShould find the shorturl by a signed code : %+v : %v.", tests.Failed, testID, dest, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould find the shorturl by a signed code.", tests.Success, testID)
+		}
+
+		testID = 13
+		t.Logf("\tTest %d:\tWhen creating shorturls to destinations with a bad reputation.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 15, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			screened := shorturl.New(log, db, reputation.NewBlocklist("blocklist", []string{"phishing.example"}))
+
+			for _, ns := range []shorturl.NewShorturl{
+				{URL: "https://login.phishing.example/account"},
+				{URL: "https://github.com/mitrovicsinisaa/shorturl", FallbackURL: "https://phishing.example/"},
+				{URL: "https://github.com/mitrovicsinisaa/shorturl", Variants: []shorturl.NewVariant{{URL: "https://phishing.example/b", Weight: 1}}},
+			} {
+				if _, err := screened.Create(ctx, traceID, ns, now); errors.Cause(err) != shorturl.ErrMalicious {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create shorturl to %+v : %v.", tests.Failed, testID, ns, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create shorturls to blocked destinations.", tests.Success, testID)
+
+			surl, err := screened.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://github.com/mitrovicsinisaa/shorturl"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create shorturl.", tests.Success, testID)
+
+			info, err := screened.QueryInfo(ctx, traceID, surl.ID)
+			if err != nil || info.ScreenedAt == nil || !info.ScreenedAt.Equal(now) {
+				t.Fatalf("\t%s\tTest %d:\tShould mark the new shorturl screened : %+v : %v.", tests.Failed, testID, info.ScreenedAt, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould mark the new shorturl screened.", tests.Success, testID)
+
+			fallback := "https://www.phishing.example/"
+			if err := screened.Update(ctx, traceID, auth.Claims{}, surl.ID, shorturl.UpdateShorturl{FallbackURL: &fallback}, now); errors.Cause(err) != shorturl.ErrMalicious {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to update shorturl to a blocked fallback : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to update shorturl to a blocked fallback.", tests.Success, testID)
+		}
+	}
+}
+
+func TestForward(t *testing.T) {
+	tt := []struct {
+		name   string
+		dest   string
+		path   string
+		query  url.Values
+		policy string
+		want   string
+	}{
+		{"path", "https://docs.example/v2/", "getting-started", nil, shorturl.QueryPolicyLink, "https://docs.example/v2/getting-started"},
+		{"query", "https://docs.example/v2", "", url.Values{"lang": {"de"}}, shorturl.QueryPolicyLink, "https://docs.example/v2?lang=de"},
+		{"linkWins", "https://docs.example?lang=en", "", url.Values{"lang": {"de"}}, shorturl.QueryPolicyLink, "https://docs.example?lang=en"},
+		{"requestWins", "https://docs.example?lang=en", "", url.Values{"lang": {"de"}}, shorturl.QueryPolicyRequest, "https://docs.example?lang=de"},
+		{"append", "https://docs.example?lang=en", "", url.Values{"lang": {"de"}}, shorturl.QueryPolicyAppend, "https://docs.example?lang=en&lang=de"},
+	}
+
+	t.Log("Given the need to forward the extra path and query of a request.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen handling the %s case.", testID, tst.name)
+			{
+				got, err := shorturl.Forward(tst.dest, tst.path, tst.query, tst.policy)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to forward the request : %s.", tests.Failed, testID, err)
+				}
+				if got != tst.want {
+					t.Fatalf("\t%s\tTest %d:\tShould get %q : got %q.", tests.Failed, testID, tst.want, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected destination.", tests.Success, testID)
+			}
+		}
+	}
+}
+
+func TestUTMApply(t *testing.T) {
+	utm := shorturl.UTM{Source: "newsletter", Medium: "email", Campaign: "launch"}
+
+	t.Log("Given the need to merge utm fields into a destination.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the destination has its own query.", testID)
+		{
+			got, err := utm.Apply("https://example.com/landing?ref=1&utm_source=old")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to apply the utm fields : %s.", tests.Failed, testID, err)
+			}
+
+			want := "https://example.com/landing?ref=1&utm_campaign=launch&utm_medium=email&utm_source=newsletter"
+			if got != want {
+				t.Fatalf("\t%s\tTest %d:\tShould get %q : got %q.", tests.Failed, testID, want, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the expected destination.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the destination can't be parsed.", testID)
+		{
+			if _, err := utm.Apply("https://example.com/%zz"); errors.Cause(err) != shorturl.ErrInvalidDestination {
+				t.Fatalf("\t%s\tTest %d:\tShould report an invalid destination : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report an invalid destination.", tests.Success, testID)
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	now := time.Date(2021, time.July, 12, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	tt := []struct {
+		name  string
+		from  *time.Time
+		until *time.Time
+		want  string
+	}{
+		{"open", nil, nil, shorturl.WindowActive},
+		{"pending", &after, nil, shorturl.WindowPending},
+		{"started", &now, nil, shorturl.WindowActive},
+		{"running", &before, &after, shorturl.WindowActive},
+		{"ended", nil, &now, shorturl.WindowEnded},
+	}
+
+	t.Log("Given the need to place a visit in the activation window of a shorturl.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen handling the %s case.", testID, tst.name)
+			{
+				if got := shorturl.Window(tst.from, tst.until, now); got != tst.want {
+					t.Fatalf("\t%s\tTest %d:\tShould get %q : got %q.", tests.Failed, testID, tst.want, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected position.", tests.Success, testID)
+			}
+		}
 	}
 }
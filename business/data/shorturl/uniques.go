@@ -0,0 +1,193 @@
+package shorturl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mitrovicsinisaa/shorturl/business/hll"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// VisitorHash identifies a visitor by a salted hash of their IP address and
+// user agent so unique visitors can be counted without storing either.
+func VisitorHash(salt []byte, ip string, userAgent string) uint64 {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(ip))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userAgent))
+
+	return binary.BigEndian.Uint64(mac.Sum(nil)[:8])
+}
+
+// recordUnique adds the visitor to the sketch of the shorturl for the day of
+// the visit. The row is locked so concurrent visits do not lose updates.
+func (su Shorturl) recordUnique(ctx context.Context, tx *sqlx.Tx, traceID string, shorturl_id int, visitor uint64, now time.Time) error {
+	empty, err := hll.New(hll.DefaultPrecision)
+	if err != nil {
+		return err
+	}
+	data, err := empty.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	day := now.UTC().Truncate(24 * time.Hour)
+
+	const qi = `
+	INSERT INTO shorturl_uniques
+		(shorturl_id, day, sketch)
+	VALUES
+		($1, $2, $3)
+	ON CONFLICT DO NOTHING`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.recordUnique",
+		database.Log(qi, shorturl_id, day, "<sketch>"))
+
+	if _, err := tx.ExecContext(ctx, qi, shorturl_id, day, data); err != nil {
+		return errors.Wrapf(err, "inserting sketch for shorturl %d", shorturl_id)
+	}
+
+	const qs = `
+	SELECT
+		sketch
+	FROM
+		shorturl_uniques
+	WHERE
+		shorturl_id = $1 AND day = $2
+	FOR UPDATE`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.recordUnique",
+		database.Log(qs, shorturl_id, day))
+
+	if err := tx.GetContext(ctx, &data, qs, shorturl_id, day); err != nil {
+		return errors.Wrapf(err, "selecting sketch for shorturl %d", shorturl_id)
+	}
+
+	var sketch hll.Sketch
+	if err := sketch.UnmarshalBinary(data); err != nil {
+		return errors.Wrapf(err, "decoding sketch for shorturl %d", shorturl_id)
+	}
+	sketch.Add(visitor)
+
+	if data, err = sketch.MarshalBinary(); err != nil {
+		return err
+	}
+
+	const qu = `
+	UPDATE
+		shorturl_uniques
+	SET
+		sketch = $3
+	WHERE
+		shorturl_id = $1 AND day = $2`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.recordUnique",
+		database.Log(qu, shorturl_id, day, "<sketch>"))
+
+	if _, err := tx.ExecContext(ctx, qu, shorturl_id, day, data); err != nil {
+		return errors.Wrapf(err, "updating sketch for shorturl %d", shorturl_id)
+	}
+
+	return nil
+}
+
+// QueryUniques estimates the unique visitors of the specified shorturl for
+// every day in the range and for the range as a whole.
+func (su Shorturl) QueryUniques(ctx context.Context, traceID string, shorturl_id int, from time.Time, to time.Time) (Uniques, error) {
+
+	const q = `
+	SELECT
+		day, sketch
+	FROM
+		shorturl_uniques
+	WHERE
+		shorturl_id = $1 AND day BETWEEN $2 AND $3`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.QueryUniques",
+		database.Log(q, shorturl_id, from, to))
+
+	var sketches []daySketch
+	if err := su.db.SelectContext(ctx, &sketches, q, shorturl_id, from, to); err != nil {
+		return Uniques{}, errors.Wrapf(err, "selecting sketches for shorturl %d", shorturl_id)
+	}
+
+	return mergeSketches(sketches)
+}
+
+// QueryCampaignUniques estimates the unique visitors across all shorturls of
+// the specified campaign for every day in the range and for the range as a
+// whole.
+func (su Shorturl) QueryCampaignUniques(ctx context.Context, traceID string, campaign string, from time.Time, to time.Time) (Uniques, error) {
+
+	const q = `
+	SELECT
+		u.day, u.sketch
+	FROM
+		shorturl_uniques AS u
+	JOIN
+		shorturls AS s ON s.shorturl_id = u.shorturl_id
+	WHERE
+		s.utm_campaign = $1 AND u.day BETWEEN $2 AND $3`
+
+	su.log.Printf("%s : %s : query : %s", traceID, "shorturl.QueryCampaignUniques",
+		database.Log(q, campaign, from, to))
+
+	var sketches []daySketch
+	if err := su.db.SelectContext(ctx, &sketches, q, campaign, from, to); err != nil {
+		return Uniques{}, errors.Wrapf(err, "selecting sketches for campaign %q", campaign)
+	}
+
+	return mergeSketches(sketches)
+}
+
+// daySketch is a serialized sketch of a single shorturl and day.
+type daySketch struct {
+	Day    time.Time `db:"day"`
+	Sketch []byte    `db:"sketch"`
+}
+
+// mergeSketches merges the sketches per day and over the whole range.
+func mergeSketches(sketches []daySketch) (Uniques, error) {
+	total, err := hll.New(hll.DefaultPrecision)
+	if err != nil {
+		return Uniques{}, err
+	}
+
+	days := make(map[time.Time]*hll.Sketch)
+	for _, ds := range sketches {
+		var sketch hll.Sketch
+		if err := sketch.UnmarshalBinary(ds.Sketch); err != nil {
+			return Uniques{}, errors.Wrapf(err, "decoding sketch for %s", ds.Day.Format("2006-01-02"))
+		}
+
+		if err := total.Merge(&sketch); err != nil {
+			return Uniques{}, err
+		}
+
+		day, ok := days[ds.Day]
+		if !ok {
+			days[ds.Day] = &sketch
+			continue
+		}
+		if err := day.Merge(&sketch); err != nil {
+			return Uniques{}, err
+		}
+	}
+
+	u := Uniques{
+		Uniques: total.Estimate(),
+		Days:    []DailyUniques{},
+	}
+	for day, sketch := range days {
+		u.Days = append(u.Days, DailyUniques{Day: day, Uniques: sketch.Estimate()})
+	}
+	sort.Slice(u.Days, func(i, j int) bool { return u.Days[i].Day.Before(u.Days[j].Day) })
+
+	return u, nil
+}
@@ -0,0 +1,38 @@
+package shorturl
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// Apply merges the campaign fields into the destination as utm_* query
+// parameters. Fields that are set replace parameters already present in the
+// destination, empty fields leave them untouched. A destination that can't be
+// parsed is reported as ErrInvalidDestination.
+func (u UTM) Apply(destination string) (string, error) {
+	if u == (UTM{}) {
+		return destination, nil
+	}
+
+	d, err := url.Parse(destination)
+	if err != nil {
+		return "", errors.Wrapf(ErrInvalidDestination, "parsing destination %q: %v", destination, err)
+	}
+
+	q := d.Query()
+	for k, v := range map[string]string{
+		"utm_source":   u.Source,
+		"utm_medium":   u.Medium,
+		"utm_campaign": u.Campaign,
+		"utm_term":     u.Term,
+		"utm_content":  u.Content,
+	} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	d.RawQuery = q.Encode()
+
+	return d.String(), nil
+}
@@ -0,0 +1,39 @@
+package shorturl
+
+import "time"
+
+// Set of positions of a visit relative to the activation window of a
+// shorturl.
+const (
+	WindowPending = "pending"
+	WindowActive  = "active"
+	WindowEnded   = "ended"
+)
+
+// Window reports where now falls in the activation window running from
+// from, inclusive, until until, exclusive. A missing bound leaves that side
+// of the window open.
+func Window(from *time.Time, until *time.Time, now time.Time) string {
+	switch {
+	case from != nil && now.Before(*from):
+		return WindowPending
+	case until != nil && !now.Before(*until):
+		return WindowEnded
+	default:
+		return WindowActive
+	}
+}
+
+// validWindow reports whether the window ends after it starts.
+func validWindow(from *time.Time, until *time.Time) bool {
+	return from == nil || until == nil || until.After(*from)
+}
+
+// utcTime converts a bound of the window to UTC for the TIMESTAMP columns.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
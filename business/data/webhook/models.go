@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Set of events that can be subscribed to.
+const (
+	EventLinkCreated    = "link.created"
+	EventLinkUpdated    = "link.updated"
+	EventLinkDeleted    = "link.deleted"
+	EventLinkExpired    = "link.expired"
+	EventLinkDisabled   = "link.disabled"
+	EventVisitMilestone = "visit.milestone"
+)
+
+// Set of delivery states.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Info represents a webhook subscription. The secret is only returned when
+// the subscription is created.
+type Info struct {
+	ID             int            `db:"subscription_id" json:"id"`
+	URL            string         `db:"url" json:"url"`
+	Secret         string         `db:"secret" json:"-"`
+	Events         pq.StringArray `db:"events" json:"events"`
+	MilestoneEvery int            `db:"milestone_every" json:"milestone_every"`
+	DateCreated    time.Time      `db:"date_created" json:"date_created"`
+}
+
+// NewSubscription contains information needed to create a new subscription.
+// MilestoneEvery is the number of visits between visit.milestone events.
+type NewSubscription struct {
+	URL            string   `json:"url" validate:"required,url"`
+	Events         []string `json:"events" validate:"required,min=1,dive,oneof=link.created link.updated link.deleted link.expired link.disabled visit.milestone"`
+	MilestoneEvery int      `json:"milestone_every" validate:"gte=0"`
+}
+
+// Event is something that happened to a shorturl that subscribers are told
+// about. Data is encoded as the data field of the delivered document.
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+// LinkData is the data of link events.
+type LinkData struct {
+	ID   int    `json:"id"`
+	Code string `json:"code"`
+	URL  string `json:"url,omitempty"`
+}
+
+// MilestoneData is the data of visit.milestone events.
+type MilestoneData struct {
+	LinkData
+	Visits    int `json:"visits"`
+	Milestone int `json:"milestone"`
+}
+
+// Delivery represents one event sent, or still to be sent, to a subscription.
+type Delivery struct {
+	ID             int64           `db:"delivery_id" json:"id"`
+	SubscriptionID int             `db:"subscription_id" json:"subscription_id"`
+	Event          string          `db:"event" json:"event"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	NextAttempt    time.Time       `db:"next_attempt" json:"next_attempt"`
+	ResponseCode   int             `db:"response_code" json:"response_code"`
+	Error          string          `db:"error" json:"error"`
+	DateCreated    time.Time       `db:"date_created" json:"date_created"`
+	DateDelivered  *time.Time      `db:"date_delivered" json:"date_delivered,omitempty"`
+}
+
+// eventJob is the payload of the job that fans a published event out to its
+// subscriptions.
+type eventJob struct {
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// deliverJob is the payload of the job that sends a single delivery.
+type deliverJob struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
@@ -0,0 +1,568 @@
+// Package webhook lets other systems subscribe to shorturl events. Events are
+// published as jobs in the transaction of the write they describe, fanned out
+// to a delivery per subscription and sent, signed with the secret of the
+// subscription, by the job runner until they succeed or run out of attempts.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/mitrovicsinisaa/shorturl/business/jobs"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// Set of job kinds run for webhooks.
+const (
+	KindEvent   = "webhook.event"
+	KindDeliver = "webhook.deliver"
+)
+
+// Set of headers sent with every delivery.
+const (
+	SignatureHeader = "X-Shorturl-Signature"
+	EventHeader     = "X-Shorturl-Event"
+	DeliveryHeader  = "X-Shorturl-Delivery"
+)
+
+// Set of parameters of deliveries.
+const (
+
+	// maxAttempts is the number of attempts after which a delivery is
+	// marked as failed.
+	maxAttempts = 8
+
+	// maxErrorLength bounds the response stored for failed attempts.
+	maxErrorLength = 512
+)
+
+// Webhook manages the set of API's for webhook access.
+type Webhook struct {
+	log  *log.Logger
+	db   *sqlx.DB
+	jobs jobs.Jobs
+}
+
+// New constructs a Webhook for api access.
+func New(log *log.Logger, db *sqlx.DB) Webhook {
+	return Webhook{
+		log:  log,
+		db:   db,
+		jobs: jobs.New(log, db),
+	}
+}
+
+// Create adds a subscription to the database. A secret for verifying the
+// signature of deliveries is generated and returned only here. Shorturls
+// that already passed milestones do not fire for them.
+func (wh Webhook) Create(ctx context.Context, traceID string, ns NewSubscription, now time.Time) (Info, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Info{}, errors.Wrap(err, "generating secret")
+	}
+
+	sub := Info{
+		URL:            ns.URL,
+		Secret:         hex.EncodeToString(secret),
+		Events:         ns.Events,
+		MilestoneEvery: ns.MilestoneEvery,
+		DateCreated:    now.UTC(),
+	}
+
+	tx, err := wh.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Info{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	INSERT INTO webhook_subscriptions
+		(url, secret, events, milestone_every, date_created)
+	VALUES
+		($1, $2, $3, $4, $5)
+		RETURNING subscription_id`
+
+	wh.log.Printf("%s : %s : query : %s", traceID, "webhook.Create",
+		database.Log(q, sub.URL, "***", sub.Events, sub.MilestoneEvery, sub.DateCreated))
+
+	if err := tx.GetContext(ctx, &sub.ID, q, sub.URL, sub.Secret, sub.Events, sub.MilestoneEvery, sub.DateCreated); err != nil {
+		return Info{}, errors.Wrap(err, "inserting subscription")
+	}
+
+	if sub.MilestoneEvery > 0 {
+		const qm = `
+		INSERT INTO webhook_milestones
+			(subscription_id, shorturl_id, reached)
+		SELECT
+			$1, shorturl_id, (visits / $2) * $2
+		FROM
+			shorturls
+		WHERE
+			visits >= $2`
+
+		wh.log.Printf("%s : %s : query : %s", traceID, "webhook.Create",
+			database.Log(qm, sub.ID, sub.MilestoneEvery))
+
+		if _, err := tx.ExecContext(ctx, qm, sub.ID, sub.MilestoneEvery); err != nil {
+			return Info{}, errors.Wrap(err, "seeding milestones")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Info{}, errors.Wrap(err, "committing subscription")
+	}
+
+	return sub, nil
+}
+
+// Delete removes a subscription together with its deliveries.
+func (wh Webhook) Delete(ctx context.Context, traceID string, subscriptionID int) error {
+
+	const q = `
+	DELETE FROM
+		webhook_subscriptions
+	WHERE
+		subscription_id = $1`
+
+	wh.log.Printf("%s : %s : query : %s", traceID, "webhook.Delete",
+		database.Log(q, subscriptionID))
+
+	if _, err := wh.db.ExecContext(ctx, q, subscriptionID); err != nil {
+		return errors.Wrapf(err, "deleting subscription %d", subscriptionID)
+	}
+
+	return nil
+}
+
+// Query retrieves every subscription.
+func (wh Webhook) Query(ctx context.Context, traceID string) ([]Info, error) {
+
+	const q = `
+	SELECT
+		*
+	FROM
+		webhook_subscriptions
+	ORDER BY
+		subscription_id`
+
+	wh.log.Printf("%s : %s : query : %s", traceID, "webhook.Query",
+		database.Log(q))
+
+	subs := []Info{}
+	if err := wh.db.SelectContext(ctx, &subs, q); err != nil {
+		return nil, errors.Wrap(err, "selecting subscriptions")
+	}
+
+	return subs, nil
+}
+
+// QueryDeliveries retrieves the deliveries of a subscription, most recent
+// first.
+func (wh Webhook) QueryDeliveries(ctx context.Context, traceID string, subscriptionID int, pageNumber int, rowsPerPage int) ([]Delivery, error) {
+
+	const q = `
+	SELECT
+		*
+	FROM
+		webhook_deliveries
+	WHERE
+		subscription_id = $1
+	ORDER BY
+		delivery_id DESC
+	OFFSET $2 ROWS FETCH NEXT $3 ROWS ONLY`
+
+	wh.log.Printf("%s : %s : query : %s", traceID, "webhook.QueryDeliveries",
+		database.Log(q, subscriptionID, pageNumber, rowsPerPage))
+
+	deliveries := []Delivery{}
+	if err := wh.db.SelectContext(ctx, &deliveries, q, subscriptionID, pageNumber, rowsPerPage); err != nil {
+		return nil, errors.Wrapf(err, "selecting deliveries of subscription %d", subscriptionID)
+	}
+
+	return deliveries, nil
+}
+
+// Enqueue publishes the event using the transaction of the write it belongs
+// to. Deliveries to the subscriptions of the event are created by a job once
+// that transaction commits.
+func (wh Webhook) Enqueue(ctx context.Context, traceID string, tx sqlx.ExtContext, ev Event, now time.Time) error {
+	payload, err := encode(ev, now)
+	if err != nil {
+		return err
+	}
+
+	nj := jobs.NewJob{
+		Kind:    KindEvent,
+		Payload: eventJob{Event: ev.Type, Payload: payload},
+	}
+	if _, err := wh.jobs.Enqueue(ctx, traceID, tx, nj, now); err != nil {
+		return errors.Wrapf(err, "enqueuing %s", ev.Type)
+	}
+
+	return nil
+}
+
+// CheckMilestones enqueues a visit.milestone delivery for every shorturl that
+// passed a milestone of a subscription since the last check. A shorturl that
+// passed several milestones at once is only reported at the highest.
+func (wh Webhook) CheckMilestones(ctx context.Context, traceID string, now time.Time) error {
+	tx, err := wh.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	SELECT
+		ws.subscription_id, s.shorturl_id, s.url, s.visits,
+		(s.visits / ws.milestone_every) * ws.milestone_every AS milestone
+	FROM
+		webhook_subscriptions AS ws
+	CROSS JOIN
+		shorturls AS s
+	LEFT JOIN
+		webhook_milestones AS m ON m.subscription_id = ws.subscription_id AND m.shorturl_id = s.shorturl_id
+	WHERE
+		$1 = ANY(ws.events) AND
+		ws.milestone_every > 0 AND
+		(s.visits / ws.milestone_every) * ws.milestone_every > COALESCE(m.reached, 0)`
+
+	wh.log.Printf("%s : %s : query : %s", traceID, "webhook.CheckMilestones",
+		database.Log(q, EventVisitMilestone))
+
+	var reached []struct {
+		SubscriptionID int    `db:"subscription_id"`
+		ShorturlID     int    `db:"shorturl_id"`
+		URL            string `db:"url"`
+		Visits         int    `db:"visits"`
+		Milestone      int    `db:"milestone"`
+	}
+	if err := tx.SelectContext(ctx, &reached, q, EventVisitMilestone); err != nil {
+		return errors.Wrap(err, "selecting milestones")
+	}
+
+	const qm = `
+	INSERT INTO webhook_milestones
+		(subscription_id, shorturl_id, reached)
+	VALUES
+		($1, $2, $3)
+	ON CONFLICT (subscription_id, shorturl_id) DO UPDATE SET
+		reached = EXCLUDED.reached`
+
+	for _, r := range reached {
+		ev := Event{
+			Type: EventVisitMilestone,
+			Data: MilestoneData{
+				LinkData: LinkData{
+					ID:   r.ShorturlID,
+					Code: base62.Encode(r.ShorturlID),
+					URL:  r.URL,
+				},
+				Visits:    r.Visits,
+				Milestone: r.Milestone,
+			},
+		}
+		payload, err := encode(ev, now)
+		if err != nil {
+			return err
+		}
+
+		if err := wh.enqueueDelivery(ctx, traceID, tx, r.SubscriptionID, ev.Type, payload, now); err != nil {
+			return errors.Wrapf(err, "enqueuing milestone of shorturl %d", r.ShorturlID)
+		}
+
+		wh.log.Printf("%s : %s : query : %s", traceID, "webhook.CheckMilestones",
+			database.Log(qm, r.SubscriptionID, r.ShorturlID, r.Milestone))
+
+		if _, err := tx.ExecContext(ctx, qm, r.SubscriptionID, r.ShorturlID, r.Milestone); err != nil {
+			return errors.Wrapf(err, "recording milestone of shorturl %d", r.ShorturlID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing milestones")
+	}
+
+	return nil
+}
+
+// CheckExpirations publishes a link.expired event for every shorturl whose
+// activation window ended since the last check. Each end of a window is
+// published once, moving the end of the window publishes it again.
+func (wh Webhook) CheckExpirations(ctx context.Context, traceID string, now time.Time) error {
+	tx, err := wh.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	UPDATE
+		shorturls
+	SET
+		"expired_notified" = true
+	WHERE
+		active_until <= $1 AND NOT expired_notified
+		RETURNING shorturl_id, url`
+
+	wh.log.Printf("%s : %s : query : %s", traceID, "webhook.CheckExpirations",
+		database.Log(q, now.UTC()))
+
+	var expired []struct {
+		ShorturlID int    `db:"shorturl_id"`
+		URL        string `db:"url"`
+	}
+	if err := tx.SelectContext(ctx, &expired, q, now.UTC()); err != nil {
+		return errors.Wrap(err, "selecting expired shorturls")
+	}
+
+	for _, e := range expired {
+		ev := Event{
+			Type: EventLinkExpired,
+			Data: LinkData{ID: e.ShorturlID, Code: base62.Encode(e.ShorturlID), URL: e.URL},
+		}
+		if err := wh.Enqueue(ctx, traceID, tx, ev, now); err != nil {
+			return errors.Wrapf(err, "publishing expiration of shorturl %d", e.ShorturlID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing expirations")
+	}
+
+	return nil
+}
+
+// Register adds the handlers of the webhook jobs to the runner. Deliveries
+// are sent with the client.
+func (wh Webhook) Register(r *jobs.Runner, client *http.Client) {
+	r.Handle(KindEvent, wh.fanOut)
+	r.Handle(KindDeliver, func(ctx context.Context, traceID string, tx *sqlx.Tx, job jobs.Job, now time.Time) error {
+		return wh.deliver(ctx, traceID, client, job, now)
+	})
+}
+
+// fanOut creates a delivery of a published event for every subscription to
+// it.
+func (wh Webhook) fanOut(ctx context.Context, traceID string, tx *sqlx.Tx, job jobs.Job, now time.Time) error {
+	var ej eventJob
+	if err := job.Decode(&ej); err != nil {
+		return errors.Wrap(err, "decoding event")
+	}
+
+	const q = `
+	SELECT
+		subscription_id
+	FROM
+		webhook_subscriptions
+	WHERE
+		$1 = ANY(events)`
+
+	wh.log.Printf("%s : %s : query : %s", traceID, "webhook.fanOut",
+		database.Log(q, ej.Event))
+
+	var subs []int
+	if err := tx.SelectContext(ctx, &subs, q, ej.Event); err != nil {
+		return errors.Wrapf(err, "selecting subscriptions to %s", ej.Event)
+	}
+
+	for _, id := range subs {
+		if err := wh.enqueueDelivery(ctx, traceID, tx, id, ej.Event, ej.Payload, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enqueueDelivery stores a delivery to a subscription together with the job
+// that sends it.
+func (wh Webhook) enqueueDelivery(ctx context.Context, traceID string, tx *sqlx.Tx, subscriptionID int, event string, payload []byte, now time.Time) error {
+
+	const q = `
+	INSERT INTO webhook_deliveries
+		(subscription_id, event, payload, status, next_attempt, date_created)
+	VALUES
+		($1, $2, $3::JSONB, $4, $5, $5)
+		RETURNING delivery_id`
+
+	wh.log.Printf("%s : %s : query : %s", traceID, "webhook.enqueueDelivery",
+		database.Log(q, subscriptionID, event, string(payload), StatusPending, now.UTC()))
+
+	var id int64
+	if err := tx.GetContext(ctx, &id, q, subscriptionID, event, string(payload), StatusPending, now.UTC()); err != nil {
+		return errors.Wrapf(err, "inserting delivery to subscription %d", subscriptionID)
+	}
+
+	nj := jobs.NewJob{
+		Kind:        KindDeliver,
+		Payload:     deliverJob{DeliveryID: id},
+		MaxAttempts: maxAttempts,
+	}
+	if _, err := wh.jobs.Enqueue(ctx, traceID, tx, nj, now); err != nil {
+		return errors.Wrapf(err, "enqueuing delivery %d", id)
+	}
+
+	return nil
+}
+
+// deliver sends a single delivery and records the outcome of the attempt.
+// The outcome is written outside of the job transaction so failed attempts
+// show up in the delivery log while the job is retried.
+func (wh Webhook) deliver(ctx context.Context, traceID string, client *http.Client, job jobs.Job, now time.Time) error {
+	now = now.UTC()
+
+	var dj deliverJob
+	if err := job.Decode(&dj); err != nil {
+		return errors.Wrap(err, "decoding delivery")
+	}
+
+	const q = `
+	SELECT
+		d.delivery_id, d.event, d.payload, s.url, s.secret
+	FROM
+		webhook_deliveries AS d
+	JOIN
+		webhook_subscriptions AS s ON s.subscription_id = d.subscription_id
+	WHERE
+		d.delivery_id = $1`
+
+	wh.log.Printf("%s : %s : query : %s", traceID, "webhook.deliver",
+		database.Log(q, dj.DeliveryID))
+
+	var d struct {
+		ID      int64           `db:"delivery_id"`
+		Event   string          `db:"event"`
+		Payload json.RawMessage `db:"payload"`
+		URL     string          `db:"url"`
+		Secret  string          `db:"secret"`
+	}
+	if err := wh.db.GetContext(ctx, &d, q, dj.DeliveryID); err != nil {
+		if err == sql.ErrNoRows {
+
+			// The subscription was deleted together with its deliveries.
+			return nil
+		}
+		return errors.Wrapf(err, "selecting delivery %d", dj.DeliveryID)
+	}
+
+	code, sendErr := send(ctx, client, d.URL, d.Secret, d.ID, d.Event, d.Payload, now)
+
+	attempts := job.Attempts + 1
+	status := StatusDelivered
+	next := now
+	var msg string
+	var delivered *time.Time
+	switch {
+	case sendErr == nil:
+		delivered = &now
+	case attempts >= job.MaxAttempts:
+		status = StatusFailed
+		msg = sendErr.Error()
+	default:
+		status = StatusPending
+		next = now.Add(jobs.Backoff(attempts))
+		msg = sendErr.Error()
+	}
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+
+	const qu = `
+	UPDATE
+		webhook_deliveries
+	SET
+		"status" = $2,
+		"attempts" = $3,
+		"next_attempt" = $4,
+		"response_code" = $5,
+		"error" = $6,
+		"date_delivered" = $7
+	WHERE
+		delivery_id = $1`
+
+	wh.log.Printf("%s : %s : query : %s", traceID, "webhook.deliver",
+		database.Log(qu, d.ID, status, attempts, next, code, msg, delivered))
+
+	if _, err := wh.db.ExecContext(ctx, qu, d.ID, status, attempts, next, code, msg, delivered); err != nil {
+		return errors.Wrapf(err, "updating delivery %d", d.ID)
+	}
+
+	return sendErr
+}
+
+// Sign computes the signature header of a delivery. Receivers recompute the
+// HMAC-SHA256 of the timestamp, a dot and the body with their secret and
+// compare it with v1.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// send posts a single delivery and returns the response status code. Any
+// status outside of 2xx is an error.
+func send(ctx context.Context, client *http.Client, url string, secret string, deliveryID int64, event string, payload []byte, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(deliveryID, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, now.Unix(), payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxErrorLength))
+
+	return resp.StatusCode, nil
+}
+
+// encode builds the document delivered for an event.
+func encode(ev Event, now time.Time) ([]byte, error) {
+	doc := struct {
+		Event string      `json:"event"`
+		Time  time.Time   `json:"time"`
+		Data  interface{} `json:"data"`
+	}{
+		Event: ev.Type,
+		Time:  now.UTC(),
+		Data:  ev.Data,
+	}
+
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encoding %s", ev.Type)
+	}
+
+	return payload, nil
+}
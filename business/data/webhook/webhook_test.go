@@ -0,0 +1,221 @@
+package webhook_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/auth"
+	"github.com/mitrovicsinisaa/shorturl/business/data/shorturl"
+	"github.com/mitrovicsinisaa/shorturl/business/data/webhook"
+	"github.com/mitrovicsinisaa/shorturl/business/jobs"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+func TestWebhook(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	wh := webhook.New(log, db)
+	su := shorturl.New(log, db, nil)
+
+	runner := jobs.NewRunner(log, db, jobs.Config{})
+	wh.Register(runner, http.DefaultClient)
+
+	var secret string
+	var verified int
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sig := r.Header.Get(webhook.SignatureHeader)
+		ts, _ := strconv.ParseInt(strings.TrimPrefix(strings.Split(sig, ",")[0], "t="), 10, 64)
+		if sig != webhook.Sign(secret, ts, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		verified++
+	}))
+	defer ok.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	t.Log("Given the need to deliver shorturl events to subscribers.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen delivering an event.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 1, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			ns := webhook.NewSubscription{URL: ok.URL, Events: []string{webhook.EventLinkCreated}}
+			sub, err := wh.Create(ctx, traceID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a subscription : %s.", tests.Failed, testID, err)
+			}
+			secret = sub.Secret
+			t.Logf("\t%s\tTest %d:\tShould be able to create a subscription.", tests.Success, testID)
+
+			ns = webhook.NewSubscription{URL: broken.URL, Events: []string{webhook.EventLinkCreated}}
+			failing, err := wh.Create(ctx, traceID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a subscription : %s.", tests.Failed, testID, err)
+			}
+
+			ev := webhook.Event{
+				Type: webhook.EventLinkCreated,
+				Data: webhook.LinkData{ID: 1, Code: "1", URL: "https://example.com"},
+			}
+			if err := wh.Enqueue(ctx, traceID, db, ev, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enqueue an event : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to enqueue an event.", tests.Success, testID)
+
+			// The event is fanned out first, then each delivery is sent.
+			var n int
+			for {
+				ran, err := runner.RunNext(ctx, traceID, now)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to run jobs : %s.", tests.Failed, testID, err)
+				}
+				if !ran {
+					break
+				}
+				n++
+			}
+			if n != 3 || verified != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould deliver a signed event to each subscriber : ran %d, verified %d.", tests.Failed, testID, n, verified)
+			}
+			t.Logf("\t%s\tTest %d:\tShould deliver a signed event to each subscriber.", tests.Success, testID)
+
+			deliveries, err := wh.QueryDeliveries(ctx, traceID, sub.ID, 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query deliveries : %s.", tests.Failed, testID, err)
+			}
+			if len(deliveries) != 1 || deliveries[0].Status != webhook.StatusDelivered || deliveries[0].ResponseCode != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould log the delivery : %+v.", tests.Failed, testID, deliveries)
+			}
+			t.Logf("\t%s\tTest %d:\tShould log the delivery.", tests.Success, testID)
+
+			deliveries, err = wh.QueryDeliveries(ctx, traceID, failing.ID, 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query deliveries : %s.", tests.Failed, testID, err)
+			}
+			d := deliveries[0]
+			if d.Status != webhook.StatusPending || d.Attempts != 1 || !d.NextAttempt.Equal(now.Add(jobs.Backoff(1))) {
+				t.Fatalf("\t%s\tTest %d:\tShould retry the failed delivery later : %+v.", tests.Failed, testID, d)
+			}
+			t.Logf("\t%s\tTest %d:\tShould retry the failed delivery later.", tests.Success, testID)
+
+			if ran, err := runner.RunNext(ctx, traceID, now.Add(time.Second)); err != nil || ran {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT retry before the backoff : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT retry before the backoff.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a shorturl passes a visit milestone.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 1, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			ns := webhook.NewSubscription{URL: ok.URL, Events: []string{webhook.EventVisitMilestone}, MilestoneEvery: 2}
+			sub, err := wh.Create(ctx, traceID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a subscription : %s.", tests.Failed, testID, err)
+			}
+
+			surl, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://example.com"}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+			for i := 0; i < 3; i++ {
+				if _, err := su.QueryByID(ctx, traceID, surl.ID, shorturl.NewVisit{}, now); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to visit shorturl : %s.", tests.Failed, testID, err)
+				}
+			}
+
+			for i := 0; i < 2; i++ {
+				if err := wh.CheckMilestones(ctx, traceID, now); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to check milestones : %s.", tests.Failed, testID, err)
+				}
+			}
+
+			deliveries, err := wh.QueryDeliveries(ctx, traceID, sub.ID, 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query deliveries : %s.", tests.Failed, testID, err)
+			}
+			if len(deliveries) != 1 || !strings.Contains(string(deliveries[0].Payload), `"milestone": 2`) {
+				t.Fatalf("\t%s\tTest %d:\tShould enqueue the milestone once : %+v.", tests.Failed, testID, deliveries)
+			}
+			t.Logf("\t%s\tTest %d:\tShould enqueue the milestone once.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the activation window of a shorturl ends.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 1, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			ns := webhook.NewSubscription{URL: ok.URL, Events: []string{webhook.EventLinkExpired}}
+			sub, err := wh.Create(ctx, traceID, ns, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a subscription : %s.", tests.Failed, testID, err)
+			}
+
+			until := now.Add(time.Hour)
+			surl, err := su.Create(ctx, traceID, shorturl.NewShorturl{URL: "https://example.com", ActiveUntil: &until}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create shorturl : %s.", tests.Failed, testID, err)
+			}
+
+			expire := func(now time.Time) int {
+				if err := wh.CheckExpirations(ctx, traceID, now); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to check expirations : %s.", tests.Failed, testID, err)
+				}
+				for {
+					ran, err := runner.RunNext(ctx, traceID, now)
+					if err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould be able to run jobs : %s.", tests.Failed, testID, err)
+					}
+					if !ran {
+						break
+					}
+				}
+				deliveries, err := wh.QueryDeliveries(ctx, traceID, sub.ID, 0, 10)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to query deliveries : %s.", tests.Failed, testID, err)
+				}
+				return len(deliveries)
+			}
+
+			if n := expire(now); n != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT publish an active shorturl : %d.", tests.Failed, testID, n)
+			}
+			if n := expire(now.Add(2 * time.Hour)); n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould publish the expiration : %d.", tests.Failed, testID, n)
+			}
+			if n := expire(now.Add(2 * time.Hour)); n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould publish the expiration once : %d.", tests.Failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish the expiration once.", tests.Success, testID)
+
+			until = now.Add(3 * time.Hour)
+			if err := su.Update(ctx, traceID, auth.Claims{}, surl.ID, shorturl.UpdateShorturl{ActiveUntil: &until}, now.Add(2*time.Hour)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to extend the window : %s.", tests.Failed, testID, err)
+			}
+			if n := expire(now.Add(4 * time.Hour)); n != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould publish the end of the extended window : %d.", tests.Failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish the end of the extended window.", tests.Success, testID)
+		}
+	}
+}
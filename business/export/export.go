@@ -0,0 +1,109 @@
+// Package export streams records as CSV or JSON Lines without holding the
+// whole export in memory.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownFormat is used when an export is requested in a format that is
+// not supported.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Set of supported export formats.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// flushEvery is the number of records written between flushes so clients
+// receive the export as it is produced.
+const flushEvery = 500
+
+// Record is a single exported row. It is encoded with encoding/json for JSON
+// Lines and through Fields for CSV.
+type Record interface {
+	Fields() []string
+}
+
+// Writer encodes records in one of the supported formats.
+type Writer struct {
+	w       io.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	written int
+}
+
+// NewWriter constructs a writer for the format. The header is written right
+// away for CSV and ignored for JSON Lines.
+func NewWriter(w io.Writer, format string, header []string) (*Writer, error) {
+	ew := Writer{
+		w: w,
+	}
+
+	switch format {
+	case FormatCSV:
+		ew.csv = csv.NewWriter(w)
+		if err := ew.csv.Write(header); err != nil {
+			return nil, errors.Wrap(err, "writing header")
+		}
+	case FormatNDJSON:
+		ew.json = json.NewEncoder(w)
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	return &ew, nil
+}
+
+// ContentType returns the media type of the format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/octet-stream"
+}
+
+// Write encodes the record and periodically flushes it to the client.
+func (ew *Writer) Write(r Record) error {
+	if ew.csv != nil {
+		if err := ew.csv.Write(r.Fields()); err != nil {
+			return errors.Wrap(err, "writing csv record")
+		}
+	} else {
+		if err := ew.json.Encode(r); err != nil {
+			return errors.Wrap(err, "writing json record")
+		}
+	}
+
+	ew.written++
+	if ew.written%flushEvery == 0 {
+		return ew.Flush()
+	}
+
+	return nil
+}
+
+// Flush sends everything written so far to the underlying writer and, for
+// HTTP responses, on to the client.
+func (ew *Writer) Flush() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		if err := ew.csv.Error(); err != nil {
+			return errors.Wrap(err, "flushing csv")
+		}
+	}
+
+	if f, ok := ew.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package export_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/mitrovicsinisaa/shorturl/business/export"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+type row struct {
+	Name   string `json:"name"`
+	Visits int    `json:"visits"`
+}
+
+func (r row) Fields() []string {
+	return []string{r.Name, strconv.Itoa(r.Visits)}
+}
+
+func TestWriter(t *testing.T) {
+	tt := []struct {
+		format string
+		want   string
+	}{
+		{export.FormatCSV, "name,visits\n\"a,b\",1\nc,2\n"},
+		{export.FormatNDJSON, "{\"name\":\"a,b\",\"visits\":1}\n{\"name\":\"c\",\"visits\":2}\n"},
+	}
+
+	t.Log("Given the need to stream records in several formats.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen writing %s.", testID, tst.format)
+			{
+				var buf bytes.Buffer
+				ew, err := export.NewWriter(&buf, tst.format, []string{"name", "visits"})
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to construct a writer : %s.", failed, testID, err)
+				}
+				for _, r := range []row{{"a,b", 1}, {"c", 2}} {
+					if err := ew.Write(r); err != nil {
+						t.Fatalf("\t%s\tTest %d:\tShould be able to write a record : %s.", failed, testID, err)
+					}
+				}
+				if err := ew.Flush(); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to flush : %s.", failed, testID, err)
+				}
+				if got := buf.String(); got != tst.want {
+					t.Fatalf("\t%s\tTest %d:\tShould get %q : got %q.", failed, testID, tst.want, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the encoded records.", success, testID)
+			}
+		}
+
+		testID := len(tt)
+		t.Logf("\tTest %d:\tWhen asking for an unknown format.", testID)
+		{
+			if _, err := export.NewWriter(&bytes.Buffer{}, "xml", nil); err != export.ErrUnknownFormat {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to construct a writer : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to construct a writer.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Package hll implements a HyperLogLog sketch for estimating the number of
+// distinct values in a stream without keeping the values themselves.
+package hll
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// Set of precision limits. A sketch with precision p uses 2^p one byte
+// registers and has a standard error of about 1.04/sqrt(2^p).
+const (
+	MinPrecision     = 4
+	MaxPrecision     = 16
+	DefaultPrecision = 12
+)
+
+var (
+	// ErrInvalidPrecision occurs when a precision is out of range.
+	ErrInvalidPrecision = errors.New("precision out of range")
+
+	// ErrPrecisionMismatch occurs when sketches of different precision are merged.
+	ErrPrecisionMismatch = errors.New("sketches have different precision")
+
+	// ErrInvalidSketch occurs when serialized data is not a valid sketch.
+	ErrInvalidSketch = errors.New("invalid sketch data")
+)
+
+// Sketch is a HyperLogLog cardinality estimator. It expects well distributed
+// 64 bit hashes of the values being counted.
+type Sketch struct {
+	p         uint8
+	registers []uint8
+}
+
+// New constructs an empty sketch with the specified precision.
+func New(precision uint8) (*Sketch, error) {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, ErrInvalidPrecision
+	}
+
+	s := Sketch{
+		p:         precision,
+		registers: make([]uint8, 1<<precision),
+	}
+
+	return &s, nil
+}
+
+// Add records the hash of a value.
+func (s *Sketch) Add(hash uint64) {
+	idx := hash >> (64 - s.p)
+
+	// The remaining bits are shifted to the top and a sentinel bit is set so
+	// the rank never exceeds 64-p+1.
+	w := hash<<s.p | 1<<(s.p-1)
+	rank := uint8(bits.LeadingZeros64(w)) + 1
+
+	if rank > s.registers[idx] {
+		s.registers[idx] = rank
+	}
+}
+
+// Merge folds the values of another sketch into this one. Afterwards the
+// sketch estimates the cardinality of the union of both streams.
+func (s *Sketch) Merge(other *Sketch) error {
+	if s.p != other.p {
+		return ErrPrecisionMismatch
+	}
+
+	for i, r := range other.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+
+	return nil
+}
+
+// Estimate returns the estimated number of distinct values added.
+func (s *Sketch) Estimate() uint64 {
+	m := float64(len(s.registers))
+
+	var sum float64
+	var zeros int
+	for _, r := range s.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	est := alpha(len(s.registers)) * m * m / sum
+
+	// Small cardinalities are better served by linear counting.
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(est + 0.5)
+}
+
+// MarshalBinary encodes the sketch as its precision followed by the
+// registers.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 1+len(s.registers))
+	data[0] = s.p
+	copy(data[1:], s.registers)
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes a sketch encoded by MarshalBinary.
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return ErrInvalidSketch
+	}
+
+	p := data[0]
+	if p < MinPrecision || p > MaxPrecision || len(data) != 1+1<<p {
+		return ErrInvalidSketch
+	}
+
+	s.p = p
+	s.registers = make([]uint8, 1<<p)
+	copy(s.registers, data[1:])
+
+	return nil
+}
+
+// alpha returns the bias correction constant for m registers.
+func alpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/float64(m))
+}
@@ -0,0 +1,92 @@
+package hll_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/mitrovicsinisaa/shorturl/business/hll"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// hash returns a well distributed hash for the i-th test value.
+func hash(i int) uint64 {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("visitor-%d", i)))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func TestSketch(t *testing.T) {
+	t.Log("Given the need to estimate distinct visitors.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen counting values with duplicates.", testID)
+		{
+			for _, n := range []int{10, 1000, 50000} {
+				s, err := hll.New(hll.DefaultPrecision)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create a sketch : %s.", failed, testID, err)
+				}
+
+				for i := 0; i < n; i++ {
+					s.Add(hash(i))
+					s.Add(hash(i))
+				}
+
+				got := float64(s.Estimate())
+				if relErr := math.Abs(got-float64(n)) / float64(n); relErr > 0.05 {
+					t.Fatalf("\t%s\tTest %d:\tShould estimate %d within 5%% : got %v.", failed, testID, n, got)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould estimate the cardinality within 5%%.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen merging serialized sketches.", testID)
+		{
+			a, _ := hll.New(hll.DefaultPrecision)
+			b, _ := hll.New(hll.DefaultPrecision)
+			for i := 0; i < 2000; i++ {
+				a.Add(hash(i))
+				b.Add(hash(i + 1000))
+			}
+
+			data, err := b.MarshalBinary()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to marshal a sketch : %s.", failed, testID, err)
+			}
+
+			var decoded hll.Sketch
+			if err := decoded.UnmarshalBinary(data); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal a sketch : %s.", failed, testID, err)
+			}
+
+			if err := a.Merge(&decoded); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to merge sketches : %s.", failed, testID, err)
+			}
+
+			got := float64(a.Estimate())
+			if relErr := math.Abs(got-3000) / 3000; relErr > 0.05 {
+				t.Fatalf("\t%s\tTest %d:\tShould estimate the union within 5%% : got %v.", failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould estimate the union within 5%%.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen merging sketches of different precision.", testID)
+		{
+			a, _ := hll.New(10)
+			b, _ := hll.New(12)
+			if err := a.Merge(b); err != hll.ErrPrecisionMismatch {
+				t.Fatalf("\t%s\tTest %d:\tShould fail with a precision mismatch : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail with a precision mismatch.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package jobs runs background work stored in Postgres. Jobs are enqueued in
+// the same transaction as the write they belong to, so they exist if and only
+// if the write committed. Workers claim due jobs with FOR UPDATE SKIP LOCKED,
+// retry failed jobs with exponential backoff and move jobs that ran out of
+// attempts to the dead letter status for inspection.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is used when a specific job is requested but does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Set of job states. Jobs that succeed are removed.
+const (
+	StatusPending = "pending"
+	StatusDead    = "dead"
+)
+
+// Set of parameters of jobs.
+const (
+
+	// defaultMaxAttempts is the number of attempts after which a job is
+	// dead lettered when it does not set its own.
+	defaultMaxAttempts = 8
+
+	// backoffBase is the delay before the first retry. It doubles with every
+	// further attempt up to backoffMax.
+	backoffBase = 30 * time.Second
+	backoffMax  = 6 * time.Hour
+
+	// maxErrorLength bounds the error stored for failed attempts.
+	maxErrorLength = 512
+)
+
+// Jobs manages the set of API's for job access.
+type Jobs struct {
+	log *log.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Jobs for api access.
+func New(log *log.Logger, db *sqlx.DB) Jobs {
+	return Jobs{
+		log: log,
+		db:  db,
+	}
+}
+
+// Enqueue stores a job using the transaction of the write it belongs to. The
+// job only becomes visible to workers once that transaction commits.
+func (j Jobs) Enqueue(ctx context.Context, traceID string, tx sqlx.ExtContext, nj NewJob, now time.Time) (int64, error) {
+	payload, err := json.Marshal(nj.Payload)
+	if err != nil {
+		return 0, errors.Wrapf(err, "encoding %s job", nj.Kind)
+	}
+
+	runAt := nj.RunAt
+	if runAt.IsZero() {
+		runAt = now
+	}
+	maxAttempts := nj.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	const q = `
+	INSERT INTO jobs
+		(kind, payload, status, max_attempts, run_at, date_created, date_updated)
+	VALUES
+		($1, $2::JSONB, $3, $4, $5, $6, $6)
+		RETURNING job_id`
+
+	args := []interface{}{nj.Kind, string(payload), StatusPending, maxAttempts, runAt.UTC(), now.UTC()}
+
+	j.log.Printf("%s : %s : query : %s", traceID, "jobs.Enqueue",
+		database.Log(q, args...))
+
+	var id int64
+	if err := sqlx.GetContext(ctx, tx, &id, q, args...); err != nil {
+		return 0, errors.Wrapf(err, "inserting %s job", nj.Kind)
+	}
+
+	return id, nil
+}
+
+// Query retrieves a page of the jobs in the specified status, the oldest
+// first.
+func (j Jobs) Query(ctx context.Context, traceID string, status string, pageNumber int, rowsPerPage int) ([]Job, error) {
+
+	const q = `
+	SELECT
+		*
+	FROM
+		jobs
+	WHERE
+		status = $1
+	ORDER BY
+		run_at, job_id
+	OFFSET $2 ROWS FETCH NEXT $3 ROWS ONLY`
+
+	j.log.Printf("%s : %s : query : %s", traceID, "jobs.Query",
+		database.Log(q, status, pageNumber, rowsPerPage))
+
+	jobs := []Job{}
+	if err := j.db.SelectContext(ctx, &jobs, q, status, pageNumber, rowsPerPage); err != nil {
+		return nil, errors.Wrapf(err, "selecting %s jobs", status)
+	}
+
+	return jobs, nil
+}
+
+// Retry puts a dead job back in the queue with a fresh set of attempts.
+func (j Jobs) Retry(ctx context.Context, traceID string, jobID int64, now time.Time) error {
+
+	const q = `
+	UPDATE
+		jobs
+	SET
+		"status" = $2,
+		"attempts" = 0,
+		"run_at" = $3,
+		"date_updated" = $3
+	WHERE
+		job_id = $1 AND status = $4`
+
+	j.log.Printf("%s : %s : query : %s", traceID, "jobs.Retry",
+		database.Log(q, jobID, StatusPending, now.UTC(), StatusDead))
+
+	res, err := j.db.ExecContext(ctx, q, jobID, StatusPending, now.UTC(), StatusDead)
+	if err != nil {
+		return errors.Wrapf(err, "retrying job %d", jobID)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "retrying job %d", jobID)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Backoff returns the delay before the next attempt of a job that failed the
+// specified number of times.
+func Backoff(attempts int) time.Duration {
+	d := backoffBase
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= backoffMax {
+			return backoffMax
+		}
+	}
+	return d
+}
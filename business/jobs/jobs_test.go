@@ -0,0 +1,154 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mitrovicsinisaa/shorturl/business/jobs"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+func TestJobs(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	j := jobs.New(log, db)
+	runner := jobs.NewRunner(log, db, jobs.Config{})
+
+	var ran []string
+	runner.Handle("ok", func(ctx context.Context, traceID string, tx *sqlx.Tx, job jobs.Job, now time.Time) error {
+		var payload struct{ Name string }
+		if err := job.Decode(&payload); err != nil {
+			return err
+		}
+		ran = append(ran, payload.Name)
+		return nil
+	})
+	runner.Handle("fail", func(ctx context.Context, traceID string, tx *sqlx.Tx, job jobs.Job, now time.Time) error {
+		if _, err := j.Enqueue(ctx, traceID, tx, jobs.NewJob{Kind: "ok", Payload: struct{ Name string }{"undone"}}, now); err != nil {
+			return err
+		}
+		return errors.New("failed on purpose")
+	})
+
+	t.Log("Given the need to run background jobs.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling jobs.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 1, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			tx, err := db.BeginTxx(ctx, nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to begin a transaction : %s.", tests.Failed, testID, err)
+			}
+			if _, err := j.Enqueue(ctx, traceID, tx, jobs.NewJob{Kind: "ok", Payload: struct{ Name string }{"rolled back"}}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enqueue a job : %s.", tests.Failed, testID, err)
+			}
+			tx.Rollback()
+
+			if _, err := j.Enqueue(ctx, traceID, db, jobs.NewJob{Kind: "ok", Payload: struct{ Name string }{"later"}, RunAt: now.Add(time.Hour)}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enqueue a job : %s.", tests.Failed, testID, err)
+			}
+			if _, err := j.Enqueue(ctx, traceID, db, jobs.NewJob{Kind: "ok", Payload: struct{ Name string }{"now"}}, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enqueue a job : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to enqueue jobs.", tests.Success, testID)
+
+			for {
+				ok, err := runner.RunNext(ctx, traceID, now)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to run jobs : %s.", tests.Failed, testID, err)
+				}
+				if !ok {
+					break
+				}
+			}
+			if len(ran) != 1 || ran[0] != "now" {
+				t.Fatalf("\t%s\tTest %d:\tShould only run committed jobs that are due : %v.", tests.Failed, testID, ran)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only run committed jobs that are due.", tests.Success, testID)
+
+			if _, err := runner.RunNext(ctx, traceID, now.Add(time.Hour)); err != nil || len(ran) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould run scheduled jobs at their time : %v : %v.", tests.Failed, testID, ran, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run scheduled jobs at their time.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a job keeps failing.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 2, 12, 0, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			id, err := j.Enqueue(ctx, traceID, db, jobs.NewJob{Kind: "fail", MaxAttempts: 2}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enqueue a job : %s.", tests.Failed, testID, err)
+			}
+
+			if ok, err := runner.RunNext(ctx, traceID, now); err != nil || !ok {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to run the job : %v.", tests.Failed, testID, err)
+			}
+			if ok, err := runner.RunNext(ctx, traceID, now); err != nil || ok {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT retry before the backoff : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould retry after the backoff.", tests.Success, testID)
+
+			if ok, err := runner.RunNext(ctx, traceID, now.Add(jobs.Backoff(1))); err != nil || !ok {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retry the job : %v.", tests.Failed, testID, err)
+			}
+
+			dead, err := j.Query(ctx, traceID, jobs.StatusDead, 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query dead jobs : %s.", tests.Failed, testID, err)
+			}
+			if len(dead) != 1 || dead[0].ID != id || dead[0].Attempts != 2 || dead[0].LastError != "failed on purpose" {
+				t.Fatalf("\t%s\tTest %d:\tShould dead letter the job once out of attempts : %+v.", tests.Failed, testID, dead)
+			}
+			t.Logf("\t%s\tTest %d:\tShould dead letter the job once out of attempts.", tests.Success, testID)
+
+			pending, err := j.Query(ctx, traceID, jobs.StatusPending, 0, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query pending jobs : %s.", tests.Failed, testID, err)
+			}
+			if len(pending) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould undo the writes of failed jobs : %+v.", tests.Failed, testID, pending)
+			}
+			t.Logf("\t%s\tTest %d:\tShould undo the writes of failed jobs.", tests.Success, testID)
+
+			if err := j.Retry(ctx, traceID, id, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retry a dead job : %s.", tests.Failed, testID, err)
+			}
+			if err := j.Retry(ctx, traceID, id, now); err != jobs.ErrNotFound {
+				t.Fatalf("\t%s\tTest %d:\tShould only retry dead jobs : %v.", tests.Failed, testID, err)
+			}
+			if ok, err := runner.RunNext(ctx, traceID, now); err != nil || !ok {
+				t.Fatalf("\t%s\tTest %d:\tShould run the retried job : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retry a dead job.", tests.Success, testID)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Log("Given the need to space out retries of failed jobs.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a job keeps failing.", testID)
+		{
+			if jobs.Backoff(2) != 2*jobs.Backoff(1) {
+				t.Fatalf("\t%s\tTest %d:\tShould double the delay : %v, %v.", tests.Failed, testID, jobs.Backoff(1), jobs.Backoff(2))
+			}
+			if jobs.Backoff(40) != jobs.Backoff(50) {
+				t.Fatalf("\t%s\tTest %d:\tShould cap the delay : %v.", tests.Failed, testID, jobs.Backoff(40))
+			}
+			t.Logf("\t%s\tTest %d:\tShould double the delay up to a cap.", tests.Success, testID)
+		}
+	}
+}
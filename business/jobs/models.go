@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job represents a unit of background work stored in the database.
+type Job struct {
+	ID          int64           `db:"job_id" json:"id"`
+	Kind        string          `db:"kind" json:"kind"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	Status      string          `db:"status" json:"status"`
+	Attempts    int             `db:"attempts" json:"attempts"`
+	MaxAttempts int             `db:"max_attempts" json:"max_attempts"`
+	RunAt       time.Time       `db:"run_at" json:"run_at"`
+	LastError   string          `db:"last_error" json:"last_error"`
+	DateCreated time.Time       `db:"date_created" json:"date_created"`
+	DateUpdated time.Time       `db:"date_updated" json:"date_updated"`
+}
+
+// Decode unmarshals the payload of the job into the value.
+func (j Job) Decode(val interface{}) error {
+	return json.Unmarshal(j.Payload, val)
+}
+
+// NewJob contains information needed to enqueue a job. Payload is encoded as
+// JSON. A zero RunAt runs the job as soon as possible and a zero MaxAttempts
+// uses the default.
+type NewJob struct {
+	Kind        string
+	Payload     interface{}
+	RunAt       time.Time
+	MaxAttempts int
+}
@@ -0,0 +1,239 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownKind is used when a job is claimed that no handler is registered
+// for. Such jobs are retried like failed ones, a newer version of the service
+// might know them.
+var ErrUnknownKind = errors.New("unknown job kind")
+
+// Handler processes a single job claimed at now. It runs inside the
+// transaction that claimed the job, so jobs it enqueues through tx are only
+// stored if it succeeds. Writes that must be kept even when the handler fails
+// belong outside tx.
+type Handler func(ctx context.Context, traceID string, tx *sqlx.Tx, job Job, now time.Time) error
+
+// Config configures the workers of a Runner.
+type Config struct {
+	Workers      int
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+// Runner claims due jobs and runs them with the handler registered for their
+// kind.
+type Runner struct {
+	log      *log.Logger
+	db       *sqlx.DB
+	cfg      Config
+	handlers map[string]Handler
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewRunner constructs a Runner. Handlers must be registered before Start.
+func NewRunner(log *log.Logger, db *sqlx.DB, cfg Config) *Runner {
+	return &Runner{
+		log:      log,
+		db:       db,
+		cfg:      cfg,
+		handlers: make(map[string]Handler),
+		shutdown: make(chan struct{}),
+	}
+}
+
+// Handle registers the handler for jobs of the specified kind.
+func (r *Runner) Handle(kind string, h Handler) {
+	r.handlers[kind] = h
+}
+
+// Start launches the workers. Each one runs due jobs until none are left and
+// then polls for new ones.
+func (r *Runner) Start() {
+	r.wg.Add(r.cfg.Workers)
+	for i := 0; i < r.cfg.Workers; i++ {
+		go r.work()
+	}
+}
+
+// Shutdown stops the workers from claiming new jobs and waits for the jobs
+// they are running to finish, or for the context to expire.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	close(r.shutdown)
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "draining workers")
+	}
+}
+
+// work is the loop of a single worker.
+func (r *Runner) work() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			select {
+			case <-r.shutdown:
+				return
+			default:
+			}
+
+			traceID := uuid.New().String()
+			ran, err := r.RunNext(context.Background(), traceID, time.Now())
+			if err != nil {
+				r.log.Printf("jobs: %s : ERROR : %v", traceID, err)
+				break
+			}
+			if !ran {
+				break
+			}
+		}
+
+		select {
+		case <-r.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunNext claims the oldest due job and runs it. It reports whether a job was
+// claimed. A failed job is retried after a backoff or, once it runs out of
+// attempts, dead lettered. A job that succeeds is removed.
+func (r *Runner) RunNext(ctx context.Context, traceID string, now time.Time) (bool, error) {
+	now = now.UTC()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	SELECT
+		*
+	FROM
+		jobs
+	WHERE
+		status = $1 AND run_at <= $2
+	ORDER BY
+		run_at, job_id
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED`
+
+	r.log.Printf("%s : %s : query : %s", traceID, "jobs.RunNext",
+		database.Log(q, StatusPending, now))
+
+	var job Job
+	if err := tx.GetContext(ctx, &job, q, StatusPending, now); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "claiming job")
+	}
+
+	// The savepoint lets a failed handler undo its own writes while the
+	// claim on the job is kept to record the failure.
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT job"); err != nil {
+		return false, errors.Wrap(err, "creating savepoint")
+	}
+
+	runErr := r.run(ctx, traceID, tx, job, now)
+
+	if runErr == nil {
+		const qd = `
+		DELETE FROM
+			jobs
+		WHERE
+			job_id = $1`
+
+		r.log.Printf("%s : %s : query : %s", traceID, "jobs.RunNext",
+			database.Log(qd, job.ID))
+
+		if _, err := tx.ExecContext(ctx, qd, job.ID); err != nil {
+			return false, errors.Wrapf(err, "completing job %d", job.ID)
+		}
+	} else {
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT job"); err != nil {
+			return false, errors.Wrap(err, "rolling back to savepoint")
+		}
+
+		attempts := job.Attempts + 1
+		status := StatusPending
+		runAt := now.Add(Backoff(attempts))
+		if attempts >= job.MaxAttempts {
+			status = StatusDead
+			runAt = job.RunAt
+		}
+		msg := runErr.Error()
+		if len(msg) > maxErrorLength {
+			msg = msg[:maxErrorLength]
+		}
+
+		r.log.Printf("jobs: %s : ERROR : %s job %d attempt %d : %v", traceID, job.Kind, job.ID, attempts, runErr)
+
+		const qu = `
+		UPDATE
+			jobs
+		SET
+			"status" = $2,
+			"attempts" = $3,
+			"run_at" = $4,
+			"last_error" = $5,
+			"date_updated" = $6
+		WHERE
+			job_id = $1`
+
+		r.log.Printf("%s : %s : query : %s", traceID, "jobs.RunNext",
+			database.Log(qu, job.ID, status, attempts, runAt, msg, now))
+
+		if _, err := tx.ExecContext(ctx, qu, job.ID, status, attempts, runAt, msg, now); err != nil {
+			return false, errors.Wrapf(err, "failing job %d", job.ID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrapf(err, "committing job %d", job.ID)
+	}
+
+	return true, nil
+}
+
+// run calls the handler of the job within the job timeout.
+func (r *Runner) run(ctx context.Context, traceID string, tx *sqlx.Tx, job Job, now time.Time) error {
+	h, ok := r.handlers[job.Kind]
+	if !ok {
+		return errors.Wrap(ErrUnknownKind, job.Kind)
+	}
+
+	if r.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.cfg.Timeout)
+		defer cancel()
+	}
+
+	return h(ctx, traceID, tx, job, now)
+}
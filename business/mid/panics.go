@@ -31,6 +31,13 @@ func Panics(log *log.Logger) web.Middleware {
 			// variable after the fact.
 			defer func() {
 				if r := recover(); r != nil {
+
+					// Handlers abort responses they can no longer finish,
+					// the server closes the connection.
+					if r == http.ErrAbortHandler {
+						panic(r)
+					}
+
 					err = errors.Errorf("panic: %v", r)
 
 					// Log to Go stack trace for this panic's gorutine.
@@ -0,0 +1,110 @@
+// Package pow implements a hashcash style proof of work that public
+// endpoints require instead of a captcha. A client is handed a challenge and
+// has to find a nonce whose SHA-256 hash together with the challenge starts
+// with a number of zero bits. Finding it takes the client a moment, checking
+// it takes a single hash.
+//
+// Challenges carry the time they were issued and expire. They are not signed,
+// a client making up its own challenges still pays for each of them, so the
+// caller must accept every challenge only once.
+package pow
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalid occurs when a challenge is malformed or the nonce does not
+	// solve it.
+	ErrInvalid = errors.New("invalid proof of work")
+
+	// ErrExpired occurs when a challenge was issued too long ago, or claims
+	// to be issued in the future.
+	ErrExpired = errors.New("proof of work challenge expired")
+)
+
+// maxNonceLength bounds the nonces that are hashed.
+const maxNonceLength = 32
+
+// clockSkew is how far in the future a challenge may claim to be issued.
+const clockSkew = time.Minute
+
+// NewChallenge issues a challenge at the specified time.
+func NewChallenge(now time.Time) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "reading random bytes")
+	}
+	return strconv.FormatInt(now.Unix(), 10) + "." + hex.EncodeToString(b), nil
+}
+
+// Verify checks that the nonce solves the challenge with at least difficulty
+// leading zero bits and that the challenge was issued within maxAge.
+func Verify(challenge string, nonce string, difficulty int, maxAge time.Duration, now time.Time) error {
+	issued, ok := parse(challenge)
+	if !ok || nonce == "" || len(nonce) > maxNonceLength {
+		return ErrInvalid
+	}
+
+	if now.Sub(issued) > maxAge || issued.Sub(now) > clockSkew {
+		return ErrExpired
+	}
+
+	if zeroBits(challenge, nonce) < difficulty {
+		return ErrInvalid
+	}
+
+	return nil
+}
+
+// Solve finds a nonce for the challenge. It is what clients do and is meant
+// for tests and tools.
+func Solve(challenge string, difficulty int) string {
+	for n := 0; ; n++ {
+		nonce := strconv.Itoa(n)
+		if zeroBits(challenge, nonce) >= difficulty {
+			return nonce
+		}
+	}
+}
+
+// parse validates a challenge and returns the time it was issued.
+func parse(challenge string) (time.Time, bool) {
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 2 || len(parts[1]) != 32 {
+		return time.Time{}, false
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return time.Time{}, false
+	}
+
+	unix, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(unix, 0), true
+}
+
+// zeroBits counts the leading zero bits of the hash of the challenge and
+// nonce.
+func zeroBits(challenge string, nonce string) int {
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+
+	var n int
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+package pow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/pow"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+func TestPow(t *testing.T) {
+	now := time.Date(2021, time.July, 15, 12, 0, 0, 0, time.UTC)
+	const difficulty = 12
+
+	t.Log("Given the need to require a proof of work.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen solving a challenge.", testID)
+		{
+			challenge, err := pow.NewChallenge(now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to issue a challenge : %s.", tests.Failed, testID, err)
+			}
+			nonce := pow.Solve(challenge, difficulty)
+
+			if err := pow.Verify(challenge, nonce, difficulty, time.Minute, now.Add(30*time.Second)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the solution : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept the solution.", tests.Success, testID)
+
+			if err := pow.Verify(challenge, nonce, 64, time.Minute, now); err != pow.ErrInvalid {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a solution that is too easy : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a solution that is too easy.", tests.Success, testID)
+
+			if err := pow.Verify(challenge, nonce, difficulty, time.Minute, now.Add(2*time.Minute)); err != pow.ErrExpired {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an expired challenge : %v.", tests.Failed, testID, err)
+			}
+			if err := pow.Verify(challenge, nonce, difficulty, time.Minute, now.Add(-time.Hour)); err != pow.ErrExpired {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a challenge from the future : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject expired challenges.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen handling malformed input.", testID)
+		{
+			malformed := []struct {
+				challenge string
+				nonce     string
+			}{
+				{"", "1"},
+				{"abc", "1"},
+				{"1626350400.xyz", "1"},
+				{"1626350400.00000000000000000000000000000000", ""},
+				{"1626350400.00000000000000000000000000000000", "012345678901234567890123456789012"},
+			}
+			for _, m := range malformed {
+				if err := pow.Verify(m.challenge, m.nonce, 0, time.Hour, now); err != pow.ErrInvalid {
+					t.Fatalf("\t%s\tTest %d:\tShould reject %q with nonce %q : %v.", tests.Failed, testID, m.challenge, m.nonce, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject malformed input.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package proxy finds the address of the client a request came from when the
+// service runs behind reverse proxies. X-Forwarded-For is only believed when
+// the request was sent by a trusted proxy, since anyone can send the header.
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Trusted is the set of networks whose proxies are trusted to report the
+// address of the client.
+type Trusted []*net.IPNet
+
+// New constructs the set of trusted proxies from CIDR ranges or single
+// addresses.
+func New(proxies []string) (Trusted, error) {
+	var t Trusted
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, errors.Errorf("invalid proxy address %q", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			t = append(t, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing proxy range %q", p)
+		}
+		t = append(t, n)
+	}
+
+	return t, nil
+}
+
+// ClientIP returns the address of the client of the request. Requests sent
+// by a trusted proxy are traced back through X-Forwarded-For, from the right,
+// to the first hop that is not a trusted proxy. The hops left of it could be
+// made up by the client and are ignored.
+func (t Trusted) ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !t.contains(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if net.ParseIP(hop) == nil {
+			return ip
+		}
+		ip = hop
+		if !t.contains(ip) {
+			return ip
+		}
+	}
+
+	return ip
+}
+
+// contains reports whether the address belongs to a trusted proxy.
+func (t Trusted) contains(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range t {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
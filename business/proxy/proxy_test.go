@@ -0,0 +1,61 @@
+package proxy_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mitrovicsinisaa/shorturl/business/proxy"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := proxy.New([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("Should be able to parse the trusted proxies : %s.", err)
+	}
+
+	tt := []struct {
+		name   string
+		remote string
+		fwd    string
+		ip     string
+	}{
+		{"direct", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"direct with a forged header", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"behind a proxy", "10.0.0.2:5000", "198.51.100.1", "198.51.100.1"},
+		{"behind a proxy with a forged hop", "10.0.0.2:5000", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"behind a chain of proxies", "10.0.0.2:5000", "198.51.100.1, 192.168.1.1, 10.0.0.3", "198.51.100.1"},
+		{"behind a proxy with a garbled hop", "10.0.0.2:5000", "198.51.100.1, nonsense", "10.0.0.2"},
+	}
+
+	t.Log("Given the need to find the address of the client.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen the request comes %s.", testID, tst.name)
+			{
+				r := httptest.NewRequest("GET", "/", nil)
+				r.RemoteAddr = tst.remote
+				if tst.fwd != "" {
+					r.Header.Set("X-Forwarded-For", tst.fwd)
+				}
+
+				if ip := trusted.ClientIP(r); ip != tst.ip {
+					t.Fatalf("\t%s\tTest %d:\tShould get %s : %s.", tests.Failed, testID, tst.ip, ip)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get %s.", tests.Success, testID, tst.ip)
+			}
+		}
+	}
+
+	t.Log("Given the need to configure trusted proxies.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a proxy is not an address.", testID)
+		{
+			if _, err := proxy.New([]string{"proxy.internal"}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the proxy.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the proxy.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package reputation
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Limits of the host suffixes and path prefixes looked up per URL, as in the
+// Safe Browsing lookup rules.
+const (
+	maxHostSuffixes = 5
+	maxPathPrefixes = 6
+)
+
+// Expressions returns the host suffix and path prefix combinations a URL is
+// looked up by, the most specific first. For
+// https://a.b.example/1/2.html?x=1 they are:
+//
+//	a.b.example/1/2.html?x=1
+//	a.b.example/1/2.html
+//	a.b.example/
+//	a.b.example/1/
+//	b.example/1/2.html?x=1
+//	b.example/1/2.html
+//	b.example/
+//	b.example/1/
+//
+// Hosts are lowercased and stripped of their port, IP addresses are not split
+// into suffixes. URLs without a host have no expressions.
+func Expressions(rawURL string) ([]string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing url")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return nil, nil
+	}
+
+	var exprs []string
+	for _, h := range hostSuffixes(host) {
+		for _, p := range pathPrefixes(u) {
+			exprs = append(exprs, h+p)
+		}
+	}
+
+	return exprs, nil
+}
+
+// hostSuffixes returns the host and up to four of its parent domains,
+// leaving out the top level domain.
+func hostSuffixes(host string) []string {
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+
+	suffixes := []string{host}
+	labels := strings.Split(host, ".")
+	start := len(labels) - maxHostSuffixes
+	if start < 1 {
+		start = 1
+	}
+	for i := start; i < len(labels)-1; i++ {
+		suffixes = append(suffixes, strings.Join(labels[i:], "."))
+	}
+
+	return suffixes
+}
+
+// pathPrefixes returns the path with and without its query, the root and up
+// to three leading directories.
+func pathPrefixes(u *url.URL) []string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	var prefixes []string
+	if u.RawQuery != "" {
+		prefixes = append(prefixes, path+"?"+u.RawQuery)
+	}
+	prefixes = append(prefixes, path)
+
+	dir := "/"
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; len(prefixes) < maxPathPrefixes; i++ {
+		if dir != path {
+			prefixes = append(prefixes, dir)
+		}
+		if i >= len(segments)-1 {
+			break
+		}
+		dir += segments[i] + "/"
+	}
+
+	return prefixes
+}
@@ -0,0 +1,107 @@
+package reputation
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// list is a list file together with the checker last loaded from it.
+type list struct {
+	path    string
+	load    func(path string) (Checker, error)
+	modTime time.Time
+	checker Checker
+}
+
+// Lists screens URLs against the lists in a set of files. The files are
+// reloaded by Reload when they change, so lists can be updated without a
+// restart.
+type Lists struct {
+	mu    sync.RWMutex
+	lists []*list
+}
+
+// LoadLists reads the blocklist and hash prefix files. An empty path leaves
+// that list out.
+func LoadLists(blocklistFile string, hashPrefixFile string) (*Lists, error) {
+	var l Lists
+	if blocklistFile != "" {
+		l.lists = append(l.lists, &list{
+			path: blocklistFile,
+			load: func(path string) (Checker, error) { return LoadBlocklist(path) },
+		})
+	}
+	if hashPrefixFile != "" {
+		l.lists = append(l.lists, &list{
+			path: hashPrefixFile,
+			load: func(path string) (Checker, error) { return LoadHashPrefixes(path) },
+		})
+	}
+
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// Len returns the number of lists screened against.
+func (l *Lists) Len() int {
+	return len(l.lists)
+}
+
+// Reload reads the files modified since they were last loaded. A file that
+// can't be read keeps its previous list and the error is returned once the
+// other files were reloaded.
+func (l *Lists) Reload() error {
+	var firstErr error
+	for _, ls := range l.lists {
+		fi, err := os.Stat(ls.path)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrap(err, "checking list")
+			}
+			continue
+		}
+
+		l.mu.RLock()
+		changed := ls.checker == nil || !fi.ModTime().Equal(ls.modTime)
+		l.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		checker, err := ls.load(ls.path)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "loading %s", ls.path)
+			}
+			continue
+		}
+
+		l.mu.Lock()
+		ls.modTime = fi.ModTime()
+		ls.checker = checker
+		l.mu.Unlock()
+	}
+
+	return firstErr
+}
+
+// Check implements the Checker interface.
+func (l *Lists) Check(ctx context.Context, url string) (string, error) {
+	l.mu.RLock()
+	c := make(Composite, 0, len(l.lists))
+	for _, ls := range l.lists {
+		if ls.checker != nil {
+			c = append(c, ls.checker)
+		}
+	}
+	l.mu.RUnlock()
+
+	return c.Check(ctx, url)
+}
@@ -0,0 +1,222 @@
+// Package reputation screens URLs against lists of known malicious
+// destinations. Lists are loaded from files so screening works offline: a
+// plain blocklist of hosts and path prefixes, and a list of hash prefixes in
+// the style of Safe Browsing. Every checker returns a description of the
+// threat a URL matched, or an empty string for clean URLs.
+package reputation
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Checker screens a URL. It returns a description of the threat the URL
+// matched, or an empty string when it matched none.
+type Checker interface {
+	Check(ctx context.Context, url string) (string, error)
+}
+
+// =============================================================================
+
+// Blocklist matches URLs against hosts and path prefixes. An entry without a
+// path blocks the host and all of its subdomains, an entry with a path
+// ending in a slash blocks everything below it and any other entry blocks
+// that exact URL.
+type Blocklist struct {
+	name    string
+	entries map[string]struct{}
+}
+
+// NewBlocklist constructs a Blocklist of the entries. The name identifies
+// the list in threats.
+func NewBlocklist(name string, entries []string) *Blocklist {
+	bl := Blocklist{
+		name:    name,
+		entries: make(map[string]struct{}, len(entries)),
+	}
+	for _, e := range entries {
+		bl.entries[normalize(e)] = struct{}{}
+	}
+	return &bl
+}
+
+// LoadBlocklist reads a blocklist file with an entry per line. Blank lines
+// and lines starting with # are skipped.
+func LoadBlocklist(path string) (*Blocklist, error) {
+	entries, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewBlocklist(filepath.Base(path), entries), nil
+}
+
+// Check implements the Checker interface.
+func (bl *Blocklist) Check(ctx context.Context, url string) (string, error) {
+	exprs, err := Expressions(url)
+	if err != nil {
+		return "", err
+	}
+
+	for _, expr := range exprs {
+		if _, ok := bl.entries[expr]; ok {
+			return bl.name + ": " + expr, nil
+		}
+	}
+
+	return "", nil
+}
+
+// normalize turns a blocklist entry into the expression it matches.
+func normalize(entry string) string {
+	entry = strings.TrimSpace(entry)
+	entry = strings.TrimPrefix(entry, "http://")
+	entry = strings.TrimPrefix(entry, "https://")
+
+	host, path := entry, "/"
+	if i := strings.Index(entry, "/"); i >= 0 {
+		host, path = entry[:i], entry[i:]
+	}
+
+	return strings.TrimSuffix(strings.ToLower(host), ".") + path
+}
+
+// =============================================================================
+
+// Set of lengths a hash prefix may have, in bytes.
+const (
+	minPrefixLength = 4
+	maxPrefixLength = sha256.Size
+)
+
+// HashPrefixes matches URLs by the SHA-256 hashes of their expressions
+// against a list of hash prefixes, as distributed by Safe Browsing style
+// feeds. Short prefixes keep the list small at the cost of the occasional
+// false positive, full hashes match exactly.
+type HashPrefixes struct {
+	name     string
+	lengths  []int
+	prefixes map[string]struct{}
+}
+
+// NewHashPrefixes constructs HashPrefixes of the hex encoded prefixes. The
+// name identifies the list in threats.
+func NewHashPrefixes(name string, prefixes []string) (*HashPrefixes, error) {
+	hp := HashPrefixes{
+		name:     name,
+		prefixes: make(map[string]struct{}, len(prefixes)),
+	}
+
+	seen := make(map[int]bool)
+	for _, p := range prefixes {
+		p = strings.ToLower(strings.TrimSpace(p))
+		b, err := hex.DecodeString(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decoding hash prefix %q", p)
+		}
+		if len(b) < minPrefixLength || len(b) > maxPrefixLength {
+			return nil, errors.Errorf("hash prefix %q must be %d to %d bytes", p, minPrefixLength, maxPrefixLength)
+		}
+
+		hp.prefixes[p] = struct{}{}
+		if !seen[len(b)] {
+			seen[len(b)] = true
+			hp.lengths = append(hp.lengths, len(b))
+		}
+	}
+
+	return &hp, nil
+}
+
+// LoadHashPrefixes reads a file with a hex encoded hash prefix per line.
+// Blank lines and lines starting with # are skipped.
+func LoadHashPrefixes(path string) (*HashPrefixes, error) {
+	prefixes, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewHashPrefixes(filepath.Base(path), prefixes)
+}
+
+// Check implements the Checker interface.
+func (hp *HashPrefixes) Check(ctx context.Context, url string) (string, error) {
+	exprs, err := Expressions(url)
+	if err != nil {
+		return "", err
+	}
+
+	for _, expr := range exprs {
+		sum := sha256.Sum256([]byte(expr))
+		for _, l := range hp.lengths {
+			if _, ok := hp.prefixes[hex.EncodeToString(sum[:l])]; ok {
+				return hp.name + ": " + expr, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// =============================================================================
+
+// Composite screens URLs with several checkers and reports the first threat
+// found. A checker failing doesn't stop the others, its error is only
+// returned when no other checker found a threat.
+type Composite []Checker
+
+// Check implements the Checker interface.
+func (c Composite) Check(ctx context.Context, url string) (string, error) {
+	var firstErr error
+	for _, checker := range c {
+		threat, err := checker.Check(ctx, url)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if threat != "" {
+			return threat, nil
+		}
+	}
+
+	return "", firstErr
+}
+
+// =============================================================================
+
+// readLines reads the entries of a list file.
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening list")
+	}
+	defer f.Close()
+
+	return scanLines(f)
+}
+
+// scanLines returns the lines of r that are not blank or comments.
+func scanLines(r io.Reader) ([]string, error) {
+	var lines []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading list")
+	}
+
+	return lines, nil
+}
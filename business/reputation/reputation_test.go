@@ -0,0 +1,219 @@
+package reputation_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/reputation"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+func TestExpressions(t *testing.T) {
+	t.Log("Given the need to look up URLs by host suffix and path prefix.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen building the expressions of a URL.", testID)
+		{
+			exprs, err := reputation.Expressions("https://A.b.example:8443/1/2.html?x=1")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to build expressions : %s.", tests.Failed, testID, err)
+			}
+
+			want := []string{
+				"a.b.example/1/2.html?x=1", "a.b.example/1/2.html", "a.b.example/", "a.b.example/1/",
+				"b.example/1/2.html?x=1", "b.example/1/2.html", "b.example/", "b.example/1/",
+			}
+			if len(exprs) != len(want) {
+				t.Fatalf("\t%s\tTest %d:\tShould get every host suffix and path prefix : %q.", tests.Failed, testID, exprs)
+			}
+			for i := range want {
+				if exprs[i] != want[i] {
+					t.Fatalf("\t%s\tTest %d:\tShould get %q at %d : %q.", tests.Failed, testID, want[i], i, exprs[i])
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould get every host suffix and path prefix.", tests.Success, testID)
+
+			exprs, err = reputation.Expressions("http://192.168.0.1")
+			if err != nil || len(exprs) != 1 || exprs[0] != "192.168.0.1/" {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT split an IP address : %q : %v.", tests.Failed, testID, exprs, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT split an IP address.", tests.Success, testID)
+		}
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	t.Log("Given the need to screen URLs against a blocklist.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen loading a blocklist file.", testID)
+		{
+			dir, err := ioutil.TempDir("", "reputation")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a directory : %s.", tests.Failed, testID, err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "blocklist.txt")
+			list := "# known phishing\nphishing.example\n\nhttps://files.example/malware/\n"
+			if err := ioutil.WriteFile(path, []byte(list), 0600); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to write the blocklist : %s.", tests.Failed, testID, err)
+			}
+
+			bl, err := reputation.LoadBlocklist(path)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to load the blocklist : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to load the blocklist.", tests.Success, testID)
+
+			cases := map[string]bool{
+				"https://phishing.example/login":       true,
+				"https://www.phishing.example/":        true,
+				"https://files.example/malware/x.exe":  true,
+				"https://files.example/docs/guide.pdf": false,
+				"https://example.com/phishing.example": false,
+			}
+			for url, blocked := range cases {
+				threat, err := bl.Check(context.Background(), url)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to check %q : %s.", tests.Failed, testID, url, err)
+				}
+				if (threat != "") != blocked {
+					t.Fatalf("\t%s\tTest %d:\tShould report %q blocked %v : %q.", tests.Failed, testID, url, blocked, threat)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould block hosts, their subdomains and path prefixes.", tests.Success, testID)
+		}
+	}
+}
+
+func TestHashPrefixes(t *testing.T) {
+	sum := sha256.Sum256([]byte("phishing.example/"))
+
+	t.Log("Given the need to screen URLs against a hash prefix list.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen checking URLs against hash prefixes.", testID)
+		{
+			hp, err := reputation.NewHashPrefixes("feed", []string{hex.EncodeToString(sum[:4])})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the list : %s.", tests.Failed, testID, err)
+			}
+
+			threat, err := hp.Check(context.Background(), "https://login.phishing.example/account")
+			if err != nil || threat != "feed: phishing.example/" {
+				t.Fatalf("\t%s\tTest %d:\tShould match the prefix of the host : %q : %v.", tests.Failed, testID, threat, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould match the prefix of the host.", tests.Success, testID)
+
+			threat, err = hp.Check(context.Background(), "https://example.com/")
+			if err != nil || threat != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT match other hosts : %q : %v.", tests.Failed, testID, threat, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT match other hosts.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen creating a list of invalid prefixes.", testID)
+		{
+			for _, p := range []string{"abc", "zzzzzzzz", hex.EncodeToString(sum[:3])} {
+				if _, err := reputation.NewHashPrefixes("feed", []string{p}); err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould reject the prefix %q.", tests.Failed, testID, p)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject invalid prefixes.", tests.Success, testID)
+		}
+	}
+}
+
+// failing is a checker that always fails.
+type failing struct{}
+
+func (failing) Check(ctx context.Context, url string) (string, error) {
+	return "", errors.New("list unavailable")
+}
+
+func TestComposite(t *testing.T) {
+	t.Log("Given the need to screen URLs against several lists.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen one of the lists fails.", testID)
+		{
+			c := reputation.Composite{failing{}, reputation.NewBlocklist("local", []string{"phishing.example"})}
+
+			threat, err := c.Check(context.Background(), "https://phishing.example/")
+			if err != nil || threat != "local: phishing.example/" {
+				t.Fatalf("\t%s\tTest %d:\tShould report the threat of the other list : %q : %v.", tests.Failed, testID, threat, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the threat of the other list.", tests.Success, testID)
+
+			if _, err := c.Check(context.Background(), "https://example.com/"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould report the failure when no list matched.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the failure when no list matched.", tests.Success, testID)
+		}
+	}
+}
+
+func TestLists(t *testing.T) {
+	t.Log("Given the need to update lists without a restart.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a list file changes.", testID)
+		{
+			dir, err := ioutil.TempDir("", "reputation")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a directory : %s.", tests.Failed, testID, err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "blocklist.txt")
+			if err := ioutil.WriteFile(path, []byte("phishing.example\n"), 0600); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to write the blocklist : %s.", tests.Failed, testID, err)
+			}
+
+			l, err := reputation.LoadLists(path, "")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to load the lists : %s.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to load the lists.", tests.Success, testID)
+
+			if err := ioutil.WriteFile(path, []byte("malware.example\n"), 0600); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to write the blocklist : %s.", tests.Failed, testID, err)
+			}
+			later := time.Now().Add(time.Minute)
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to touch the blocklist : %s.", tests.Failed, testID, err)
+			}
+
+			if err := l.Reload(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the lists : %s.", tests.Failed, testID, err)
+			}
+			if threat, _ := l.Check(context.Background(), "https://malware.example/"); threat == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould screen against the new entries.", tests.Failed, testID)
+			}
+			if threat, _ := l.Check(context.Background(), "https://phishing.example/"); threat != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould drop the removed entries : %q.", tests.Failed, testID, threat)
+			}
+			t.Logf("\t%s\tTest %d:\tShould screen against the reloaded list.", tests.Success, testID)
+
+			if err := os.Remove(path); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to remove the blocklist : %s.", tests.Failed, testID, err)
+			}
+			if err := l.Reload(); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould report the missing file.", tests.Failed, testID)
+			}
+			if threat, _ := l.Check(context.Background(), "https://malware.example/"); threat == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the previous list.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the previous list when a file can't be read.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule reports when a task is due next.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// Parse reads a schedule. It accepts the descriptors "@every <duration>",
+// "@hourly" and "@daily" and the five cron fields minute, hour, day of month,
+// month and day of week, each being "*", a value, a range, a list or a step
+// like "*/15". Schedules are evaluated in UTC.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	switch {
+	case strings.HasPrefix(spec, "@every "):
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %q", spec)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("parsing %q: interval must be at least a second", spec)
+		}
+		return every(d), nil
+	case spec == "@hourly":
+		spec = "0 * * * *"
+	case spec == "@daily":
+		spec = "0 0 * * *"
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("parsing %q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	var c cron
+	bounds := []struct {
+		set      *uint64
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 6},
+	}
+	for i, b := range bounds {
+		set, err := parseField(fields[i], b.min, b.max)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %q", spec)
+		}
+		*b.set = set
+	}
+	c.anyDom = fields[2] == "*"
+	c.anyDow = fields[4] == "*"
+
+	// Days like the 31st of February never come, such a schedule would
+	// otherwise have no next time at all.
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("parsing %q: schedule never matches", spec)
+	}
+
+	return c, nil
+}
+
+// every is a schedule that is due at each multiple of an interval.
+type every time.Duration
+
+// Next returns the first multiple of the interval after t. Aligning to
+// multiples lets every replica agree on the same slots.
+func (e every) Next(t time.Time) time.Time {
+	d := time.Duration(e)
+	return t.UTC().Truncate(d).Add(d)
+}
+
+// cron is a schedule of five cron fields kept as bit sets.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	anyDom, anyDow                bool
+}
+
+// Next returns the first minute after t matching the fields. Like cron, a
+// day matches either restricted day field when both are restricted.
+func (c cron) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+
+	// Every schedule matches within five years, the longest gap being a
+	// 29th of February that falls on a specific weekday.
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of t matches the day fields.
+func (c cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// parseField returns the bit set of the values of a comma separated field.
+func parseField(field string, min int, max int) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = v, v
+			if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
@@ -0,0 +1,57 @@
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/scheduler"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+func TestParse(t *testing.T) {
+	from := time.Date(2021, time.July, 1, 12, 34, 56, 0, time.UTC) // A Thursday.
+
+	tt := []struct {
+		spec string
+		next time.Time
+	}{
+		{"@every 5m", time.Date(2021, time.July, 1, 12, 35, 0, 0, time.UTC)},
+		{"@every 1h", time.Date(2021, time.July, 1, 13, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, time.July, 1, 13, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, time.July, 2, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, time.July, 1, 12, 45, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2021, time.July, 2, 2, 30, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2021, time.July, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2021, time.July, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 1,7 *", time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	t.Log("Given the need to parse task schedules.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen parsing %q.", testID, tst.spec)
+			{
+				s, err := scheduler.Parse(tst.spec)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to parse the schedule : %s.", tests.Failed, testID, err)
+				}
+				if next := s.Next(from); !next.Equal(tst.next) {
+					t.Fatalf("\t%s\tTest %d:\tShould be due at %v : got %v.", tests.Failed, testID, tst.next, next)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be due at %v.", tests.Success, testID, tst.next)
+			}
+		}
+
+		testID := len(tt)
+		t.Logf("\tTest %d:\tWhen parsing invalid schedules.", testID)
+		{
+			for _, spec := range []string{"", "@every", "@every 1ms", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "0 0 31 2 *", "0 0 30,31 2 *"} {
+				if _, err := scheduler.Parse(spec); err == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT be able to parse %q.", tests.Failed, testID, spec)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to parse invalid schedules.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+// Package scheduler runs periodic tasks once per scheduled slot across every
+// replica of the service. Each replica checks its tasks on an interval. The
+// replica that takes the Postgres advisory lock of a due task is its leader
+// for that slot and runs it, the others stand by. The next slot is stored
+// with the task, so when the leader dies any other replica picks the task up
+// once the lock is released with its session.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/mitrovicsinisaa/shorturl/foundation/database"
+	"github.com/pkg/errors"
+)
+
+// Set of outcomes of a run.
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// maxErrorLength bounds the error stored for failed runs.
+const maxErrorLength = 512
+
+// Func is the work of a task. It is called with the time the run was due.
+type Func func(ctx context.Context, traceID string, now time.Time) error
+
+// Config configures how often tasks are checked and how long a run may take.
+type Config struct {
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+// Status is what is known about the last run of a task, by any replica.
+type Status struct {
+	Name           string     `db:"name" json:"name"`
+	Schedule       string     `db:"schedule" json:"schedule"`
+	NextRun        time.Time  `db:"next_run" json:"next_run"`
+	LastRun        *time.Time `db:"last_run" json:"last_run,omitempty"`
+	LastDurationMS int64      `db:"last_duration_ms" json:"last_duration_ms"`
+	LastStatus     string     `db:"last_status" json:"last_status,omitempty"`
+	LastError      string     `db:"last_error" json:"last_error,omitempty"`
+	LastHost       string     `db:"last_host" json:"last_host,omitempty"`
+}
+
+// task is a registered task.
+type task struct {
+	name     string
+	spec     string
+	schedule Schedule
+	fn       Func
+	key      int64
+}
+
+// Scheduler checks registered tasks and runs the ones that are due.
+type Scheduler struct {
+	log      *log.Logger
+	db       *sqlx.DB
+	cfg      Config
+	host     string
+	tasks    []task
+	mu       sync.Mutex
+	status   map[string]Status
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New constructs a Scheduler. Tasks must be added before Start.
+func New(log *log.Logger, db *sqlx.DB, cfg Config) *Scheduler {
+	host, _ := os.Hostname()
+
+	return &Scheduler{
+		log:      log,
+		db:       db,
+		cfg:      cfg,
+		host:     host,
+		status:   make(map[string]Status),
+		shutdown: make(chan struct{}),
+	}
+}
+
+// Add registers a task to run on the schedule. Names identify tasks across
+// replicas and must be unique.
+func (s *Scheduler) Add(name string, spec string, fn Func) error {
+	for _, t := range s.tasks {
+		if t.name == name {
+			return fmt.Errorf("task %q already added", name)
+		}
+	}
+
+	schedule, err := Parse(spec)
+	if err != nil {
+		return errors.Wrapf(err, "adding task %q", name)
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte("scheduler:" + name))
+
+	s.tasks = append(s.tasks, task{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		fn:       fn,
+		key:      int64(h.Sum64()),
+	})
+
+	return nil
+}
+
+// Start launches a goroutine per task that checks it every poll interval.
+func (s *Scheduler) Start() {
+	s.wg.Add(len(s.tasks))
+	for _, t := range s.tasks {
+		go s.loop(t)
+	}
+}
+
+// Shutdown stops checking tasks and waits for running tasks to finish, or
+// for the context to expire.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	close(s.shutdown)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "draining tasks")
+	}
+}
+
+// Status returns what is known about every task, ordered by name. It is
+// meant to be published on the debug host.
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Status, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		st, ok := s.status[t.name]
+		if !ok {
+			st = Status{Name: t.name, Schedule: t.spec}
+		}
+		list = append(list, st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list
+}
+
+// RunDue checks every task once and runs the ones that are due and not held
+// by another replica. It returns the names of the tasks it ran.
+func (s *Scheduler) RunDue(ctx context.Context, traceID string, now time.Time) ([]string, error) {
+	var ran []string
+	for _, t := range s.tasks {
+		ok, err := s.check(ctx, traceID, t, now)
+		if err != nil {
+			return ran, err
+		}
+		if ok {
+			ran = append(ran, t.name)
+		}
+	}
+	return ran, nil
+}
+
+// loop checks a single task until shutdown.
+func (s *Scheduler) loop(t task) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case now := <-ticker.C:
+			traceID := uuid.New().String()
+			if _, err := s.check(context.Background(), traceID, t, now); err != nil {
+				s.log.Printf("scheduler: %s : ERROR : %s : %v", traceID, t.name, err)
+			}
+		}
+	}
+}
+
+// check runs the task if it is due and this replica takes its lock. It
+// reports whether the task ran here.
+func (s *Scheduler) check(ctx context.Context, traceID string, t task, now time.Time) (ran bool, err error) {
+	now = now.UTC()
+
+	// Advisory locks belong to the session, so the lock is taken, used and
+	// released on a single connection. A connection whose unlock failed is
+	// discarded so it can't go back to the pool holding the lock.
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "acquiring connection")
+	}
+	defer func() {
+		if err != nil {
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}()
+
+	const ql = `SELECT pg_try_advisory_lock($1)`
+
+	s.log.Printf("%s : %s : query : %s", traceID, "scheduler.check",
+		database.Log(ql, t.key))
+
+	var locked bool
+	if err := conn.GetContext(ctx, &locked, ql, t.key); err != nil {
+		return false, errors.Wrapf(err, "locking %s", t.name)
+	}
+
+	if !locked {
+
+		// Another replica is running the task, only refresh what is known
+		// about it.
+		st, err := s.queryStatus(ctx, traceID, conn, t)
+		if err != nil && err != sql.ErrNoRows {
+			return false, err
+		}
+		if err == nil {
+			s.setStatus(st)
+		}
+		return false, nil
+	}
+
+	defer func() {
+		const qu = `SELECT pg_advisory_unlock($1)`
+
+		s.log.Printf("%s : %s : query : %s", traceID, "scheduler.check",
+			database.Log(qu, t.key))
+
+		if _, uerr := conn.ExecContext(context.Background(), qu, t.key); uerr != nil && err == nil {
+			err = errors.Wrapf(uerr, "unlocking %s", t.name)
+		}
+	}()
+
+	st, err := s.queryStatus(ctx, traceID, conn, t)
+	switch {
+	case err == sql.ErrNoRows || (err == nil && st.Schedule != t.spec):
+
+		// The task is new or its schedule changed, it first runs at the
+		// next slot of the schedule.
+		st.Name = t.name
+		st.Schedule = t.spec
+		st.NextRun = t.schedule.Next(now)
+		if err := s.saveStatus(ctx, traceID, conn, st); err != nil {
+			return false, err
+		}
+		s.setStatus(st)
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	s.setStatus(st)
+
+	if now.Before(st.NextRun) {
+		return false, nil
+	}
+
+	runCtx := ctx
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	runErr := t.fn(runCtx, traceID, now)
+
+	st.LastRun = &now
+	st.LastDurationMS = time.Since(start).Milliseconds()
+	st.LastStatus = StatusOK
+	st.LastError = ""
+	st.LastHost = s.host
+	st.NextRun = t.schedule.Next(now)
+	if runErr != nil {
+		s.log.Printf("scheduler: %s : ERROR : %s : %v", traceID, t.name, runErr)
+
+		st.LastStatus = StatusFailed
+		st.LastError = runErr.Error()
+		if len(st.LastError) > maxErrorLength {
+			st.LastError = st.LastError[:maxErrorLength]
+		}
+	}
+
+	if err := s.saveStatus(ctx, traceID, conn, st); err != nil {
+		return true, err
+	}
+	s.setStatus(st)
+
+	return true, nil
+}
+
+// queryStatus retrieves the stored status of the task.
+func (s *Scheduler) queryStatus(ctx context.Context, traceID string, conn *sqlx.Conn, t task) (Status, error) {
+
+	const q = `
+	SELECT
+		*
+	FROM
+		scheduled_tasks
+	WHERE
+		name = $1`
+
+	s.log.Printf("%s : %s : query : %s", traceID, "scheduler.queryStatus",
+		database.Log(q, t.name))
+
+	var st Status
+	if err := conn.GetContext(ctx, &st, q, t.name); err != nil {
+		if err == sql.ErrNoRows {
+			return Status{}, err
+		}
+		return Status{}, errors.Wrapf(err, "selecting task %s", t.name)
+	}
+
+	return st, nil
+}
+
+// saveStatus stores the status of the task.
+func (s *Scheduler) saveStatus(ctx context.Context, traceID string, conn *sqlx.Conn, st Status) error {
+
+	const q = `
+	INSERT INTO scheduled_tasks
+		(name, schedule, next_run, last_run, last_duration_ms, last_status, last_error, last_host)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (name) DO UPDATE SET
+		schedule = EXCLUDED.schedule,
+		next_run = EXCLUDED.next_run,
+		last_run = EXCLUDED.last_run,
+		last_duration_ms = EXCLUDED.last_duration_ms,
+		last_status = EXCLUDED.last_status,
+		last_error = EXCLUDED.last_error,
+		last_host = EXCLUDED.last_host`
+
+	args := []interface{}{st.Name, st.Schedule, st.NextRun, st.LastRun, st.LastDurationMS, st.LastStatus, st.LastError, st.LastHost}
+
+	s.log.Printf("%s : %s : query : %s", traceID, "scheduler.saveStatus",
+		database.Log(q, args...))
+
+	if _, err := conn.ExecContext(ctx, q, args...); err != nil {
+		return errors.Wrapf(err, "saving task %s", st.Name)
+	}
+
+	return nil
+}
+
+// setStatus records the latest known status of a task.
+func (s *Scheduler) setStatus(st Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status[st.Name] = st
+}
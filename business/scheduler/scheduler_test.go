@@ -0,0 +1,95 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/scheduler"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+func TestScheduler(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	// Two schedulers on the same database stand for two replicas.
+	a := scheduler.New(log, db, scheduler.Config{})
+	b := scheduler.New(log, db, scheduler.Config{})
+
+	var runs int
+	var standby []string
+	fail := false
+	task := func(ctx context.Context, traceID string, now time.Time) error {
+		runs++
+
+		// While a replica runs the task the other one can't take it.
+		ran, err := b.RunDue(ctx, traceID, now)
+		if err != nil {
+			return err
+		}
+		standby = ran
+
+		if fail {
+			return errors.New("failed on purpose")
+		}
+		return nil
+	}
+	if err := a.Add("test", "@every 1h", task); err != nil {
+		t.Fatalf("Should be able to add a task : %s.", err)
+	}
+	if err := b.Add("test", "@every 1h", task); err != nil {
+		t.Fatalf("Should be able to add a task : %s.", err)
+	}
+
+	t.Log("Given the need to run a task once across replicas.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the task is due.", testID)
+		{
+			ctx := tests.Context()
+			now := time.Date(2021, time.July, 1, 12, 30, 0, 0, time.UTC)
+			traceID := "00000000-0000-0000-0000-000000000000"
+
+			if ran, err := a.RunDue(ctx, traceID, now); err != nil || len(ran) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould wait for the first slot : %v : %v.", tests.Failed, testID, ran, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould wait for the first slot.", tests.Success, testID)
+
+			slot := time.Date(2021, time.July, 1, 13, 0, 0, 0, time.UTC)
+			if ran, err := a.RunDue(ctx, traceID, slot); err != nil || len(ran) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould run the task in its slot : %v : %v.", tests.Failed, testID, ran, err)
+			}
+			if runs != 1 || len(standby) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT run the task on the other replica while it runs : %d runs.", tests.Failed, testID, runs)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run the task on a single replica.", tests.Success, testID)
+
+			if ran, err := b.RunDue(ctx, traceID, slot.Add(time.Minute)); err != nil || len(ran) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT run the task twice in a slot : %v : %v.", tests.Failed, testID, ran, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT run the task twice in a slot.", tests.Success, testID)
+
+			st := b.Status()
+			if len(st) != 1 || st[0].LastRun == nil || !st[0].LastRun.Equal(slot) || st[0].LastStatus != scheduler.StatusOK || !st[0].NextRun.Equal(slot.Add(time.Hour)) {
+				t.Fatalf("\t%s\tTest %d:\tShould know the last run on every replica : %+v.", tests.Failed, testID, st)
+			}
+			t.Logf("\t%s\tTest %d:\tShould know the last run on every replica.", tests.Success, testID)
+
+			fail = true
+			if ran, err := b.RunDue(ctx, traceID, slot.Add(time.Hour)); err != nil || len(ran) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould run the next slot on any replica : %v : %v.", tests.Failed, testID, ran, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run the next slot on any replica.", tests.Success, testID)
+			if _, err := a.RunDue(ctx, traceID, slot.Add(time.Hour+time.Minute)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to check the task : %s.", tests.Failed, testID, err)
+			}
+			st = a.Status()
+			if st[0].LastStatus != scheduler.StatusFailed || st[0].LastError != "failed on purpose" || !st[0].NextRun.Equal(slot.Add(2*time.Hour)) {
+				t.Fatalf("\t%s\tTest %d:\tShould record the failed run and move on : %+v.", tests.Failed, testID, st)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the failed run and move on.", tests.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package signed issues and verifies shorturl codes that carry an HMAC
+// signature and an optional expiry. A signed code is checked with nothing but
+// the signing keys, so forged and expired codes are rejected before the
+// database is involved.
+//
+// A signed code has four parts separated by dots:
+//
+//	<shorturl code>.<key id>.<expiry>.<signature>
+//
+// The expiry is a unix time in base 36, or 0 for codes that never expire. The
+// signature is the truncated HMAC-SHA256 of the first three parts.
+package signed
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/base62"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalid occurs when a code is malformed or its signature does not
+	// match.
+	ErrInvalid = errors.New("invalid signed code")
+
+	// ErrExpired occurs when a correctly signed code is past its expiry.
+	ErrExpired = errors.New("signed code expired")
+)
+
+// separator splits the parts of a signed code. It never occurs in plain
+// shorturl codes, but may in go link keywords.
+const separator = "."
+
+// signatureLength is the number of HMAC bytes kept in a code.
+const signatureLength = 12
+
+// minSecretLength is the shortest secret accepted for a key.
+const minSecretLength = 32
+
+// validKID restricts key IDs to characters that need no escaping in a path.
+var validKID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,16}$`)
+
+// Keys represents an in memory store of signing secrets by key ID.
+//
+// Keys should be rotated. Codes are signed with the active key only, while
+// codes signed with any key still in the store keep verifying. A key is
+// retired by removing it once the codes it signed are no longer needed.
+type Keys map[string][]byte
+
+// NewCode contains information needed to sign a shorturl code. A nil
+// ExpiresAt signs a code that never expires.
+type NewCode struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Signer signs and verifies shorturl codes.
+type Signer struct {
+	active string
+	keys   Keys
+}
+
+// New constructs a Signer that signs with the key identified by active.
+func New(active string, keys Keys) (*Signer, error) {
+	for kid, secret := range keys {
+		if !validKID.MatchString(kid) {
+			return nil, errors.Errorf("invalid key id %q", kid)
+		}
+		if len(secret) < minSecretLength {
+			return nil, errors.Errorf("secret of key %q is shorter than %d bytes", kid, minSecretLength)
+		}
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, errors.Errorf("active key %q not found", active)
+	}
+
+	return &Signer{
+		active: active,
+		keys:   keys,
+	}, nil
+}
+
+// IsSigned reports whether a code is in the signed format with one of the
+// keys of the signer, valid or not. Other codes with dots in them, like go
+// link keywords, are not signed codes.
+func (s *Signer) IsSigned(code string) bool {
+	parts := strings.Split(code, separator)
+	if len(parts) != 4 {
+		return false
+	}
+
+	_, ok := s.keys[parts[1]]
+	return ok
+}
+
+// Sign issues a signed code for the shorturl. A zero expires issues a code
+// that never expires.
+func (s *Signer) Sign(shorturlID int, expires time.Time) string {
+	exp := "0"
+	if !expires.IsZero() {
+		exp = strconv.FormatInt(expires.Unix(), 36)
+	}
+
+	payload := strings.Join([]string{base62.Encode(shorturlID), s.active, exp}, separator)
+	return payload + separator + sign(s.keys[s.active], payload)
+}
+
+// Verify checks the signature and expiry of a signed code and returns the
+// shorturl it was issued for and when the code expires, the zero time for
+// codes that never do.
+func (s *Signer) Verify(code string, now time.Time) (int, time.Time, error) {
+	parts := strings.Split(code, separator)
+	if len(parts) != 4 {
+		return 0, time.Time{}, ErrInvalid
+	}
+
+	secret, ok := s.keys[parts[1]]
+	if !ok {
+		return 0, time.Time{}, ErrInvalid
+	}
+
+	payload := strings.Join(parts[:3], separator)
+	if !hmac.Equal([]byte(parts[3]), []byte(sign(secret, payload))) {
+		return 0, time.Time{}, ErrInvalid
+	}
+
+	// The payload is only parsed once it is known to be ours.
+	exp, err := strconv.ParseInt(parts[2], 36, 64)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalid
+	}
+	var expires time.Time
+	if exp != 0 {
+		expires = time.Unix(exp, 0).UTC()
+		if !now.Before(expires) {
+			return 0, time.Time{}, ErrExpired
+		}
+	}
+
+	shorturlID, err := base62.Decode(parts[0])
+	if err != nil {
+		return 0, time.Time{}, ErrInvalid
+	}
+
+	return shorturlID, expires, nil
+}
+
+// sign computes the signature of the payload.
+func sign(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureLength])
+}
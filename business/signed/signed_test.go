@@ -0,0 +1,131 @@
+package signed_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitrovicsinisaa/shorturl/business/signed"
+	"github.com/mitrovicsinisaa/shorturl/business/tests"
+)
+
+func TestSigned(t *testing.T) {
+	now := time.Date(2021, time.July, 14, 12, 0, 0, 0, time.UTC)
+	keys := signed.Keys{
+		"k1": []byte("0123456789abcdef0123456789abcdef"),
+		"k2": []byte("fedcba9876543210fedcba9876543210"),
+	}
+
+	t.Log("Given the need to sign shorturl codes.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen verifying a signed code.", testID)
+		{
+			s, err := signed.New("k1", keys)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a signer : %s.", tests.Failed, testID, err)
+			}
+
+			code := s.Sign(42, now.Add(time.Hour))
+			if !s.IsSigned(code) {
+				t.Fatalf("\t%s\tTest %d:\tShould recognize the signed code %q.", tests.Failed, testID, code)
+			}
+			if s.IsSigned("team.docs") || s.IsSigned("a.k3.0.b") {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT take dotted keywords for signed codes.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould recognize signed codes only.", tests.Success, testID)
+
+			id, expires, err := s.Verify(code, now)
+			if err != nil || id != 42 || !expires.Equal(now.Add(time.Hour)) {
+				t.Fatalf("\t%s\tTest %d:\tShould verify the code : %d : %v : %v.", tests.Failed, testID, id, expires, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould verify the code.", tests.Success, testID)
+
+			if _, _, err := s.Verify(code, now.Add(time.Hour)); err != signed.ErrExpired {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the expired code : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the expired code.", tests.Success, testID)
+
+			if _, _, err := s.Verify(s.Sign(42, time.Time{}), now.Add(24*365*time.Hour)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould verify a code without expiry : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould verify a code without expiry.", tests.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen verifying a tampered code.", testID)
+		{
+			s, err := signed.New("k1", keys)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a signer : %s.", tests.Failed, testID, err)
+			}
+
+			code := s.Sign(42, now.Add(time.Hour))
+			parts := strings.Split(code, ".")
+			other := strings.Split(s.Sign(43, now.Add(time.Hour)), ".")
+
+			tampered := []string{
+				other[0] + "." + strings.Join(parts[1:], "."),
+				strings.Join(parts[:2], ".") + ".0." + parts[3],
+				parts[0] + ".k2." + strings.Join(parts[2:], "."),
+				parts[0] + ".k3." + strings.Join(parts[2:], "."),
+				strings.Join(parts[:3], "."),
+				"abc",
+			}
+			for _, code := range tampered {
+				if _, _, err := s.Verify(code, now); err != signed.ErrInvalid {
+					t.Fatalf("\t%s\tTest %d:\tShould reject %q : %v.", tests.Failed, testID, code, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject tampered codes.", tests.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen rotating the signing key.", testID)
+		{
+			old, err := signed.New("k1", keys)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a signer : %s.", tests.Failed, testID, err)
+			}
+			code := old.Sign(42, time.Time{})
+
+			s, err := signed.New("k2", keys)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a signer : %s.", tests.Failed, testID, err)
+			}
+			if !strings.Contains(s.Sign(42, time.Time{}), ".k2.") {
+				t.Fatalf("\t%s\tTest %d:\tShould sign with the active key.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould sign with the active key.", tests.Success, testID)
+
+			if _, _, err := s.Verify(code, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould verify codes of older keys : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould verify codes of older keys.", tests.Success, testID)
+
+			retired, err := signed.New("k2", signed.Keys{"k2": keys["k2"]})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a signer : %s.", tests.Failed, testID, err)
+			}
+			if _, _, err := retired.Verify(code, now); err != signed.ErrInvalid {
+				t.Fatalf("\t%s\tTest %d:\tShould reject codes of retired keys : %v.", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject codes of retired keys.", tests.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen configuring the keys.", testID)
+		{
+			if _, err := signed.New("k9", keys); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould require the active key.", tests.Failed, testID)
+			}
+			if _, err := signed.New("k1", signed.Keys{"k1": []byte("short")}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould require long secrets.", tests.Failed, testID)
+			}
+			if _, err := signed.New("k.1", signed.Keys{"k.1": keys["k1"]}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould require valid key ids.", tests.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould validate the keys.", tests.Success, testID)
+		}
+	}
+}
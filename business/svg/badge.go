@@ -0,0 +1,78 @@
+package svg
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Set of layout parameters of badges.
+const (
+	badgeHeight    = 20
+	badgeCharWidth = 7
+	badgePadding   = 10
+	badgeLabelFill = "#555"
+)
+
+// badgeColors maps the named colors of badges to their value.
+var badgeColors = map[string]string{
+	"brightgreen": "#4c1",
+	"green":       "#97ca00",
+	"yellow":      "#dfb317",
+	"orange":      "#fe7d37",
+	"red":         "#e05d44",
+	"blue":        "#007ec6",
+	"lightgrey":   "#9f9f9f",
+	"grey":        "#555",
+}
+
+// hexColor matches three or six digit hex colors without the leading '#'.
+var hexColor = regexp.MustCompile(`^([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// BadgeColor resolves a named color or a hex color, with or without the
+// leading '#', to a value that is safe to use in a badge.
+func BadgeColor(color string) (string, bool) {
+	if c, ok := badgeColors[strings.ToLower(color)]; ok {
+		return c, true
+	}
+
+	color = strings.TrimPrefix(color, "#")
+	if hexColor.MatchString(color) {
+		return "#" + color, true
+	}
+
+	return "", false
+}
+
+// Badge renders a flat two part badge with the label on a grey background
+// and the message on the specified color, which must come from BadgeColor.
+func Badge(label string, message string, color string) string {
+	lw := textWidth(label)
+	mw := textWidth(message)
+	width := lw + mw
+
+	label = html.EscapeString(label)
+	message = html.EscapeString(message)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img" aria-label="%s: %s">`, width, badgeHeight, label, message)
+	fmt.Fprintf(&b, `<title>%s: %s</title>`, label, message)
+	b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="%d" rx="3" fill="#fff"/></clipPath>`, width, badgeHeight)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="%d" fill="%s"/><rect x="%d" width="%d" height="%d" fill="%s"/><rect width="%d" height="%d" fill="url(#s)"/></g>`,
+		lw, badgeHeight, badgeLabelFill, lw, mw, badgeHeight, color, width, badgeHeight)
+	fmt.Fprintf(&b, `<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11"><text x="%.1f" y="14">%s</text><text x="%.1f" y="14">%s</text></g>`,
+		float64(lw)/2, label, float64(lw)+float64(mw)/2, message)
+	b.WriteString(`</svg>`)
+
+	return b.String()
+}
+
+// textWidth estimates the width of a badge part from its number of
+// characters, which is close enough for the narrow font badges use.
+func textWidth(s string) int {
+	return utf8.RuneCountInString(s)*badgeCharWidth + badgePadding
+}
@@ -0,0 +1,63 @@
+// Package svg renders small charts and badges as standalone SVG documents
+// that can be inlined into HTML pages or served as images.
+package svg
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Bar is a single labeled value of a bar chart.
+type Bar struct {
+	Label string
+	Value int
+}
+
+// Set of layout parameters of bar charts.
+const (
+	chartPadding = 4
+	chartGap     = 2
+	chartColor   = "#4c9be8"
+	chartAxis    = "#d0d7de"
+)
+
+// BarChart renders the bars from left to right scaled to the largest value.
+// Each bar carries its label and value as a tooltip.
+func BarChart(bars []Bar, width int, height int) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img">`, width, height, width, height)
+
+	base := height - chartPadding
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`, chartPadding, base, width-chartPadding, base, chartAxis)
+
+	if len(bars) > 0 {
+		max := 0
+		for _, bar := range bars {
+			if bar.Value > max {
+				max = bar.Value
+			}
+		}
+
+		slot := float64(width-2*chartPadding) / float64(len(bars))
+		barWidth := slot - chartGap
+		if barWidth < 1 {
+			barWidth = 1
+		}
+
+		for i, bar := range bars {
+			h := 0.0
+			if max > 0 {
+				h = float64(bar.Value) / float64(max) * float64(base-chartPadding)
+			}
+			x := float64(chartPadding) + float64(i)*slot
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %d</title></rect>`,
+				x, float64(base)-h, barWidth, h, chartColor, html.EscapeString(bar.Label), bar.Value)
+		}
+	}
+
+	b.WriteString(`</svg>`)
+
+	return b.String()
+}
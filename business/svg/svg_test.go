@@ -0,0 +1,100 @@
+package svg_test
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mitrovicsinisaa/shorturl/business/svg"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// wellFormed reports whether the document parses as XML.
+func wellFormed(doc string) error {
+	d := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		if _, err := d.Token(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func TestBarChart(t *testing.T) {
+	t.Log("Given the need to render a bar chart.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen rendering bars with markup in their labels.", testID)
+		{
+			bars := []svg.Bar{{Label: "<day 1>", Value: 3}, {Label: "day 2", Value: 0}, {Label: "day 3", Value: 6}}
+			doc := svg.BarChart(bars, 300, 100)
+
+			if err := wellFormed(doc); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould render a well formed document : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould render a well formed document.", success, testID)
+
+			if n := strings.Count(doc, "<rect"); n != len(bars) {
+				t.Fatalf("\t%s\tTest %d:\tShould render a bar per value : got %d.", failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould render a bar per value.", success, testID)
+
+			if !strings.Contains(doc, `height="92.0"`) {
+				t.Fatalf("\t%s\tTest %d:\tShould scale the largest bar to the full height : %s.", failed, testID, doc)
+			}
+			t.Logf("\t%s\tTest %d:\tShould scale the largest bar to the full height.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen rendering no bars.", testID)
+		{
+			if err := wellFormed(svg.BarChart(nil, 300, 100)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould render a well formed document : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould render a well formed document.", success, testID)
+		}
+	}
+}
+
+func TestBadge(t *testing.T) {
+	t.Log("Given the need to render a badge.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen resolving badge colors.", testID)
+		{
+			for color, want := range map[string]string{"blue": "#007ec6", "4c1": "#4c1", "#ff8800": "#ff8800"} {
+				if got, ok := svg.BadgeColor(color); !ok || got != want {
+					t.Fatalf("\t%s\tTest %d:\tShould resolve %q to %q : got %q.", failed, testID, color, want, got)
+				}
+			}
+			for _, color := range []string{"", "purple-ish", `"/><script>`, "12345"} {
+				if _, ok := svg.BadgeColor(color); ok {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT resolve %q.", failed, testID, color)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould resolve only known and hex colors.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen rendering a badge with markup in its label.", testID)
+		{
+			doc := svg.Badge("<clicks>", "1.2k", "#4c1")
+
+			if err := wellFormed(doc); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould render a well formed document : %s.", failed, testID, err)
+			}
+			if !strings.Contains(doc, "&lt;clicks&gt;: 1.2k") {
+				t.Fatalf("\t%s\tTest %d:\tShould escape the label : %s.", failed, testID, doc)
+			}
+			t.Logf("\t%s\tTest %d:\tShould render an escaped, well formed document.", success, testID)
+		}
+	}
+}
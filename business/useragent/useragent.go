@@ -0,0 +1,116 @@
+// Package useragent provides a small table driven parser for the browser,
+// operating system and device class of a User-Agent header.
+package useragent
+
+import (
+	"strings"
+)
+
+// Set of device classes reported by Parse.
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceUnknown = "unknown"
+)
+
+// Unknown is reported for a browser or operating system that is not matched
+// by any rule.
+const Unknown = "unknown"
+
+// Info is the result of parsing a User-Agent header.
+type Info struct {
+	Browser string
+	OS      string
+	Device  string
+}
+
+// rule maps a User-Agent token to a name. Rules are evaluated in order and
+// the first rule whose tokens are all present in the header wins, so more
+// specific rules must come before the generic ones they overlap with.
+type rule struct {
+	tokens []string
+	name   string
+}
+
+// browsers is the table of known browser families.
+var browsers = []rule{
+	{[]string{"Edg/"}, "Edge"},
+	{[]string{"EdgA/"}, "Edge"},
+	{[]string{"EdgiOS/"}, "Edge"},
+	{[]string{"Edge/"}, "Edge"},
+	{[]string{"OPR/"}, "Opera"},
+	{[]string{"Opera"}, "Opera"},
+	{[]string{"SamsungBrowser/"}, "Samsung Internet"},
+	{[]string{"YaBrowser/"}, "Yandex"},
+	{[]string{"Vivaldi/"}, "Vivaldi"},
+	{[]string{"CriOS/"}, "Chrome"},
+	{[]string{"Chrome/"}, "Chrome"},
+	{[]string{"FxiOS/"}, "Firefox"},
+	{[]string{"Firefox/"}, "Firefox"},
+	{[]string{"MSIE "}, "Internet Explorer"},
+	{[]string{"Trident/"}, "Internet Explorer"},
+	{[]string{"Safari/"}, "Safari"},
+	{[]string{"curl/"}, "curl"},
+	{[]string{"Wget/"}, "Wget"},
+}
+
+// systems is the table of known operating systems.
+var systems = []rule{
+	{[]string{"Windows Phone"}, "Windows Phone"},
+	{[]string{"Windows"}, "Windows"},
+	{[]string{"iPhone"}, "iOS"},
+	{[]string{"iPad"}, "iOS"},
+	{[]string{"iPod"}, "iOS"},
+	{[]string{"Android"}, "Android"},
+	{[]string{"CrOS"}, "Chrome OS"},
+	{[]string{"Mac OS X"}, "macOS"},
+	{[]string{"Macintosh"}, "macOS"},
+	{[]string{"Linux"}, "Linux"},
+}
+
+// devices is the table of device classes. Headers that match no rule are
+// reported as desktop devices.
+var devices = []rule{
+	{[]string{"iPad"}, DeviceTablet},
+	{[]string{"Tablet"}, DeviceTablet},
+	{[]string{"Android", "Mobile"}, DeviceMobile},
+	{[]string{"Android"}, DeviceTablet},
+	{[]string{"iPhone"}, DeviceMobile},
+	{[]string{"iPod"}, DeviceMobile},
+	{[]string{"Mobi"}, DeviceMobile},
+	{[]string{"Windows Phone"}, DeviceMobile},
+}
+
+// Parse returns the browser family, operating system and device class
+// described by the User-Agent header.
+func Parse(ua string) Info {
+	if strings.TrimSpace(ua) == "" {
+		return Info{
+			Browser: Unknown,
+			OS:      Unknown,
+			Device:  DeviceUnknown,
+		}
+	}
+
+	return Info{
+		Browser: match(browsers, ua, Unknown),
+		OS:      match(systems, ua, Unknown),
+		Device:  match(devices, ua, DeviceDesktop),
+	}
+}
+
+// match returns the name of the first rule matching the header or def when
+// no rule matches.
+func match(rules []rule, ua string, def string) string {
+next:
+	for _, r := range rules {
+		for _, token := range r.tokens {
+			if !strings.Contains(ua, token) {
+				continue next
+			}
+		}
+		return r.name
+	}
+	return def
+}
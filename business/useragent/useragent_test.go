@@ -0,0 +1,80 @@
+package useragent_test
+
+import (
+	"testing"
+
+	"github.com/mitrovicsinisaa/shorturl/business/useragent"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		name string
+		ua   string
+		want useragent.Info
+	}{
+		{
+			"chromeWindows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+			useragent.Info{Browser: "Chrome", OS: "Windows", Device: useragent.DeviceDesktop},
+		},
+		{
+			"edgeWindows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36 Edg/91.0.864.59",
+			useragent.Info{Browser: "Edge", OS: "Windows", Device: useragent.DeviceDesktop},
+		},
+		{
+			"safariIPhone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1",
+			useragent.Info{Browser: "Safari", OS: "iOS", Device: useragent.DeviceMobile},
+		},
+		{
+			"safariIPad",
+			"Mozilla/5.0 (iPad; CPU OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1",
+			useragent.Info{Browser: "Safari", OS: "iOS", Device: useragent.DeviceTablet},
+		},
+		{
+			"firefoxLinux",
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0",
+			useragent.Info{Browser: "Firefox", OS: "Linux", Device: useragent.DeviceDesktop},
+		},
+		{
+			"chromeAndroidPhone",
+			"Mozilla/5.0 (Linux; Android 11; Pixel 5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.120 Mobile Safari/537.36",
+			useragent.Info{Browser: "Chrome", OS: "Android", Device: useragent.DeviceMobile},
+		},
+		{
+			"samsungAndroidTablet",
+			"Mozilla/5.0 (Linux; Android 10; SM-T510) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/14.2 Chrome/87.0.4280.141 Safari/537.36",
+			useragent.Info{Browser: "Samsung Internet", OS: "Android", Device: useragent.DeviceTablet},
+		},
+		{
+			"safariMac",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Safari/605.1.15",
+			useragent.Info{Browser: "Safari", OS: "macOS", Device: useragent.DeviceDesktop},
+		},
+		{
+			"empty",
+			"",
+			useragent.Info{Browser: useragent.Unknown, OS: useragent.Unknown, Device: useragent.DeviceUnknown},
+		},
+	}
+
+	t.Log("Given the need to parse User-Agent headers.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen parsing the %s header.", testID, tst.name)
+			{
+				if got := useragent.Parse(tst.ua); got != tst.want {
+					t.Fatalf("\t%s\tTest %d:\tShould get %+v : got %+v.", failed, testID, tst.want, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected result.", success, testID)
+			}
+		}
+	}
+}
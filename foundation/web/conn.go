@@ -0,0 +1,27 @@
+package web
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// keyConn is how the connection of a request is stored/retrieved.
+const keyConn ctcKey = 2
+
+// ConnContext stores the connection in the context of its requests, so
+// handlers can extend its deadlines. It is meant for http.Server.ConnContext.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, keyConn, c)
+}
+
+// ExtendWriteDeadline gives a long running response until t to be written,
+// overriding the write timeout of the server for this request. It does
+// nothing when the server does not store connections with ConnContext.
+func ExtendWriteDeadline(ctx context.Context, t time.Time) error {
+	c, ok := ctx.Value(keyConn).(net.Conn)
+	if !ok {
+		return nil
+	}
+	return c.SetWriteDeadline(t)
+}